@@ -0,0 +1,85 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package abci
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sunvim/yaoguang/codes"
+	"github.com/sunvim/yaoguang/crypto"
+	"github.com/sunvim/yaoguang/state"
+	abciTypes "github.com/tendermint/tendermint/abci/types"
+)
+
+const (
+	accountsQueryPath = "/accounts/"
+)
+
+func isAccountsQuery(query *abciTypes.RequestQuery) bool {
+	return strings.HasPrefix(query.Path, accountsQueryPath)
+}
+
+// getAccount returns the JSON encoded account of the hex address following the query path as of the query height,
+// clients read the sequence to sign their next transaction with from it
+func (app *App) getAccount(query *abciTypes.RequestQuery) (rsp abciTypes.ResponseQuery) {
+	address, err := crypto.AddressFromHexString(strings.TrimPrefix(query.Path, accountsQueryPath))
+	if err != nil {
+		rsp.Code = codes.EncodingErrorCode
+		rsp.Codespace = codes.Codespace
+		rsp.Log = fmt.Sprintf("invalid account address: %v", err)
+		return
+	}
+	rsp.Key = address.Bytes()
+	reader, height, release, err := app.readerAt(query.Height)
+	rsp.Height = int64(height)
+	if err != nil {
+		rsp.Code, rsp.Codespace, rsp.Log = codes.ABCIInfo(err)
+		return
+	}
+	defer release()
+	acc, err := reader.GetAccount(address)
+	if err != nil {
+		rsp.Code = codes.EncodingErrorCode
+		rsp.Codespace = codes.Codespace
+		rsp.Log = fmt.Sprintf("could not read account %v: %v", address, err)
+		return
+	}
+	if acc == nil {
+		rsp.Code = codes.UnknownAccountCode
+		rsp.Codespace = codes.Codespace
+		rsp.Log = fmt.Sprintf("account %v does not exist", address)
+		return
+	}
+	if query.Prove {
+		if err := app.prove(&rsp, height, state.AccountKey(address)); err != nil {
+			rsp.Code, rsp.Codespace, rsp.Log = codes.ABCIInfo(err)
+		}
+		return
+	}
+	bs, err := acc.Encode()
+	if err != nil {
+		rsp.Code = codes.EncodingErrorCode
+		rsp.Codespace = codes.Codespace
+		rsp.Log = fmt.Sprintf("could not encode account %v: %v", address, err)
+		return
+	}
+	rsp.Code = codes.TxExecutionSuccessCode
+	rsp.Value = bs
+	return
+}
@@ -18,6 +18,8 @@
 package abci
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"runtime/debug"
 	"sync"
@@ -25,9 +27,17 @@ import (
 	"github.com/rs/zerolog/log"
 	"github.com/sunvim/yaoguang/blockchain"
 	"github.com/sunvim/yaoguang/codes"
+	"github.com/sunvim/yaoguang/crypto"
+	"github.com/sunvim/yaoguang/execution"
+	"github.com/sunvim/yaoguang/genesis"
+	"github.com/sunvim/yaoguang/state"
 	"github.com/sunvim/yaoguang/txs"
+	"github.com/sunvim/yaoguang/txs/payload"
+	"github.com/sunvim/yaoguang/upgrade"
 	"github.com/sunvim/yaoguang/validators"
+	"github.com/sunvim/yaoguang/version"
 	"github.com/tendermint/tendermint/abci/types"
+	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
 )
 
 type Validators interface {
@@ -41,9 +51,17 @@ type App struct {
 	nodeInfo string
 	// state
 	blockchain    *blockchain.Blockchain
+	state         *state.State
 	validators    Validators
+	checker       execution.BatchExecutor
+	committer     execution.BatchCommitter
+	simulator     execution.Executor
 	mempoolLocker sync.Locker
-	block         *types.RequestBeginBlock
+	pruner        *state.Pruner
+	// held for the duration of each Commit and for good once the app is closed
+	commitLock  sync.Mutex
+	prioritizer Prioritizer
+	block       *types.RequestBeginBlock
 
 	// fail gracefully
 	panicFunc func(error)
@@ -51,12 +69,22 @@ type App struct {
 	txsDecoder txs.Decoder
 }
 
-func NewApp(nodeInfo string, blockchain *blockchain.Blockchain, validators Validators, txsDecoder txs.Decoder) *App {
+func NewApp(nodeInfo string, blockchain *blockchain.Blockchain, st *state.State, validators Validators,
+	checker execution.BatchExecutor, committer execution.BatchCommitter, simulator execution.Executor,
+	txsDecoder txs.Decoder) *App {
 	app := &App{
-		nodeInfo:   nodeInfo,
-		blockchain: blockchain,
-		validators: validators,
-		txsDecoder: txsDecoder,
+		nodeInfo:    nodeInfo,
+		blockchain:  blockchain,
+		state:       st,
+		validators:  validators,
+		checker:     checker,
+		committer:   committer,
+		simulator:   simulator,
+		txsDecoder:  txsDecoder,
+		prioritizer: DefaultPrioritizer(),
+		panicFunc: func(err error) {
+			panic(err)
+		},
 	}
 	return app
 }
@@ -64,24 +92,69 @@ func NewApp(nodeInfo string, blockchain *blockchain.Blockchain, validators Valid
 // Info/Query Connection
 // Return application info
 func (app *App) Info(_ types.RequestInfo) types.ResponseInfo {
-	return types.ResponseInfo{
-		Data:             app.nodeInfo,
-		Version:          "dev",
-		LastBlockHeight:  int64(app.blockchain.LastBlockHeight()),
-		LastBlockAppHash: app.blockchain.AppHashAfterLastBlock(),
+	rsp := types.ResponseInfo{
+		Data:       app.nodeInfo,
+		Version:    version.Get().String(),
+		AppVersion: app.appVersion(),
 	}
+	// Tendermint only calls InitChain for an application at height 0, whatever the initial height of the chain
+	if app.blockchain.HasBlocks() {
+		rsp.LastBlockHeight = int64(app.blockchain.LastBlockHeight())
+		rsp.LastBlockAppHash = app.blockchain.AppHashAfterLastBlock()
+	}
+	return rsp
 }
 
-func (app *App) Query(reqQuery types.RequestQuery) types.ResponseQuery {
+// appVersion is the application protocol version of the last block, or of genesis before the first block
+func (app *App) appVersion() uint64 {
+	if !app.blockchain.HasBlocks() {
+		genesisDoc := app.blockchain.GenesisDoc()
+		if genesisDoc.ConsensusParams == nil {
+			return 0
+		}
+		return genesisDoc.ConsensusParams.Version.AppVersion
+	}
+	appVersion, err := state.NewReader(app.state).GetAppVersion()
+	if err != nil {
+		log.Error().Err(err).Msg("could not read app version")
+	}
+	return appVersion
+}
+
+func (app *App) Query(reqQuery types.RequestQuery) (rsp types.ResponseQuery) {
+	// a query does not change state so a failure is answered rather than halting the node
 	defer func() {
 		if r := recover(); r != nil {
-			app.panicFunc(fmt.Errorf("panic occured in abci.App/Query"))
+			log.Error().Str("stack", string(debug.Stack())).Msgf("panic occurred in abci.App/Query: %v", r)
+			rsp = types.ResponseQuery{
+				Code:      codes.InternalErrorCode,
+				Codespace: codes.Codespace,
+				Log:       fmt.Sprintf("query %s failed: %v", reqQuery.Path, r),
+			}
 		}
 	}()
 
+	switch {
+	case isSimulateQuery(&reqQuery):
+		return app.simulate(&reqQuery)
+	case isAccountsQuery(&reqQuery):
+		return app.getAccount(&reqQuery)
+	case isNamesQuery(&reqQuery):
+		return app.resolveName(&reqQuery)
+	case isProposalsQuery(&reqQuery):
+		return app.getBallot(&reqQuery)
+	case isStoreQuery(&reqQuery):
+		return app.getStore(&reqQuery)
+	case isValidatorsQuery(&reqQuery):
+		return app.getValidators(&reqQuery)
+	case isUpgradeQuery(&reqQuery):
+		return app.getUpgrade(&reqQuery)
+	}
+
 	var rawResponse types.ResponseQuery
 	rawResponse.Log = "Query not supported"
 	rawResponse.Code = codes.UnsupportedRequestCode
+	rawResponse.Codespace = codes.Codespace
 
 	return rawResponse
 }
@@ -91,13 +164,57 @@ func (app *App) Query(reqQuery types.RequestQuery) types.ResponseQuery {
 func (app *App) CheckTx(req types.RequestCheckTx) (rsp types.ResponseCheckTx) {
 	const logHeader = "CheckTx"
 	defer func() {
+		// the transaction is only rejected from the mempool, the node carries on
 		if r := recover(); r != nil {
-			app.panicFunc(fmt.Errorf("panic occurred in abci.App/CheckTx: %v\n%s", r, debug.Stack()))
+			log.Error().Str("stack", string(debug.Stack())).Msgf("panic occurred in abci.App/CheckTx: %v", r)
+			rsp = types.ResponseCheckTx{
+				Code:      codes.InternalErrorCode,
+				Codespace: codes.Codespace,
+				Log:       fmt.Sprintf("CheckTx failed: %v", r),
+			}
 		}
 	}()
 	log.Info().Str("event", "entry").Msg(logHeader)
-	log.Info().Str("event", "exit").Msg(logHeader)
-	return
+	defer log.Info().Str("event", "exit").Msg(logHeader)
+
+	txEnv, err := app.txsDecoder.DecodeTx(req.Tx)
+	if err != nil {
+		log.Error().Err(err).Str("tx", fmt.Sprintf("%X", req.Tx)).Msg(logHeader)
+		return types.ResponseCheckTx{
+			Code:      codes.EncodingErrorCode,
+			Codespace: codes.Codespace,
+			Log:       fmt.Sprintf("CheckTx could not decode tx: %v", err),
+		}
+	}
+	txe, err := app.checker.Execute(txEnv)
+	if err != nil {
+		log.Info().Err(err).Stringer("tx", txEnv).Msg(logHeader)
+		code, codespace, _ := codes.ABCIInfo(err)
+		return types.ResponseCheckTx{
+			Code:      code,
+			Codespace: codespace,
+			Log:       fmt.Sprintf("CheckTx could not execute tx %v: %v", txEnv.Tx.Hash(), err),
+		}
+	}
+	return types.ResponseCheckTx{
+		Code:     codes.TxExecutionSuccessCode,
+		Log:      "CheckTx success",
+		Data:     txe.TxHash,
+		GasUsed:  int64(txe.GasUsed),
+		Priority: app.prioritizer.Priority(txEnv, txe),
+		// The priority mempool keeps a single transaction per sender, so an account may queue one per sequence
+		Sender: sender(txEnv.Tx.GetInputs()[0]),
+	}
+}
+
+func sender(input *payload.TxInput) string {
+	return fmt.Sprintf("%v/%d", input.Address, input.Sequence)
+}
+
+// SetPrioritizer replaces the prioritizer used to rank transactions in the mempool, so a game can put its own
+// critical transactions first
+func (app *App) SetPrioritizer(prioritizer Prioritizer) {
+	app.prioritizer = prioritizer
 }
 
 // Provide the Mempool lock. When provided we will attempt to acquire this lock in a goroutine during the Commit. We
@@ -107,6 +224,11 @@ func (app *App) SetMempoolLocker(mempoolLocker sync.Locker) {
 	app.mempoolLocker = mempoolLocker
 }
 
+// SetPruner has pruner prune the state in the background after each Commit
+func (app *App) SetPruner(pruner *state.Pruner) {
+	app.pruner = pruner
+}
+
 // Consensus Connection
 // Initialize blockchain w validators/other info from TendermintCore
 func (app *App) InitChain(req types.RequestInitChain) (rsp types.ResponseInitChain) {
@@ -117,7 +239,53 @@ func (app *App) InitChain(req types.RequestInitChain) (rsp types.ResponseInitCha
 		}
 	}()
 	log.Info().Str("event", "entry").Msg(logHeader)
-	log.Info().Str("event", "exit").Msg(logHeader)
+	defer log.Info().Str("event", "exit").Msg(logHeader)
+
+	if req.ChainId != app.blockchain.ChainID() {
+		app.panicFunc(fmt.Errorf("tendermint passed chain ID %s to InitChain but genesis has chain ID %s",
+			req.ChainId, app.blockchain.ChainID()))
+		return
+	}
+	appState, err := genesis.AppStateFromJSON(req.AppStateBytes)
+	if err != nil {
+		app.panicFunc(err)
+		return
+	}
+	if len(appState.Validators) == 0 {
+		// Take the validators from the genesis file
+		for _, v := range req.Validators {
+			publicKey, err := crypto.PublicKeyFromABCIPubKey(v.PubKey)
+			if err != nil {
+				app.panicFunc(fmt.Errorf("could not read genesis validator: %w", err))
+				return
+			}
+			appState.Validators = append(appState.Validators, genesis.Validator{
+				PublicKey: publicKey,
+				Power:     uint64(v.Power),
+			})
+		}
+	} else {
+		// Our validators replace those of the genesis file
+		for i, gv := range appState.Validators {
+			if !gv.PublicKey.IsSet() {
+				app.panicFunc(fmt.Errorf("genesis validator %d has no public key", i))
+				return
+			}
+			pubKey, err := gv.PublicKey.ABCIPubKey()
+			if err != nil {
+				app.panicFunc(fmt.Errorf("could not convert genesis validator: %w", err))
+				return
+			}
+			rsp.Validators = append(rsp.Validators, types.ValidatorUpdate{
+				PubKey: pubKey,
+				Power:  int64(gv.Power),
+			})
+		}
+	}
+	if err := app.committer.InitChain(req.ConsensusParams.GetVersion().GetAppVersion(), appState); err != nil {
+		app.panicFunc(fmt.Errorf("could not load genesis app state: %w", err))
+		return
+	}
 	return
 }
 
@@ -128,8 +296,19 @@ func (app *App) BeginBlock(req types.RequestBeginBlock) (rsp types.ResponseBegin
 			app.panicFunc(fmt.Errorf("panic occurred in abci.App/BeginBlock: %v\n%s", r, debug.Stack()))
 		}
 	}()
-	log.Info().Str("event", "entry").Msg(logHeader)
-	log.Info().Str("event", "exit").Msg(logHeader)
+	log.Info().Str("event", "entry").Int64("height", req.Header.Height).Msg(logHeader)
+	defer log.Info().Str("event", "exit").Msg(logHeader)
+	app.block = &req
+
+	be, err := app.committer.BeginBlock(uint64(req.Header.Height))
+	if err != nil {
+		if errors.Is(err, upgrade.ErrUnknownUpgrade) {
+			log.Error().Err(err).Msg("halting for upgrade")
+		}
+		app.panicFunc(fmt.Errorf("could not begin block at height %d: %w", req.Header.Height, err))
+		return
+	}
+	rsp.Events = be.Events
 	return
 }
 
@@ -141,8 +320,39 @@ func (app *App) DeliverTx(req types.RequestDeliverTx) (rsp types.ResponseDeliver
 		}
 	}()
 	log.Info().Str("event", "entry").Msg(logHeader)
-	log.Info().Str("event", "exit").Msg(logHeader)
-	return
+	defer log.Info().Str("event", "exit").Msg(logHeader)
+
+	txEnv, err := app.txsDecoder.DecodeTx(req.Tx)
+	if err != nil {
+		log.Error().Err(err).Str("tx", fmt.Sprintf("%X", req.Tx)).Msg(logHeader)
+		return types.ResponseDeliverTx{
+			Code:      codes.EncodingErrorCode,
+			Codespace: codes.Codespace,
+			Log:       fmt.Sprintf("DeliverTx could not decode tx: %v", err),
+		}
+	}
+	txe, err := app.committer.Execute(txEnv)
+	if err != nil {
+		log.Info().Err(err).Stringer("tx", txEnv).Msg(logHeader)
+		code, codespace, _ := codes.ABCIInfo(err)
+		rsp = types.ResponseDeliverTx{
+			Code:      code,
+			Codespace: codespace,
+			Log:       fmt.Sprintf("DeliverTx could not execute tx %v: %v", txEnv.Tx.Hash(), err),
+		}
+		if txe != nil {
+			rsp.GasUsed = int64(txe.GasUsed)
+			rsp.Events = txe.Events
+		}
+		return rsp
+	}
+	return types.ResponseDeliverTx{
+		Code:    codes.TxExecutionSuccessCode,
+		Log:     "DeliverTx success",
+		Data:    txe.TxHash,
+		GasUsed: int64(txe.GasUsed),
+		Events:  txe.Events,
+	}
 }
 
 func (app *App) EndBlock(req types.RequestEndBlock) (rsp types.ResponseEndBlock) {
@@ -153,7 +363,20 @@ func (app *App) EndBlock(req types.RequestEndBlock) (rsp types.ResponseEndBlock)
 		}
 	}()
 	log.Info().Str("event", "entry").Msg(logHeader)
-	log.Info().Str("event", "exit").Msg(logHeader)
+	defer log.Info().Str("event", "exit").Msg(logHeader)
+
+	be, err := app.committer.EndBlock(uint64(req.Height))
+	if err != nil {
+		app.panicFunc(fmt.Errorf("could not end block at height %d: %w", req.Height, err))
+		return
+	}
+	rsp.Events = be.Events
+	rsp.ValidatorUpdates = be.ValidatorUpdates
+	if be.AppVersion > 0 {
+		rsp.ConsensusParamUpdates = &tmproto.ConsensusParams{
+			Version: &tmproto.VersionParams{AppVersion: be.AppVersion},
+		}
+	}
 	return
 }
 
@@ -165,8 +388,51 @@ func (app *App) Commit() (rsp types.ResponseCommit) {
 		}
 	}()
 	log.Info().Str("event", "entry").Msg(logHeader)
-	log.Info().Str("event", "exit").Msg(logHeader)
-	return
+	defer log.Info().Str("event", "exit").Msg(logHeader)
+
+	app.commitLock.Lock()
+	defer app.commitLock.Unlock()
+
+	if app.block == nil {
+		app.panicFunc(fmt.Errorf("Commit called without a preceding BeginBlock"))
+		return
+	}
+	appHash, err := app.committer.Commit(&app.block.Header)
+	if err != nil {
+		app.panicFunc(fmt.Errorf("could not commit block at height %d: %w", app.block.Header.Height, err))
+		return
+	}
+	err = app.blockchain.CommitBlock(app.block.Header.Time, app.block.Hash, appHash)
+	if err != nil {
+		app.panicFunc(fmt.Errorf("could not commit block to blockchain state: %w", err))
+		return
+	}
+	// Pending sequences are rebuilt as Tendermint rechecks the remaining mempool transactions
+	app.checker.Reset()
+	if app.pruner != nil {
+		app.pruner.Prune()
+	}
+	log.Info().Uint64("height", app.blockchain.LastBlockHeight()).
+		Str("app_hash", fmt.Sprintf("%X", appHash)).Msg(logHeader)
+	return types.ResponseCommit{
+		Data: appHash,
+	}
+}
+
+// Close waits for a Commit in progress to finish and blocks any further Commit, so the state database can be closed
+// without cutting a write short
+func (app *App) Close(ctx context.Context) error {
+	closed := make(chan struct{})
+	go func() {
+		app.commitLock.Lock()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 // State Sync Connection
@@ -0,0 +1,129 @@
+package abci
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/sunvim/yaoguang/blockchain"
+	"github.com/sunvim/yaoguang/codes"
+	"github.com/sunvim/yaoguang/crypto"
+	"github.com/sunvim/yaoguang/execution"
+	"github.com/sunvim/yaoguang/genesis"
+	"github.com/sunvim/yaoguang/state"
+	"github.com/sunvim/yaoguang/txs"
+	"github.com/sunvim/yaoguang/txs/payload"
+	"github.com/tendermint/tendermint/abci/types"
+	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
+	tmtypes "github.com/tendermint/tendermint/types"
+	dbm "github.com/tendermint/tm-db"
+)
+
+const testChainID = "yaoguang-test"
+
+var (
+	alice = crypto.PrivateKeyFromSecret("alice", crypto.CurveTypeEd25519)
+	bob   = crypto.PrivateKeyFromSecret("bob", crypto.CurveTypeEd25519)
+)
+
+func TestApp_MalformedTxs(t *testing.T) {
+	app := newTestApp(t)
+	malformed := []string{
+		`{"tx":{"chain_id":"yaoguang-test","type":"NameTx","payload":{}}}`,
+		`{"tx":{"chain_id":"yaoguang-test","type":"BondTx","payload":{}}}`,
+		`{"tx":{"chain_id":"yaoguang-test","type":"UnbondTx","payload":{}}}`,
+		`{"tx":{"chain_id":"yaoguang-test","type":"PermissionsTx","payload":{}}}`,
+		`{"tx":{"chain_id":"yaoguang-test","type":"ProposalTx","payload":{}}}`,
+		`{"tx":{"chain_id":"yaoguang-test","type":"UpgradeTx","payload":{}}}`,
+		`{"tx":{"chain_id":"yaoguang-test","type":"SendTx","payload":{"inputs":[null]}}}`,
+		`{"tx":{"chain_id":"yaoguang-test","type":"SendTx","payload":{"inputs":[{"address":"` +
+			alice.GetAddress().String() + `","amount":10,"sequence":1}],"outputs":[null]}}}`,
+	}
+	for _, tx := range malformed {
+		rsp := app.CheckTx(types.RequestCheckTx{Tx: []byte(tx)})
+		assert.Equal(t, codes.InvalidTxCode, rsp.Code, tx)
+
+		query := app.Query(types.RequestQuery{Path: simulateQueryPath, Data: []byte(tx)})
+		require.Equal(t, codes.TxExecutionSuccessCode, query.Code, tx)
+		result := new(SimulateResponse)
+		require.NoError(t, json.Unmarshal(query.Value, result))
+		assert.Equal(t, codes.InvalidTxCode, result.Code, tx)
+	}
+}
+
+type panicDecoder struct{}
+
+func (panicDecoder) DecodeTx([]byte) (*txs.Envelope, error) {
+	panic("bad decoder")
+}
+
+func TestApp_RecoversOutsideConsensus(t *testing.T) {
+	app := newTestApp(t)
+	app.txsDecoder = panicDecoder{}
+	rsp := app.CheckTx(types.RequestCheckTx{Tx: []byte("tx")})
+	assert.Equal(t, codes.InternalErrorCode, rsp.Code)
+	query := app.Query(types.RequestQuery{Path: simulateQueryPath, Data: []byte("tx")})
+	assert.Equal(t, codes.InternalErrorCode, query.Code)
+}
+
+func TestApp_CheckTxSender(t *testing.T) {
+	app := newTestApp(t)
+	codec := txs.NewJSONCodec()
+	senders := make(map[string]bool)
+	// Several transactions from one account can wait in the mempool together
+	for seq := uint64(1); seq <= 3; seq++ {
+		send := payload.NewSendTx()
+		send.AddInput(alice.GetAddress(), 10, seq)
+		send.AddOutput(bob.GetAddress(), 10)
+		txEnv := txs.Enclose(testChainID, send)
+		require.NoError(t, txEnv.Sign(&alice))
+		txBytes, err := codec.EncodeTx(txEnv)
+		require.NoError(t, err)
+		rsp := app.CheckTx(types.RequestCheckTx{Tx: txBytes})
+		require.Equal(t, codes.TxExecutionSuccessCode, rsp.Code, rsp.Log)
+		senders[rsp.Sender] = true
+	}
+	assert.Len(t, senders, 3)
+}
+
+func TestApp_UnprovableQueries(t *testing.T) {
+	app := newTestApp(t)
+	for _, path := range []string{validatorsQueryPath, upgradeQueryPath} {
+		rsp := app.Query(types.RequestQuery{Path: path})
+		assert.Equal(t, codes.TxExecutionSuccessCode, rsp.Code, path)
+		rsp = app.Query(types.RequestQuery{Path: path, Prove: true})
+		assert.Equal(t, codes.UnsupportedRequestCode, rsp.Code, path)
+	}
+	// A single key can be proven
+	rsp := app.Query(types.RequestQuery{Path: accountsQueryPath + alice.GetAddress().String(), Prove: true})
+	require.Equal(t, codes.TxExecutionSuccessCode, rsp.Code, rsp.Log)
+	assert.NotNil(t, rsp.ProofOps)
+}
+
+// newTestApp returns an app that has committed its first block with alice as a funded account
+func newTestApp(t *testing.T) *App {
+	genesisDoc := &tmtypes.GenesisDoc{ChainID: testChainID, GenesisTime: time.Now(), InitialHeight: 1}
+	db := dbm.NewMemDB()
+	bc, err := blockchain.NewBlockchain(db, genesisDoc)
+	require.NoError(t, err)
+	st, err := state.NewState(db)
+	require.NoError(t, err)
+	app := NewApp("test", bc, st, nil, execution.NewBatchChecker(st, bc), execution.NewBatchCommitter(st, bc),
+		execution.NewSimulator(st, bc), txs.NewJSONCodec())
+	app.panicFunc = func(err error) {
+		t.Fatal(err)
+	}
+	appState, err := json.Marshal(genesis.AppState{
+		Accounts:   []genesis.Account{{Address: alice.GetAddress(), PublicKey: alice.GetPublicKey(), Balance: 1000}},
+		Validators: []genesis.Validator{{PublicKey: alice.GetPublicKey(), Power: 10}},
+	})
+	require.NoError(t, err)
+	app.InitChain(types.RequestInitChain{ChainId: testChainID, AppStateBytes: appState})
+	header := tmproto.Header{ChainID: testChainID, Height: 1, Time: genesisDoc.GenesisTime}
+	app.BeginBlock(types.RequestBeginBlock{Header: header})
+	app.EndBlock(types.RequestEndBlock{Height: 1})
+	app.Commit()
+	return app
+}
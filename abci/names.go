@@ -0,0 +1,78 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package abci
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sunvim/yaoguang/codes"
+	"github.com/sunvim/yaoguang/state"
+	abciTypes "github.com/tendermint/tendermint/abci/types"
+)
+
+const (
+	namesQueryPath = "/names/"
+)
+
+func isNamesQuery(query *abciTypes.RequestQuery) bool {
+	return strings.HasPrefix(query.Path, namesQueryPath)
+}
+
+// resolveName returns the JSON encoded entry of the name following the query path as of the query height
+func (app *App) resolveName(query *abciTypes.RequestQuery) (rsp abciTypes.ResponseQuery) {
+	name := strings.TrimPrefix(query.Path, namesQueryPath)
+	rsp.Key = []byte(name)
+	reader, height, release, err := app.readerAt(query.Height)
+	rsp.Height = int64(height)
+	if err != nil {
+		rsp.Code, rsp.Codespace, rsp.Log = codes.ABCIInfo(err)
+		return
+	}
+	defer release()
+	entry, err := reader.GetName(name)
+	if err != nil {
+		rsp.Code = codes.EncodingErrorCode
+		rsp.Codespace = codes.Codespace
+		rsp.Log = fmt.Sprintf("could not read name %s: %v", name, err)
+		return
+	}
+	// Leases that run out at the next height are already swept by its EndBlock
+	if entry == nil || entry.Expired(height+1) {
+		rsp.Code = codes.UnknownNameCode
+		rsp.Codespace = codes.Codespace
+		rsp.Log = fmt.Sprintf("name %s is not registered", name)
+		return
+	}
+	if query.Prove {
+		if err := app.prove(&rsp, height, state.NameKey(name)); err != nil {
+			rsp.Code, rsp.Codespace, rsp.Log = codes.ABCIInfo(err)
+		}
+		return
+	}
+	bs, err := entry.Encode()
+	if err != nil {
+		rsp.Code = codes.EncodingErrorCode
+		rsp.Codespace = codes.Codespace
+		rsp.Log = fmt.Sprintf("could not encode name %s: %v", name, err)
+		return
+	}
+	rsp.Code = codes.TxExecutionSuccessCode
+	rsp.Value = bs
+	return
+}
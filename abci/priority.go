@@ -0,0 +1,119 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package abci
+
+import (
+	"math"
+	"math/big"
+
+	"github.com/sunvim/yaoguang/execution/exec"
+	"github.com/sunvim/yaoguang/txs"
+	"github.com/sunvim/yaoguang/txs/payload"
+)
+
+// Prioritizer decides the ResponseCheckTx priority of a transaction. Tendermint's priority mempool (mempool version
+// "v1") reaps transactions for a block highest priority first and evicts the lowest when full. Until ABCI++ brings
+// PrepareProposal this is the application's only say in how blocks are composed.
+type Prioritizer interface {
+	Priority(txEnv *txs.Envelope, txe *exec.TxExecution) int64
+}
+
+// DefaultPrioritizer ranks by DefaultTypePrioritizer then by gas price
+func DefaultPrioritizer() Prioritizer {
+	return &ClassPrioritizer{
+		Class: DefaultTypePrioritizer(),
+		Price: GasPricePrioritizer{},
+	}
+}
+
+// TypePrioritizer ranks transactions by payload type
+type TypePrioritizer struct {
+	Priorities map[payload.Type]int64
+	// Priority of types not in Priorities
+	Default int64
+}
+
+var _ Prioritizer = (*TypePrioritizer)(nil)
+
+// DefaultTypePrioritizer puts chain administration ahead of staking ahead of everything else, so permission changes
+// and upgrades cannot be crowded out of blocks by ordinary traffic. Proposals and votes are ordinary traffic: any
+// account may send them without a fee, so they compete on price like the rest.
+func DefaultTypePrioritizer() *TypePrioritizer {
+	return &TypePrioritizer{
+		Priorities: map[payload.Type]int64{
+			payload.TypePermissions: 3,
+			payload.TypeUpgrade:     3,
+			payload.TypeBond:        2,
+			payload.TypeUnbond:      2,
+		},
+		Default: 1,
+	}
+}
+
+func (tp *TypePrioritizer) Priority(txEnv *txs.Envelope, _ *exec.TxExecution) int64 {
+	if priority, ok := tp.Priorities[txEnv.Tx.Type()]; ok {
+		return priority
+	}
+	return tp.Default
+}
+
+// GasPricePrioritizer ranks transactions by the fee they pay per unit of gas in thousandths, so the priority mempool
+// evicts cheap spam first
+type GasPricePrioritizer struct{}
+
+var _ Prioritizer = GasPricePrioritizer{}
+
+func (GasPricePrioritizer) Priority(txEnv *txs.Envelope, txe *exec.TxExecution) int64 {
+	if txe.GasUsed == 0 {
+		return 0
+	}
+	price := new(big.Int).SetUint64(txEnv.Tx.Fee)
+	price.Mul(price, big.NewInt(1000))
+	price.Div(price, new(big.Int).SetUint64(txe.GasUsed))
+	if !price.IsInt64() {
+		return math.MaxInt64
+	}
+	return price.Int64()
+}
+
+// Priorities within a class are capped below 1 << classShift
+const classShift = 40
+
+// ClassPrioritizer ranks transactions by Class and only within a class by Price
+type ClassPrioritizer struct {
+	Class Prioritizer
+	Price Prioritizer
+}
+
+var _ Prioritizer = (*ClassPrioritizer)(nil)
+
+func (cp *ClassPrioritizer) Priority(txEnv *txs.Envelope, txe *exec.TxExecution) int64 {
+	class := cp.Class.Priority(txEnv, txe)
+	if class < 0 {
+		class = 0
+	} else if class >= 1<<(63-classShift) {
+		class = 1<<(63-classShift) - 1
+	}
+	price := cp.Price.Priority(txEnv, txe)
+	if price < 0 {
+		price = 0
+	} else if price >= 1<<classShift {
+		price = 1<<classShift - 1
+	}
+	return class<<classShift | price
+}
@@ -0,0 +1,66 @@
+package abci
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sunvim/yaoguang/crypto"
+	"github.com/sunvim/yaoguang/execution/exec"
+	"github.com/sunvim/yaoguang/txs"
+	"github.com/sunvim/yaoguang/txs/payload"
+)
+
+func TestTypePrioritizer(t *testing.T) {
+	prioritizer := DefaultTypePrioritizer()
+	priority := func(p payload.Payload) int64 {
+		return prioritizer.Priority(txs.Enclose("chain", p), &exec.TxExecution{})
+	}
+	send := priority(payload.NewSendTx())
+	name := priority(payload.NewNameTx(crypto.Address{1}, 10, 1, "name", "data"))
+	bond := priority(payload.NewBondTx(crypto.Address{1}, 10, 1, nil))
+	proposal := priority(&payload.ProposalTx{})
+	permissions := priority(&payload.PermissionsTx{})
+
+	assert.Equal(t, int64(1), send)
+	assert.Equal(t, send, name)
+	assert.Greater(t, bond, send)
+	assert.Greater(t, permissions, bond)
+	// Anyone may propose or vote, so that does not outrank fee paying traffic
+	assert.Equal(t, send, proposal)
+
+	prioritizer.Priorities[payload.TypeName] = 5
+	assert.Equal(t, int64(5), priority(payload.NewNameTx(crypto.Address{1}, 10, 1, "name", "data")))
+}
+
+func TestGasPricePrioritizer(t *testing.T) {
+	priority := func(fee, gasUsed uint64) int64 {
+		txEnv := txs.Enclose("chain", payload.NewSendTx())
+		txEnv.Tx.Fee = fee
+		return GasPricePrioritizer{}.Priority(txEnv, &exec.TxExecution{GasUsed: gasUsed})
+	}
+	assert.Equal(t, int64(0), priority(10, 0))
+	assert.Equal(t, int64(0), priority(0, 1000))
+	assert.Equal(t, int64(10), priority(10, 1000))
+	assert.Equal(t, int64(2500), priority(5, 2))
+	assert.Equal(t, int64(math.MaxInt64), priority(math.MaxUint64, 1))
+}
+
+func TestDefaultPrioritizer(t *testing.T) {
+	prioritizer := DefaultPrioritizer()
+	priority := func(p payload.Payload, fee, gasUsed uint64) int64 {
+		txEnv := txs.Enclose("chain", p)
+		txEnv.Tx.Fee = fee
+		return prioritizer.Priority(txEnv, &exec.TxExecution{GasUsed: gasUsed})
+	}
+	send := payload.NewSendTx()
+	bond := payload.NewBondTx(crypto.Address{1}, 10, 1, nil)
+	proposal := &payload.ProposalTx{}
+
+	assert.Greater(t, priority(send, 20, 1000), priority(send, 10, 1000))
+	assert.Greater(t, priority(send, 10, 1000), priority(send, 10, 2000))
+	// Class comes before price
+	assert.Greater(t, priority(bond, 0, 1000), priority(send, 1<<60, 1))
+	assert.Equal(t, int64(1)<<classShift, priority(send, 0, 1000))
+	assert.Greater(t, priority(send, 10, 1000), priority(proposal, 0, 1000))
+}
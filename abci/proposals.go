@@ -0,0 +1,77 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package abci
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/sunvim/yaoguang/codes"
+	abciTypes "github.com/tendermint/tendermint/abci/types"
+)
+
+const (
+	proposalsQueryPath = "/proposals/"
+)
+
+func isProposalsQuery(query *abciTypes.RequestQuery) bool {
+	return strings.HasPrefix(query.Path, proposalsQueryPath)
+}
+
+// getBallot returns the JSON encoded ballot of the proposal whose hex hash follows the query path
+func (app *App) getBallot(query *abciTypes.RequestQuery) (rsp abciTypes.ResponseQuery) {
+	proposalHash, err := hex.DecodeString(strings.TrimPrefix(query.Path, proposalsQueryPath))
+	if err != nil {
+		rsp.Code = codes.EncodingErrorCode
+		rsp.Codespace = codes.Codespace
+		rsp.Log = fmt.Sprintf("could not decode proposal hash: %v", err)
+		return
+	}
+	rsp.Key = proposalHash
+	reader, height, release, err := app.readerAt(query.Height)
+	rsp.Height = int64(height)
+	if err != nil {
+		rsp.Code, rsp.Codespace, rsp.Log = codes.ABCIInfo(err)
+		return
+	}
+	defer release()
+	ballot, err := reader.GetBallot(proposalHash)
+	if err != nil {
+		rsp.Code = codes.EncodingErrorCode
+		rsp.Codespace = codes.Codespace
+		rsp.Log = fmt.Sprintf("could not read proposal %X: %v", proposalHash, err)
+		return
+	}
+	if ballot == nil {
+		rsp.Code = codes.UnknownProposalCode
+		rsp.Codespace = codes.Codespace
+		rsp.Log = fmt.Sprintf("proposal %X does not exist", proposalHash)
+		return
+	}
+	bs, err := ballot.Encode()
+	if err != nil {
+		rsp.Code = codes.EncodingErrorCode
+		rsp.Codespace = codes.Codespace
+		rsp.Log = fmt.Sprintf("could not encode proposal %X: %v", proposalHash, err)
+		return
+	}
+	rsp.Code = codes.TxExecutionSuccessCode
+	rsp.Value = bs
+	return
+}
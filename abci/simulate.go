@@ -0,0 +1,80 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package abci
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/sunvim/yaoguang/binary"
+	"github.com/sunvim/yaoguang/codes"
+	abciTypes "github.com/tendermint/tendermint/abci/types"
+)
+
+const (
+	simulateQueryPath = "/tx/simulate"
+)
+
+// SimulateResponse is the JSON encoded value of a simulate query. Code is the code the transaction would get from
+// DeliverTx against the latest committed state.
+type SimulateResponse struct {
+	TxHash    binary.HexBytes   `json:"tx_hash,omitempty"`
+	Code      uint32            `json:"code"`
+	Codespace string            `json:"codespace,omitempty"`
+	Log       string            `json:"log,omitempty"`
+	GasUsed   uint64            `json:"gas_used"`
+	Return    binary.HexBytes   `json:"return,omitempty"`
+	Events    []abciTypes.Event `json:"events,omitempty"`
+}
+
+func isSimulateQuery(query *abciTypes.RequestQuery) bool {
+	return query.Path == simulateQueryPath
+}
+
+// simulate executes the encoded transaction in query.Data without committing it, signatures are optional
+func (app *App) simulate(query *abciTypes.RequestQuery) (rsp abciTypes.ResponseQuery) {
+	rsp.Height = int64(app.blockchain.LastBlockHeight())
+	txEnv, err := app.txsDecoder.DecodeTx(query.Data)
+	if err != nil {
+		rsp.Code = codes.EncodingErrorCode
+		rsp.Codespace = codes.Codespace
+		rsp.Log = fmt.Sprintf("could not decode tx to simulate: %v", err)
+		return
+	}
+	result := SimulateResponse{
+		TxHash: txEnv.Tx.Hash(),
+	}
+	txe, err := app.simulator.Execute(txEnv)
+	if txe != nil {
+		result.GasUsed = txe.GasUsed
+		result.Return = txe.Return
+		result.Events = txe.Events
+	}
+	result.Code, result.Codespace, result.Log = codes.ABCIInfo(err)
+	bs, err := json.Marshal(result)
+	if err != nil {
+		rsp.Code = codes.EncodingErrorCode
+		rsp.Codespace = codes.Codespace
+		rsp.Log = fmt.Sprintf("could not encode simulation result: %v", err)
+		return
+	}
+	rsp.Code = codes.TxExecutionSuccessCode
+	rsp.Key = result.TxHash
+	rsp.Value = bs
+	return
+}
@@ -0,0 +1,196 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package abci
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/sunvim/yaoguang/codes"
+	"github.com/sunvim/yaoguang/crypto"
+	"github.com/sunvim/yaoguang/state"
+	"github.com/sunvim/yaoguang/txs/payload"
+	abciTypes "github.com/tendermint/tendermint/abci/types"
+	tmcrypto "github.com/tendermint/tendermint/proto/tendermint/crypto"
+)
+
+const (
+	storeQueryPath      = "/store"
+	validatorsQueryPath = "/validators"
+	upgradeQueryPath    = "/upgrade"
+
+	// ProofOpIAVLCommitment is the type of the ProofOp of a proven query, its data is an ics23.CommitmentProof of
+	// the state key against the app hash committed in the header of the block after the query height
+	ProofOpIAVLCommitment = "ics23:iavl"
+)
+
+// ValidatorEntry is an element of the JSON encoded value of a validators query
+type ValidatorEntry struct {
+	Address   crypto.Address    `json:"address"`
+	PublicKey *crypto.PublicKey `json:"public_key"`
+	Power     *big.Int          `json:"power"`
+}
+
+// UpgradeStatus is the JSON encoded value of an upgrade query
+type UpgradeStatus struct {
+	AppVersion uint64               `json:"app_version"`
+	Plan       *payload.UpgradePlan `json:"plan,omitempty"`
+}
+
+func isStoreQuery(query *abciTypes.RequestQuery) bool {
+	return query.Path == storeQueryPath
+}
+
+func isValidatorsQuery(query *abciTypes.RequestQuery) bool {
+	return query.Path == validatorsQueryPath
+}
+
+func isUpgradeQuery(query *abciTypes.RequestQuery) bool {
+	return query.Path == upgradeQueryPath
+}
+
+// getStore returns the raw value of the state key in query.Data, an absent key has an empty value
+func (app *App) getStore(query *abciTypes.RequestQuery) (rsp abciTypes.ResponseQuery) {
+	rsp.Key = query.Data
+	_, height, release, err := app.readerAt(query.Height)
+	rsp.Height = int64(height)
+	if err != nil {
+		rsp.Code, rsp.Codespace, rsp.Log = codes.ABCIInfo(err)
+		return
+	}
+	defer release()
+	// the raw value is read from the tree along with its proof
+	if err := app.prove(&rsp, height, query.Data); err != nil {
+		rsp.Code, rsp.Codespace, rsp.Log = codes.ABCIInfo(err)
+		return
+	}
+	if !query.Prove {
+		rsp.ProofOps = nil
+	}
+	return
+}
+
+// getValidators returns the JSON encoded validator set in address order. The set is read from a key per validator
+// so cannot be proven.
+func (app *App) getValidators(query *abciTypes.RequestQuery) (rsp abciTypes.ResponseQuery) {
+	if query.Prove {
+		rsp.Code, rsp.Codespace, rsp.Log = codes.ABCIInfo(codes.UnsupportedRequest.Errorf("validators cannot be proven"))
+		return
+	}
+	reader, height, release, err := app.readerAt(query.Height)
+	rsp.Height = int64(height)
+	if err != nil {
+		rsp.Code, rsp.Codespace, rsp.Log = codes.ABCIInfo(err)
+		return
+	}
+	defer release()
+	entries := []ValidatorEntry{}
+	err = reader.IterateValidators(func(id crypto.Addressable, power *big.Int) error {
+		entries = append(entries, ValidatorEntry{
+			Address:   id.GetAddress(),
+			PublicKey: id.GetPublicKey(),
+			Power:     power,
+		})
+		return nil
+	})
+	if err == nil {
+		rsp.Value, err = json.Marshal(entries)
+	}
+	if err != nil {
+		rsp.Code = codes.EncodingErrorCode
+		rsp.Codespace = codes.Codespace
+		rsp.Log = fmt.Sprintf("could not read validators: %v", err)
+	}
+	return
+}
+
+// getUpgrade returns the JSON encoded app version and scheduled upgrade, which are kept under separate keys so cannot
+// be proven together
+func (app *App) getUpgrade(query *abciTypes.RequestQuery) (rsp abciTypes.ResponseQuery) {
+	if query.Prove {
+		rsp.Code, rsp.Codespace, rsp.Log = codes.ABCIInfo(codes.UnsupportedRequest.Errorf("upgrade cannot be proven"))
+		return
+	}
+	reader, height, release, err := app.readerAt(query.Height)
+	rsp.Height = int64(height)
+	if err != nil {
+		rsp.Code, rsp.Codespace, rsp.Log = codes.ABCIInfo(err)
+		return
+	}
+	defer release()
+	status := UpgradeStatus{}
+	status.AppVersion, err = reader.GetAppVersion()
+	if err == nil {
+		status.Plan, err = reader.GetUpgradePlan()
+	}
+	if err == nil {
+		rsp.Value, err = json.Marshal(status)
+	}
+	if err != nil {
+		rsp.Code = codes.EncodingErrorCode
+		rsp.Codespace = codes.Codespace
+		rsp.Log = fmt.Sprintf("could not read upgrade: %v", err)
+	}
+	return
+}
+
+// readerAt reads the state committed with the block at height, or with the last block if height is 0. The height is
+// kept from being pruned until release is called.
+func (app *App) readerAt(height int64) (reader *state.Reader, _ uint64, release func(), err error) {
+	last := app.blockchain.LastBlockHeight()
+	if height < 0 || uint64(height) > last {
+		return nil, last, nil, codes.InvalidHeight.Errorf("height %d is beyond the last block %d", height, last)
+	}
+	if height == 0 {
+		height = int64(last)
+	}
+	release, err = app.state.Hold(height)
+	if err != nil {
+		return nil, uint64(height), nil, codes.InvalidHeight.Wrap(err, fmt.Sprintf("could not read state at "+
+			"height %d, it may have been pruned", height))
+	}
+	reader, err = app.state.Reader(height)
+	if err != nil {
+		release()
+		return nil, uint64(height), nil, codes.InvalidHeight.Wrap(err, fmt.Sprintf("could not read state at "+
+			"height %d", height))
+	}
+	return reader, uint64(height), release, nil
+}
+
+// prove sets the value of key at height, which must be held, and a proof of it, or of its absence, on rsp
+func (app *App) prove(rsp *abciTypes.ResponseQuery, height uint64, key []byte) error {
+	value, proof, err := app.state.Prove(int64(height), key)
+	if err != nil {
+		return codes.InvalidHeight.Wrap(err, fmt.Sprintf("could not prove key %X at height %d", key, height))
+	}
+	bs, err := proof.Marshal()
+	if err != nil {
+		return codes.EncodingError.Wrap(err, "could not encode proof")
+	}
+	rsp.Value = value
+	rsp.ProofOps = &tmcrypto.ProofOps{
+		Ops: []tmcrypto.ProofOp{{
+			Type: ProofOpIAVLCommitment,
+			Key:  key,
+			Data: bs,
+		}},
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package account
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/sunvim/yaoguang/binary"
+	"github.com/sunvim/yaoguang/codes"
+	"github.com/sunvim/yaoguang/crypto"
+	"github.com/sunvim/yaoguang/permission"
+)
+
+type Account struct {
+	Address   crypto.Address    `json:"address"`
+	PublicKey *crypto.PublicKey `json:"public_key,omitempty"`
+	// Sequence of the last transaction signed by this account, the next one must carry Sequence+1
+	Sequence uint64 `json:"sequence"`
+	Balance  uint64 `json:"balance"`
+	// Override the global permissions for this account
+	Permissions permission.AccountPermissions `json:"permissions"`
+}
+
+func NewAccount(address crypto.Address) *Account {
+	return &Account{Address: address}
+}
+
+func (acc *Account) GetAddress() crypto.Address {
+	return acc.Address
+}
+
+func (acc *Account) GetPublicKey() *crypto.PublicKey {
+	return acc.PublicKey
+}
+
+// AddToBalance credits amount returning an error on overflow
+func (acc *Account) AddToBalance(amount uint64) error {
+	if binary.IsUint64SumOverflow(acc.Balance, amount) {
+		return codes.IntegerOverflow.Errorf("adding %d to balance %d of account %v would overflow", amount,
+			acc.Balance, acc.Address)
+	}
+	acc.Balance += amount
+	return nil
+}
+
+// SubtractFromBalance debits amount returning an error if the account cannot cover it
+func (acc *Account) SubtractFromBalance(amount uint64) error {
+	if amount > acc.Balance {
+		return codes.InsufficientFunds.Errorf("insufficient funds: account %v has balance %d but %d was requested",
+			acc.Address, acc.Balance, amount)
+	}
+	acc.Balance -= amount
+	return nil
+}
+
+func (acc *Account) Copy() *Account {
+	if acc == nil {
+		return nil
+	}
+	accCopy := *acc
+	accCopy.Permissions.Roles = append([]string(nil), acc.Permissions.Roles...)
+	return &accCopy
+}
+
+func (acc *Account) Encode() ([]byte, error) {
+	return json.Marshal(acc)
+}
+
+func Decode(bs []byte) (*Account, error) {
+	acc := new(Account)
+	if err := json.Unmarshal(bs, acc); err != nil {
+		return nil, err
+	}
+	return acc, nil
+}
+
+func (acc *Account) String() string {
+	if acc == nil {
+		return "Nil Account"
+	}
+	return fmt.Sprintf("Account{Address: %v; Sequence: %d; Balance: %d}", acc.Address, acc.Sequence, acc.Balance)
+}
@@ -16,3 +16,137 @@
  */
 
 package blockchain
+
+import (
+	"fmt"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/google/orderedcode"
+	tmstate "github.com/tendermint/tendermint/proto/tendermint/state"
+	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
+	"github.com/tendermint/tendermint/types"
+	dbm "github.com/tendermint/tm-db"
+)
+
+// Names of the databases Tendermint keeps its blocks and its own state in under the db dir
+const (
+	BlockStoreDBName      = "blockstore"
+	TendermintStateDBName = "state"
+)
+
+// Key prefixes of the Tendermint block and state stores, which are internal to Tendermint so are read directly
+const (
+	prefixBlockMeta     = int64(0)
+	prefixBlockPart     = int64(1)
+	prefixABCIResponses = int64(7)
+)
+
+// BlockStore reads the blocks a stopped Tendermint node has stored
+type BlockStore struct {
+	db dbm.DB
+}
+
+func NewBlockStore(db dbm.DB) *BlockStore {
+	return &BlockStore{db: db}
+}
+
+// Base is the height of the first block stored, 0 if there are none
+func (bs *BlockStore) Base() (uint64, error) {
+	return bs.firstHeight(false)
+}
+
+// Height is the height of the last block stored, 0 if there are none
+func (bs *BlockStore) Height() (uint64, error) {
+	return bs.firstHeight(true)
+}
+
+func (bs *BlockStore) firstHeight(reverse bool) (uint64, error) {
+	start, end := orderedKey(prefixBlockMeta, 1), orderedKey(prefixBlockMeta, 1<<63-1)
+	iterator := bs.db.Iterator
+	if reverse {
+		iterator = bs.db.ReverseIterator
+	}
+	it, err := iterator(start, end)
+	if err != nil {
+		return 0, err
+	}
+	defer it.Close()
+	if !it.Valid() {
+		return 0, it.Error()
+	}
+	var prefix, height int64
+	if _, err := orderedcode.Parse(string(it.Key()), &prefix, &height); err != nil {
+		return 0, fmt.Errorf("could not decode block meta key %X: %w", it.Key(), err)
+	}
+	return uint64(height), nil
+}
+
+// LoadBlock returns the block at height, nil if it is not stored
+func (bs *BlockStore) LoadBlock(height uint64) (*types.Block, error) {
+	bz, err := bs.db.Get(orderedKey(prefixBlockMeta, int64(height)))
+	if err != nil {
+		return nil, err
+	}
+	if len(bz) == 0 {
+		return nil, nil
+	}
+	meta := new(tmproto.BlockMeta)
+	if err := proto.Unmarshal(bz, meta); err != nil {
+		return nil, fmt.Errorf("could not decode meta of block %d: %w", height, err)
+	}
+	var buf []byte
+	for i := uint32(0); i < meta.BlockID.PartSetHeader.Total; i++ {
+		bz, err := bs.db.Get(orderedKey(prefixBlockPart, int64(height), int64(i)))
+		if err != nil {
+			return nil, err
+		}
+		if len(bz) == 0 {
+			return nil, fmt.Errorf("part %d of block %d is missing", i, height)
+		}
+		part := new(tmproto.Part)
+		if err := proto.Unmarshal(bz, part); err != nil {
+			return nil, fmt.Errorf("could not decode part %d of block %d: %w", i, height, err)
+		}
+		buf = append(buf, part.Bytes...)
+	}
+	pbb := new(tmproto.Block)
+	if err := proto.Unmarshal(buf, pbb); err != nil {
+		return nil, fmt.Errorf("could not decode block %d: %w", height, err)
+	}
+	block, err := types.BlockFromProto(pbb)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode block %d: %w", height, err)
+	}
+	return block, nil
+}
+
+// LoadABCIResponses returns the responses of the application to the block at height that Tendermint saved in its
+// state database, nil if there are none
+func LoadABCIResponses(db dbm.DB, height uint64) (*tmstate.ABCIResponses, error) {
+	bz, err := db.Get(orderedKey(prefixABCIResponses, int64(height)))
+	if err != nil {
+		return nil, err
+	}
+	if len(bz) == 0 {
+		return nil, nil
+	}
+	responses := new(tmstate.ABCIResponses)
+	if err := responses.Unmarshal(bz); err != nil {
+		return nil, fmt.Errorf("could not decode ABCI responses of block %d: %w", height, err)
+	}
+	return responses, nil
+}
+
+func orderedKey(prefix int64, parts ...int64) []byte {
+	key, err := orderedcode.Append(nil, prefix)
+	if err != nil {
+		panic(err)
+	}
+	for _, part := range parts {
+		key, err = orderedcode.Append(key, part)
+		if err != nil {
+			panic(err)
+		}
+	}
+	return key
+}
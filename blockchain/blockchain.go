@@ -18,13 +18,20 @@
 package blockchain
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"sync"
 	"time"
 
+	"github.com/sunvim/yaoguang/crypto"
+	tmjson "github.com/tendermint/tendermint/libs/json"
 	"github.com/tendermint/tendermint/types"
 	db "github.com/tendermint/tm-db"
 )
 
+var stateKey = []byte("blockchain")
+
 type Blockchain struct {
 	sync.RWMutex
 	state              State
@@ -35,12 +42,116 @@ type Blockchain struct {
 	lastCommitDuration time.Duration
 }
 
-func NewBlockchain() *Blockchain {
-	return &Blockchain{}
+// NewBlockchain returns a Blockchain that has not committed any blocks. Its last block height is the one before the
+// initial height of the genesis doc so the first block committed is at the initial height.
+func NewBlockchain(db db.DB, genesisDoc *types.GenesisDoc) (*Blockchain, error) {
+	genesisHash, err := hashGenesis(genesisDoc)
+	if err != nil {
+		return nil, err
+	}
+	bc := &Blockchain{
+		db:      db,
+		genesis: *genesisDoc,
+		state: State{
+			GenesisHash:   genesisHash,
+			LastBlockTime: genesisDoc.GenesisTime,
+		},
+	}
+	bc.state.LastBlockHeight = bc.InitialHeight() - 1
+	return bc, nil
+}
+
+// LoadOrNewBlockchain resumes the Blockchain saved in db, or starts a new one if there is none
+func LoadOrNewBlockchain(db db.DB, genesisDoc *types.GenesisDoc) (*Blockchain, error) {
+	bc, err := NewBlockchain(db, genesisDoc)
+	if err != nil {
+		return nil, err
+	}
+	bs, err := db.Get(stateKey)
+	if err != nil {
+		return nil, fmt.Errorf("could not read blockchain state: %w", err)
+	}
+	if bs == nil {
+		return bc, nil
+	}
+	state := State{}
+	if err := json.Unmarshal(bs, &state); err != nil {
+		return nil, fmt.Errorf("could not decode blockchain state: %w", err)
+	}
+	if !bytes.Equal(state.GenesisHash, bc.state.GenesisHash) {
+		return nil, fmt.Errorf("genesis doc has hash %v but the saved blockchain was started from genesis with hash %v",
+			bc.state.GenesisHash, state.GenesisHash)
+	}
+	bc.state = state
+	return bc, nil
+}
+
+// CommitBlock records that the block at the next height has been committed with appHash
+func (bc *Blockchain) CommitBlock(blockTime time.Time, blockHash, appHash []byte) error {
+	bc.Lock()
+	defer bc.Unlock()
+	now := time.Now()
+	state := bc.state
+	state.LastBlockHeight++
+	state.LastBlockTime = blockTime
+	state.AppHashAfterLastBlock = appHash
+	if err := bc.save(state); err != nil {
+		return err
+	}
+	bc.state = state
+	bc.lastBlockHash = blockHash
+	if !bc.lastCommitTime.IsZero() {
+		bc.lastCommitDuration = now.Sub(bc.lastCommitTime)
+	}
+	bc.lastCommitTime = now
+	return nil
+}
+
+// Reset records the block at height as the last committed with appHash, to resume from a state that has been
+// replayed or rolled back to that height. A height before the initial height records that no blocks are committed.
+func (bc *Blockchain) Reset(height uint64, blockTime time.Time, appHash []byte) error {
+	bc.Lock()
+	defer bc.Unlock()
+	state := bc.state
+	state.LastBlockHeight = height
+	state.LastBlockTime = blockTime
+	state.AppHashAfterLastBlock = appHash
+	if err := bc.save(state); err != nil {
+		return err
+	}
+	bc.state = state
+	bc.lastBlockHash = nil
+	bc.lastCommitTime = time.Time{}
+	bc.lastCommitDuration = 0
+	return nil
+}
+
+func (bc *Blockchain) save(state State) error {
+	bs, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("could not encode blockchain state: %w", err)
+	}
+	return bc.db.SetSync(stateKey, bs)
 }
 
-func (bc *Blockchain) SetGenesisDoc(genesis *types.GenesisDoc) {
-	bc.genesis = *genesis
+func (bc *Blockchain) GenesisHash() []byte {
+	return bc.state.GenesisHash
+}
+
+func (bc *Blockchain) GenesisDoc() types.GenesisDoc {
+	return bc.genesis
+}
+
+func (bc *Blockchain) ChainID() string {
+	return bc.genesis.ChainID
+}
+
+// ValidatorKeyTypes are the types of key the consensus params of the genesis allow for a validator
+func (bc *Blockchain) ValidatorKeyTypes() []string {
+	if bc.genesis.ConsensusParams == nil {
+		return types.DefaultValidatorParams().PubKeyTypes
+	}
+	return bc.genesis.ConsensusParams.Validator.PubKeyTypes
 }
 
 func (bc *Blockchain) LastBlockHeight() uint64 {
@@ -55,8 +166,53 @@ func (bc *Blockchain) LastBlockHeight() uint64 {
 
 }
 
+// InitialHeight is the height of the first block of the chain, greater than 1 for a chain started from exported state
+func (bc *Blockchain) InitialHeight() uint64 {
+	if bc.genesis.InitialHeight > 1 {
+		return uint64(bc.genesis.InitialHeight)
+	}
+	return 1
+}
+
+// HasBlocks reports whether any block has been committed since genesis
+func (bc *Blockchain) HasBlocks() bool {
+	return bc.LastBlockHeight() >= bc.InitialHeight()
+}
+
+func (bc *Blockchain) LastBlockTime() time.Time {
+	bc.RLock()
+	defer bc.RUnlock()
+	return bc.state.LastBlockTime
+}
+
+func (bc *Blockchain) LastCommitTime() time.Time {
+	bc.RLock()
+	defer bc.RUnlock()
+	return bc.lastCommitTime
+}
+
+func (bc *Blockchain) LastCommitDuration() time.Duration {
+	bc.RLock()
+	defer bc.RUnlock()
+	return bc.lastCommitDuration
+}
+
+func (bc *Blockchain) LastBlockHash() []byte {
+	bc.RLock()
+	defer bc.RUnlock()
+	return bc.lastBlockHash
+}
+
 func (bc *Blockchain) AppHashAfterLastBlock() []byte {
 	bc.RLock()
 	defer bc.RUnlock()
 	return bc.state.AppHashAfterLastBlock
 }
+
+func hashGenesis(genesisDoc *types.GenesisDoc) ([]byte, error) {
+	bs, err := tmjson.Marshal(genesisDoc)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode genesis doc: %w", err)
+	}
+	return crypto.SHA256(bs), nil
+}
@@ -27,6 +27,8 @@ type BlockchainInfo interface {
 	GenesisHash() []byte
 	GenesisDoc() types.GenesisDoc
 	ChainID() string
+	InitialHeight() uint64
+	HasBlocks() bool
 	LastBlockHeight() uint64
 	LastBlockTime() time.Time
 	LastCommitTime() time.Time
@@ -0,0 +1,58 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package commands
+
+import (
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/sunvim/utils/grace"
+	"github.com/sunvim/yaoguang/core"
+	"github.com/sunvim/yaoguang/share"
+)
+
+var CmdABCI = &cobra.Command{
+	Use:   "abci",
+	Short: "serve the application to an out-of-process tendermint node",
+	Long: `Serve the application over tendermint's socket or gRPC ABCI protocol, chosen by the abci option of the
+config, on its proxy-app address. Start the node with external-abci set in the [yaoguang] section of the config to
+connect to it.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// errors from here on are not usage errors
+		cmd.SilenceUsage = true
+
+		_, srv := grace.New(cmd.Context())
+
+		log.Info().Str("service", "booting").Msg("abci")
+		config, _ := cmd.Flags().GetString(share.BootConfig)
+		log.Info().Str("config", config).Msg("abci")
+		nodeInfo, _ := cmd.Flags().GetString(share.BootNodeInfo)
+		kern, err := core.NewABCIKern(nodeInfo, config)
+		if err != nil {
+			return err
+		}
+		if err := boot(kern); err != nil {
+			return err
+		}
+		return serve(srv, kern)
+	},
+}
+
+func init() {
+	CmdABCI.PersistentFlags().StringP(share.BootConfig, "c", "config/config.toml", "configuration for this service")
+	CmdABCI.PersistentFlags().StringP(share.BootNodeInfo, "", "dev", "node name or id")
+}
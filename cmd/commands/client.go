@@ -0,0 +1,91 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/sunvim/yaoguang/account"
+	"github.com/sunvim/yaoguang/codes"
+	"github.com/sunvim/yaoguang/crypto"
+	abciTypes "github.com/tendermint/tendermint/abci/types"
+	rpcclient "github.com/tendermint/tendermint/rpc/client"
+	rpchttp "github.com/tendermint/tendermint/rpc/client/http"
+)
+
+// How long a client command waits on the node, broadcast_tx_commit is bounded by the node's own timeout
+const rpcTimeout = 60 * time.Second
+
+func addNodeFlag(cmd *cobra.Command) {
+	cmd.PersistentFlags().String("node", "http://127.0.0.1:26657", "Tendermint RPC address of the node")
+}
+
+func rpcClient(cmd *cobra.Command) (*rpchttp.HTTP, error) {
+	node, _ := cmd.Flags().GetString("node")
+	client, err := rpchttp.NewWithTimeout(node, rpcTimeout)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not create client of %s", node)
+	}
+	return client, nil
+}
+
+// abciQuery runs query path against the app through the node, a response with an error code is returned as an
+// error carrying that code
+func abciQuery(ctx context.Context, client rpcclient.ABCIClient, path string, data []byte, height int64,
+	prove bool) (*abciTypes.ResponseQuery, error) {
+	result, err := client.ABCIQueryWithOptions(ctx, path, data, rpcclient.ABCIQueryOptions{
+		Height: height,
+		Prove:  prove,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "query %s failed", path)
+	}
+	rsp := result.Response
+	if rsp.Code != codes.TxExecutionSuccessCode {
+		return &rsp, errors.Errorf("query %s failed with code %d (%s): %s", path, rsp.Code, rsp.Codespace, rsp.Log)
+	}
+	return &rsp, nil
+}
+
+func queryAccount(ctx context.Context, client rpcclient.ABCIClient, address crypto.Address, height int64,
+	prove bool) (*account.Account, *abciTypes.ResponseQuery, error) {
+	rsp, err := abciQuery(ctx, client, "/accounts/"+address.String(), nil, height, prove)
+	if err != nil {
+		return nil, rsp, err
+	}
+	acc, err := account.Decode(rsp.Value)
+	if err != nil {
+		return nil, rsp, errors.Wrapf(err, "could not decode account %v", address)
+	}
+	return acc, rsp, nil
+}
+
+func printJSON(v interface{}) error {
+	bs, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(os.Stdout, string(bs))
+	return err
+}
@@ -0,0 +1,72 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package commands
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/sunvim/yaoguang/core"
+	"github.com/sunvim/yaoguang/share"
+	tmjson "github.com/tendermint/tendermint/libs/json"
+)
+
+var CmdExport = &cobra.Command{
+	Use:   "export",
+	Short: "dump the state of a stopped node to a genesis file",
+	Long: `export writes the accounts, validators, names, proposals and staking of the state committed at
+--height to a genesis file whose chain starts at the following height, so a new chain can be started
+from the same state. The node must be stopped.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+
+		config, _ := cmd.Flags().GetString(share.BootConfig)
+		height, _ := cmd.Flags().GetUint64("height")
+		chainID, _ := cmd.Flags().GetString("chain-id")
+		output, _ := cmd.Flags().GetString("output")
+
+		genesisDoc, err := core.ExportGenesis(config, height, chainID)
+		if err != nil {
+			return err
+		}
+		if output != "" {
+			if err := genesisDoc.SaveAs(output); err != nil {
+				return errors.Wrapf(err, "could not write %s", output)
+			}
+			log.Info().Str("chain-id", genesisDoc.ChainID).Int64("initial-height", genesisDoc.InitialHeight).
+				Str("output", output).Msg("export")
+			return nil
+		}
+		bs, err := tmjson.MarshalIndent(genesisDoc, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "could not encode genesis")
+		}
+		fmt.Println(string(bs))
+		return nil
+	},
+}
+
+func init() {
+	CmdExport.Flags().StringP(share.BootConfig, "c", "config/config.toml", "configuration of the node to export")
+	CmdExport.Flags().Uint64("height", 0, "height of the state to export, the last block if 0")
+	CmdExport.Flags().String("chain-id", "", "chain ID of the exported genesis, that of the node if empty")
+	CmdExport.Flags().StringP("output", "o", "", "genesis file to write, stdout if empty")
+}
@@ -0,0 +1,88 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package commands
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/sunvim/yaoguang/core"
+	"github.com/sunvim/yaoguang/crypto"
+	"github.com/sunvim/yaoguang/genesis"
+)
+
+var CmdInit = &cobra.Command{
+	Use:   "init",
+	Short: "create the home directory of a single validator node",
+	Long: `init writes config/config.toml, a node key, a private validator key and a genesis
+file with this node as the only validator, whose address is funded with --balance`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+
+		home, _ := cmd.Flags().GetString("home")
+		chainID, _ := cmd.Flags().GetString("chain-id")
+		moniker, _ := cmd.Flags().GetString("moniker")
+		keyType, _ := cmd.Flags().GetString("key-type")
+		balance, _ := cmd.Flags().GetUint64("balance")
+
+		curveType, err := crypto.CurveTypeFromString(keyType)
+		if err != nil || curveType == crypto.CurveTypeUnset {
+			return errors.Errorf("--key-type must be ed25519 or secp256k1, not %q", keyType)
+		}
+		if chainID == "" {
+			return errors.New("--chain-id must not be empty")
+		}
+
+		node, err := core.InitHome(core.HomeConfig(home, moniker), curveType)
+		if err != nil {
+			return err
+		}
+		appState := &genesis.AppState{
+			Accounts: []genesis.Account{{
+				Address:   node.Validator.GetAddress(),
+				PublicKey: node.Validator,
+				Balance:   balance,
+			}},
+		}
+		genesisDoc, err := core.NewGenesisDoc(chainID, []*core.Home{node}, appState)
+		if err != nil {
+			return err
+		}
+		if err := genesisDoc.SaveAs(node.Config.GenesisFile()); err != nil {
+			return errors.Wrap(err, "could not write genesis file")
+		}
+
+		log.Info().Str("home", home).Str("chain-id", chainID).Str("node-id", string(node.NodeID)).
+			Str("validator", node.Validator.GetAddress().String()).Msg("init")
+		fmt.Printf("start the node with: yaoguang start --config %s\n",
+			filepath.Join(home, "config", "config.toml"))
+		return nil
+	},
+}
+
+func init() {
+	CmdInit.Flags().String("home", ".", "directory to create the node home in")
+	CmdInit.Flags().String("chain-id", "yaoguang", "chain ID of the genesis")
+	CmdInit.Flags().String("moniker", "node0", "name of this node")
+	CmdInit.Flags().String("key-type", "ed25519", "curve of the validator key, ed25519 or secp256k1")
+	CmdInit.Flags().Uint64("balance", 1000000000, "genesis balance of the validator's account")
+}
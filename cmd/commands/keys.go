@@ -0,0 +1,302 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/sunvim/yaoguang/crypto"
+	"github.com/sunvim/yaoguang/keys"
+	hex "github.com/tmthrgd/go-hex"
+	"golang.org/x/term"
+)
+
+// Environment variable a passphrase is read from when --passphrase-file is not given, for scripts
+const passphraseEnv = "YAOGUANG_PASSPHRASE"
+
+var CmdKeys = &cobra.Command{
+	Use:   "keys",
+	Short: "manage the keys of the local keystore",
+	Long: fmt.Sprintf(`keys are kept encrypted with a passphrase, which is read from --passphrase-file, from
+$%s or else prompted for`, passphraseEnv),
+}
+
+var cmdKeysGenerate = &cobra.Command{
+	Use:   "generate [name]",
+	Short: "generate a new key",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		curveType, err := keyTypeFlag(cmd)
+		if err != nil {
+			return err
+		}
+		passphrase, err := readPassphrase(cmd, true)
+		if err != nil {
+			return err
+		}
+		key, err := keystore(cmd).Generate(optionalArg(args), curveType, passphrase)
+		if err != nil {
+			return err
+		}
+		printKey(key)
+		return nil
+	},
+}
+
+var cmdKeysList = &cobra.Command{
+	Use:   "list",
+	Short: "list the keys in the keystore",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		ks, err := keystore(cmd).List()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tADDRESS\tCURVE")
+		for _, key := range ks {
+			fmt.Fprintf(w, "%s\t%v\t%v\n", key.Name, key.Address, key.PublicKey.CurveType)
+		}
+		return w.Flush()
+	},
+}
+
+var cmdKeysShow = &cobra.Command{
+	Use:   "show <name|address>",
+	Short: "show the address and public key of a key",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		key, err := keystore(cmd).Get(args[0])
+		if err != nil {
+			return err
+		}
+		printKey(key)
+		return nil
+	},
+}
+
+var cmdKeysImport = &cobra.Command{
+	Use:   "import [name]",
+	Short: "import a hex encoded private key read from --private-key-file or prompted for",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		curveType, err := keyTypeFlag(cmd)
+		if err != nil {
+			return err
+		}
+		var keyHex string
+		if file, _ := cmd.Flags().GetString("private-key-file"); file != "" {
+			bs, err := os.ReadFile(file)
+			if err != nil {
+				return errors.Wrap(err, "could not read private key")
+			}
+			keyHex = string(bs)
+		} else if keyHex, err = prompt("Private key (hex): "); err != nil {
+			return err
+		}
+		raw, err := hex.DecodeString(strings.TrimSpace(keyHex))
+		if err != nil {
+			return errors.Wrap(err, "private key is not hex")
+		}
+		privateKey, err := crypto.PrivateKeyFromRawBytes(raw, curveType)
+		if err != nil {
+			return errors.Wrap(err, "invalid private key")
+		}
+		passphrase, err := readPassphrase(cmd, true)
+		if err != nil {
+			return err
+		}
+		key, err := keystore(cmd).Import(optionalArg(args), privateKey, passphrase)
+		if err != nil {
+			return err
+		}
+		printKey(key)
+		return nil
+	},
+}
+
+var cmdKeysExport = &cobra.Command{
+	Use:   "export <name|address>",
+	Short: "print the hex encoded private key of a key",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		privateKey, err := unlock(cmd, args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "anyone holding this %v key controls its account\n", privateKey.CurveType)
+		fmt.Println(hex.EncodeUpperToString(privateKey.RawBytes()))
+		return nil
+	},
+}
+
+var cmdKeysDelete = &cobra.Command{
+	Use:   "delete <name|address>",
+	Short: "remove a key from the keystore, its passphrase is required",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		if _, err := unlock(cmd, args[0]); err != nil {
+			return err
+		}
+		return keystore(cmd).Delete(args[0])
+	},
+}
+
+var cmdKeysSign = &cobra.Command{
+	Use:   "sign <name|address> <hex message>",
+	Short: "sign a hex encoded message and print the hex encoded signature",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		msg, err := hex.DecodeString(args[1])
+		if err != nil {
+			return errors.Wrap(err, "message is not hex")
+		}
+		privateKey, err := unlock(cmd, args[0])
+		if err != nil {
+			return err
+		}
+		sig, err := privateKey.Sign(msg)
+		if err != nil {
+			return err
+		}
+		fmt.Println(hex.EncodeUpperToString(sig.RawBytes()))
+		return nil
+	},
+}
+
+func keystore(cmd *cobra.Command) *keys.Keystore {
+	dir, _ := cmd.Flags().GetString("keystore")
+	return keys.NewKeystore(dir, keys.StandardScryptN)
+}
+
+func unlock(cmd *cobra.Command, nameOrAddress string) (crypto.PrivateKey, error) {
+	// fail on an unknown key before asking for a passphrase
+	if _, err := keystore(cmd).Get(nameOrAddress); err != nil {
+		return crypto.PrivateKey{}, err
+	}
+	passphrase, err := readPassphrase(cmd, false)
+	if err != nil {
+		return crypto.PrivateKey{}, err
+	}
+	return keystore(cmd).Unlock(nameOrAddress, passphrase)
+}
+
+func keyTypeFlag(cmd *cobra.Command) (crypto.CurveType, error) {
+	keyType, _ := cmd.Flags().GetString("key-type")
+	curveType, err := crypto.CurveTypeFromString(keyType)
+	if err != nil || curveType == crypto.CurveTypeUnset {
+		return crypto.CurveTypeUnset, errors.Errorf("--key-type must be ed25519 or secp256k1, not %q", keyType)
+	}
+	return curveType, nil
+}
+
+// readPassphrase of a key, confirm asks twice when the passphrase is prompted for
+func readPassphrase(cmd *cobra.Command, confirm bool) (string, error) {
+	if file, _ := cmd.Flags().GetString("passphrase-file"); file != "" {
+		bs, err := os.ReadFile(file)
+		if err != nil {
+			return "", errors.Wrap(err, "could not read passphrase")
+		}
+		return strings.TrimRight(string(bs), "\r\n"), nil
+	}
+	if passphrase, ok := os.LookupEnv(passphraseEnv); ok {
+		return passphrase, nil
+	}
+	passphrase, err := prompt("Passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if confirm {
+		again, err := prompt("Repeat passphrase: ")
+		if err != nil {
+			return "", err
+		}
+		if again != passphrase {
+			return "", errors.New("passphrases do not match")
+		}
+	}
+	return passphrase, nil
+}
+
+var stdin = bufio.NewReader(os.Stdin)
+
+// prompt for a secret, which is not echoed when stdin is a terminal
+func prompt(msg string) (string, error) {
+	fmt.Fprint(os.Stderr, msg)
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		secret, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", errors.Wrap(err, "could not read from terminal")
+		}
+		return string(secret), nil
+	}
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return "", errors.Wrap(err, "could not read from stdin")
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func optionalArg(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	return args[0]
+}
+
+func printKey(key *keys.Key) {
+	if key.Name != "" {
+		fmt.Println("name:      ", key.Name)
+	}
+	fmt.Println("address:   ", key.Address)
+	fmt.Println("public key:", key.PublicKey)
+}
+
+func defaultKeystore() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "keystore"
+	}
+	return filepath.Join(home, ".yaoguang", "keystore")
+}
+
+func init() {
+	CmdKeys.PersistentFlags().String("keystore", defaultKeystore(), "directory of the keystore")
+	CmdKeys.PersistentFlags().String("passphrase-file", "", "file holding the passphrase of the key")
+	for _, cmd := range []*cobra.Command{cmdKeysGenerate, cmdKeysImport} {
+		cmd.Flags().String("key-type", "ed25519", "curve of the key, ed25519 or secp256k1")
+	}
+	cmdKeysImport.Flags().String("private-key-file", "", "file holding the hex encoded private key")
+	CmdKeys.AddCommand(cmdKeysGenerate, cmdKeysList, cmdKeysShow, cmdKeysImport, cmdKeysExport, cmdKeysDelete,
+		cmdKeysSign)
+}
@@ -0,0 +1,387 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	ics23 "github.com/confio/ics23/go"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/sunvim/yaoguang/abci"
+	"github.com/sunvim/yaoguang/crypto"
+	"github.com/sunvim/yaoguang/names"
+	abciTypes "github.com/tendermint/tendermint/abci/types"
+	tmbytes "github.com/tendermint/tendermint/libs/bytes"
+	rpcclient "github.com/tendermint/tendermint/rpc/client"
+	hex "github.com/tmthrgd/go-hex"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+
+	// How long to wait for the block that commits to the state of a proven query
+	proofBlockTimeout = 10 * time.Second
+	proofBlockPoll    = 250 * time.Millisecond
+)
+
+var CmdQuery = &cobra.Command{
+	Use:   "query",
+	Short: "read the state of the chain from a node",
+	Long: `query reads the state committed at --height, the last block by default. With --prove the
+node returns a merkle proof that is checked against the app hash in the header of the next block.`,
+}
+
+// queryResult is what the query commands print with --output json
+type queryResult struct {
+	Height int64        `json:"height"`
+	Value  interface{}  `json:"value"`
+	Proof  *proofResult `json:"proof,omitempty"`
+}
+
+type proofResult struct {
+	Verified bool `json:"verified"`
+	// Block whose header commits to the state at the query height
+	Block   int64            `json:"block,omitempty"`
+	AppHash tmbytes.HexBytes `json:"app_hash,omitempty"`
+	Error   string           `json:"error,omitempty"`
+}
+
+var cmdQueryAccount = &cobra.Command{
+	Use:   "account <address>",
+	Short: "show the balance, sequence and permissions of an account",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		address, err := crypto.AddressFromHexString(args[0])
+		if err != nil {
+			return errors.Wrap(err, "invalid address")
+		}
+		cmd.SilenceUsage = true
+		path := "/accounts/" + address.String()
+		return runQuery(cmd, path, nil, func(value []byte) (interface{}, [][]string, error) {
+			acc := new(struct {
+				Address     crypto.Address    `json:"address"`
+				PublicKey   *crypto.PublicKey `json:"public_key,omitempty"`
+				Sequence    uint64            `json:"sequence"`
+				Balance     uint64            `json:"balance"`
+				Permissions json.RawMessage   `json:"permissions"`
+			})
+			if err := json.Unmarshal(value, acc); err != nil {
+				return nil, nil, errors.Wrap(err, "could not decode account")
+			}
+			publicKey := ""
+			if acc.PublicKey.IsSet() {
+				publicKey = acc.PublicKey.String()
+			}
+			return acc, [][]string{
+				{"ADDRESS", acc.Address.String()},
+				{"PUBLIC KEY", publicKey},
+				{"SEQUENCE", strconv.FormatUint(acc.Sequence, 10)},
+				{"BALANCE", strconv.FormatUint(acc.Balance, 10)},
+				{"PERMISSIONS", string(acc.Permissions)},
+			}, nil
+		})
+	},
+}
+
+var cmdQueryStorage = &cobra.Command{
+	Use:   "storage <hex key>",
+	Short: "show the raw value of a state key",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key, err := hex.DecodeString(args[0])
+		if err != nil {
+			return errors.Wrap(err, "key is not hex")
+		}
+		cmd.SilenceUsage = true
+		return runQuery(cmd, "/store", key, func(value []byte) (interface{}, [][]string, error) {
+			return tmbytes.HexBytes(value), [][]string{
+				{"KEY", hex.EncodeUpperToString(key)},
+				{"VALUE", hex.EncodeUpperToString(value)},
+			}, nil
+		})
+	},
+}
+
+var cmdQueryName = &cobra.Command{
+	Use:   "name <name>",
+	Short: "show the owner, data and lease of a registered name",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return runQuery(cmd, "/names/"+args[0], nil, func(value []byte) (interface{}, [][]string, error) {
+			entry, err := names.Decode(value)
+			if err != nil {
+				return nil, nil, err
+			}
+			return entry, [][]string{
+				{"NAME", entry.Name},
+				{"OWNER", entry.Owner.String()},
+				{"DATA", entry.Data},
+				{"EXPIRES", strconv.FormatUint(entry.Expires, 10)},
+			}, nil
+		})
+	},
+}
+
+var cmdQueryValidators = &cobra.Command{
+	Use:   "validators",
+	Short: "list the validators and their power",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := rejectProve(cmd); err != nil {
+			return err
+		}
+		cmd.SilenceUsage = true
+		return runQuery(cmd, "/validators", nil, func(value []byte) (interface{}, [][]string, error) {
+			var entries []abci.ValidatorEntry
+			if err := json.Unmarshal(value, &entries); err != nil {
+				return nil, nil, errors.Wrap(err, "could not decode validators")
+			}
+			rows := [][]string{{"ADDRESS", "POWER", "PUBLIC KEY"}}
+			for _, entry := range entries {
+				rows = append(rows, []string{entry.Address.String(), entry.Power.String(), entry.PublicKey.String()})
+			}
+			return entries, rows, nil
+		})
+	},
+}
+
+var cmdQueryUpgrade = &cobra.Command{
+	Use:   "upgrade",
+	Short: "show the application protocol version and the scheduled upgrade",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := rejectProve(cmd); err != nil {
+			return err
+		}
+		cmd.SilenceUsage = true
+		return runQuery(cmd, "/upgrade", nil, func(value []byte) (interface{}, [][]string, error) {
+			status := new(abci.UpgradeStatus)
+			if err := json.Unmarshal(value, status); err != nil {
+				return nil, nil, errors.Wrap(err, "could not decode upgrade")
+			}
+			rows := [][]string{{"APP VERSION", strconv.FormatUint(status.AppVersion, 10)}}
+			if plan := status.Plan; plan != nil {
+				rows = append(rows,
+					[]string{"UPGRADE", plan.Name},
+					[]string{"UPGRADE HEIGHT", strconv.FormatUint(plan.Height, 10)},
+					[]string{"UPGRADE INFO", plan.Info})
+			} else {
+				rows = append(rows, []string{"UPGRADE", "none scheduled"})
+			}
+			return status, rows, nil
+		})
+	},
+}
+
+var cmdQueryBlock = &cobra.Command{
+	Use:   "block [height]",
+	Short: "show the header of a block, the last one by default",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		height, _ := cmd.Flags().GetInt64("height")
+		if len(args) == 1 {
+			var err error
+			if height, err = strconv.ParseInt(args[0], 10, 64); err != nil {
+				return errors.Errorf("invalid height %q", args[0])
+			}
+		}
+		cmd.SilenceUsage = true
+		client, err := rpcClient(cmd)
+		if err != nil {
+			return err
+		}
+		var heightPtr *int64
+		if height > 0 {
+			heightPtr = &height
+		}
+		result, err := client.Block(context.Background(), heightPtr)
+		if err != nil {
+			return errors.Wrap(err, "could not get block")
+		}
+		header := result.Block.Header
+		block := struct {
+			ChainID         string           `json:"chain_id"`
+			Height          int64            `json:"height"`
+			Hash            tmbytes.HexBytes `json:"hash"`
+			Time            time.Time        `json:"time"`
+			Proposer        tmbytes.HexBytes `json:"proposer"`
+			NumTxs          int              `json:"num_txs"`
+			AppHash         tmbytes.HexBytes `json:"app_hash"`
+			ValidatorsHash  tmbytes.HexBytes `json:"validators_hash"`
+			LastResultsHash tmbytes.HexBytes `json:"last_results_hash"`
+		}{
+			ChainID:         header.ChainID,
+			Height:          header.Height,
+			Hash:            result.BlockID.Hash,
+			Time:            header.Time,
+			Proposer:        tmbytes.HexBytes(header.ProposerAddress),
+			NumTxs:          len(result.Block.Txs),
+			AppHash:         header.AppHash,
+			ValidatorsHash:  header.ValidatorsHash,
+			LastResultsHash: header.LastResultsHash,
+		}
+		if output, _ := cmd.Flags().GetString("output"); output == outputJSON {
+			return printJSON(block)
+		}
+		return printTable([][]string{
+			{"CHAIN ID", block.ChainID},
+			{"HEIGHT", strconv.FormatInt(block.Height, 10)},
+			{"HASH", block.Hash.String()},
+			{"TIME", block.Time.Format(time.RFC3339Nano)},
+			{"PROPOSER", block.Proposer.String()},
+			{"TXS", strconv.Itoa(block.NumTxs)},
+			{"APP HASH", block.AppHash.String()},
+			{"VALIDATORS HASH", block.ValidatorsHash.String()},
+			{"LAST RESULTS HASH", block.LastResultsHash.String()},
+		})
+	},
+}
+
+// rejectProve fails a query of a value read from several keys of the state, the node can only prove a single key
+func rejectProve(cmd *cobra.Command) error {
+	if prove, _ := cmd.Flags().GetBool("prove"); prove {
+		return errors.Errorf("%s cannot be proven as it is read from several state keys", cmd.CommandPath())
+	}
+	return nil
+}
+
+// decodeValue turns the value of a query into what is printed as JSON and as table rows
+type decodeValue func(value []byte) (interface{}, [][]string, error)
+
+func runQuery(cmd *cobra.Command, path string, data []byte, decode decodeValue) error {
+	output, _ := cmd.Flags().GetString("output")
+	if output != outputTable && output != outputJSON {
+		return errors.Errorf("--output must be %s or %s", outputTable, outputJSON)
+	}
+	height, _ := cmd.Flags().GetInt64("height")
+	prove, _ := cmd.Flags().GetBool("prove")
+	client, err := rpcClient(cmd)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	rsp, err := abciQuery(ctx, client, path, data, height, prove)
+	if err != nil {
+		return err
+	}
+	value, rows, err := decode(rsp.Value)
+	if err != nil {
+		return err
+	}
+	result := &queryResult{
+		Height: rsp.Height,
+		Value:  value,
+	}
+	if prove {
+		result.Proof = verifyProof(ctx, client, rsp)
+	}
+
+	if output == outputJSON {
+		if err := printJSON(result); err != nil {
+			return err
+		}
+	} else {
+		rows = append(rows, []string{""}, []string{"HEIGHT", strconv.FormatInt(result.Height, 10)})
+		if proof := result.Proof; proof != nil {
+			if proof.Verified {
+				rows = append(rows, []string{"PROOF", fmt.Sprintf("verified against app hash %v of block %d",
+					proof.AppHash, proof.Block)})
+			} else {
+				rows = append(rows, []string{"PROOF", "not verified: " + proof.Error})
+			}
+		}
+		if err := printTable(rows); err != nil {
+			return err
+		}
+	}
+	if result.Proof != nil && !result.Proof.Verified {
+		return errors.New("proof could not be verified")
+	}
+	return nil
+}
+
+// verifyProof checks the proof of a query against the app hash of the block after the query height, which is
+// the first to commit to the state at that height
+func verifyProof(ctx context.Context, client rpcclient.SignClient, rsp *abciTypes.ResponseQuery) *proofResult {
+	result := &proofResult{
+		Block: rsp.Height + 1,
+	}
+	if rsp.ProofOps == nil || len(rsp.ProofOps.Ops) != 1 || rsp.ProofOps.Ops[0].Type != abci.ProofOpIAVLCommitment {
+		result.Error = "the node returned no proof"
+		return result
+	}
+	op := rsp.ProofOps.Ops[0]
+	proof := new(ics23.CommitmentProof)
+	if err := proof.Unmarshal(op.Data); err != nil {
+		result.Error = fmt.Sprintf("could not decode proof: %v", err)
+		return result
+	}
+	block, err := client.Block(ctx, &result.Block)
+	// a proof of the latest state can only be checked once the next block is committed
+	for deadline := time.Now().Add(proofBlockTimeout); err != nil && time.Now().Before(deadline); {
+		time.Sleep(proofBlockPoll)
+		block, err = client.Block(ctx, &result.Block)
+	}
+	if err != nil {
+		result.Error = fmt.Sprintf("block %d is not available: %v", result.Block, err)
+		return result
+	}
+	result.AppHash = block.Block.Header.AppHash
+	if len(rsp.Value) == 0 {
+		result.Verified = ics23.VerifyNonMembership(ics23.IavlSpec, []byte(result.AppHash), proof, op.Key)
+	} else {
+		result.Verified = ics23.VerifyMembership(ics23.IavlSpec, []byte(result.AppHash), proof, op.Key, rsp.Value)
+	}
+	if !result.Verified {
+		result.Error = fmt.Sprintf("proof does not match app hash %v", result.AppHash)
+	}
+	return result
+}
+
+func printTable(rows [][]string) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, row := range rows {
+		for i, cell := range row {
+			if i > 0 {
+				fmt.Fprint(w, "\t")
+			}
+			fmt.Fprint(w, cell)
+		}
+		fmt.Fprintln(w)
+	}
+	return w.Flush()
+}
+
+func init() {
+	addNodeFlag(CmdQuery)
+	CmdQuery.PersistentFlags().Int64("height", 0, "height of the state to read, the last block by default")
+	CmdQuery.PersistentFlags().Bool("prove", false,
+		"request a merkle proof of the value and verify it, not for validators or upgrade")
+	CmdQuery.PersistentFlags().StringP("output", "o", outputTable, "output format, table or json")
+	CmdQuery.AddCommand(cmdQueryAccount, cmdQueryStorage, cmdQueryName, cmdQueryValidators, cmdQueryUpgrade,
+		cmdQueryBlock)
+}
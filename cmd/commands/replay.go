@@ -0,0 +1,75 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+	"github.com/sunvim/yaoguang/core"
+	"github.com/sunvim/yaoguang/share"
+)
+
+var CmdReplay = &cobra.Command{
+	Use:   "replay",
+	Short: "re-execute the stored blocks of a stopped node to check they reproduce its state",
+	Long: `replay runs the blocks --from to --to of the block store through the application against a fresh
+copy of the state committed before --from, or from genesis, and compares the app hash after each block and
+the result of each transaction with those the chain recorded. It reports the first height and transaction
+that diverge, so non-determinism or a state migration bug can be tracked down. The node must be stopped and
+its databases are not modified.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+
+		config, _ := cmd.Flags().GetString(share.BootConfig)
+		from, _ := cmd.Flags().GetUint64("from")
+		to, _ := cmd.Flags().GetUint64("to")
+		dbDir, _ := cmd.Flags().GetString("db-dir")
+		verbose, _ := cmd.Flags().GetBool("verbose")
+
+		if !verbose {
+			// the application logs every block it executes
+			zerolog.SetGlobalLevel(zerolog.WarnLevel)
+		}
+		result, err := core.Replay(config, from, to, dbDir)
+		if err != nil {
+			return err
+		}
+		bs, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "could not encode replay result")
+		}
+		fmt.Println(string(bs))
+		if result.Divergence != nil {
+			return errors.Errorf("replay diverged at height %d", result.Divergence.Height)
+		}
+		return nil
+	},
+}
+
+func init() {
+	CmdReplay.Flags().StringP(share.BootConfig, "c", "config/config.toml", "configuration of the node to replay")
+	CmdReplay.Flags().Uint64("from", 0, "first height to replay, the initial height of the chain if 0")
+	CmdReplay.Flags().Uint64("to", 0, "last height to replay, the last block committed if 0")
+	CmdReplay.Flags().String("db-dir", "", "empty directory to keep the replayed state in, memory if empty")
+	CmdReplay.Flags().BoolP("verbose", "v", false, "log the execution of each block")
+}
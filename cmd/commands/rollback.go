@@ -0,0 +1,60 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package commands
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/sunvim/yaoguang/core"
+	"github.com/sunvim/yaoguang/share"
+)
+
+var CmdRollback = &cobra.Command{
+	Use:   "rollback",
+	Short: "revert the state of a stopped node to a previous height",
+	Long: `rollback reverts the application state of a stopped node to that committed at --height, deleting the
+state of later blocks, and records that height as the last block. Tendermint's own state is rolled back
+by one block. Tendermint keeps its blocks and replays those after --height through the application on
+the next start, applying the last one again, so a node that ran a bad binary can re-execute them with a
+fixed one. The state at --height must still be retained and the blocks from --height on must be in the
+block store.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+
+		config, _ := cmd.Flags().GetString(share.BootConfig)
+		height, _ := cmd.Flags().GetUint64("height")
+		if height == 0 {
+			return errors.New("--height is required")
+		}
+		appHash, err := core.Rollback(config, height)
+		if err != nil {
+			return err
+		}
+		log.Info().Uint64("height", height).Str("app_hash", fmt.Sprintf("%X", appHash)).Msg("rollback")
+		return nil
+	},
+}
+
+func init() {
+	CmdRollback.Flags().StringP(share.BootConfig, "c", "config/config.toml", "configuration of the node to roll back")
+	CmdRollback.Flags().Uint64("height", 0, "height of the block to roll back to")
+}
@@ -0,0 +1,170 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package commands
+
+import (
+	"net"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/sunvim/utils/grace"
+	"github.com/sunvim/yaoguang/crypto"
+	"github.com/sunvim/yaoguang/keys"
+	"github.com/sunvim/yaoguang/signer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// Environment variable the shared secret is read from when --secret-file is not given
+const signerSecretEnv = "YAOGUANG_SIGNER_SECRET"
+
+var CmdSigner = &cobra.Command{
+	Use:   "signer",
+	Short: "serve signatures by keystore keys over gRPC",
+	Long: `signer unlocks keys of the keystore and signs with them for remote clients, so the keys
+never leave this host. Clients authenticate with a certificate signed by --tls-ca, with the
+secret in --secret-file or $` + signerSecretEnv + `, or both. Without TLS a secret may only be used
+on a loopback address.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+
+		listen, _ := cmd.Flags().GetString("listen")
+		names, _ := cmd.Flags().GetStringSlice("key")
+		certFile, _ := cmd.Flags().GetString("tls-cert")
+		keyFile, _ := cmd.Flags().GetString("tls-key")
+		caFile, _ := cmd.Flags().GetString("tls-ca")
+
+		secret, err := readSecret(cmd, "secret-file")
+		if err != nil {
+			return err
+		}
+		var opts []grpc.ServerOption
+		if certFile != "" {
+			config, err := signer.ServerTLSConfig(certFile, keyFile, caFile)
+			if err != nil {
+				return err
+			}
+			opts = append(opts, grpc.Creds(credentials.NewTLS(config)))
+		} else if caFile != "" {
+			return errors.New("--tls-ca requires --tls-cert and --tls-key")
+		}
+		if secret != "" {
+			if certFile == "" && !isLoopback(listen) {
+				return errors.Errorf("refusing to accept a secret in the clear on %s, use --tls-cert", listen)
+			}
+			opts = append(opts, grpc.UnaryInterceptor(signer.SecretInterceptor(secret)))
+		} else if caFile == "" {
+			return errors.Errorf("clients must be authenticated with --tls-ca or a secret in --secret-file or $%s",
+				signerSecretEnv)
+		}
+
+		ks := keystore(cmd)
+		var served []*keys.Key
+		if len(names) == 0 {
+			if served, err = ks.List(); err != nil {
+				return err
+			}
+		}
+		for _, name := range names {
+			key, err := ks.Get(name)
+			if err != nil {
+				return err
+			}
+			served = append(served, key)
+		}
+		if len(served) == 0 {
+			return errors.New("there are no keys to serve")
+		}
+		passphrase, err := readPassphrase(cmd, false)
+		if err != nil {
+			return err
+		}
+		privateKeys := make([]crypto.PrivateKey, len(served))
+		for i, key := range served {
+			if privateKeys[i], err = ks.Unlock(key.Address.String(), passphrase); err != nil {
+				return errors.Wrapf(err, "could not unlock %v", key.Address)
+			}
+		}
+
+		listener, err := net.Listen("tcp", listen)
+		if err != nil {
+			return errors.Wrapf(err, "could not listen on %s", listen)
+		}
+		server := grpc.NewServer(opts...)
+		signer.RegisterSignerServer(server, signer.NewServer(served, privateKeys...))
+
+		_, srv := grace.New(cmd.Context())
+		srv.Register(func() error {
+			log.Info().Str("service", "stopping").Msg("signer")
+			server.GracefulStop()
+			return nil
+		})
+		go func() {
+			if err := server.Serve(listener); err != nil {
+				log.Error().Err(err).Msg("signer")
+			}
+		}()
+		for _, key := range served {
+			log.Info().Str("address", key.Address.String()).Str("name", key.Name).Msg("signer")
+		}
+		log.Info().Str("listen", listener.Addr().String()).Bool("tls", certFile != "").
+			Bool("secret", secret != "").Msg("signer")
+
+		srv.Wait()
+		return nil
+	},
+}
+
+// readSecret from the file named by flag or else from the environment
+func readSecret(cmd *cobra.Command, flag string) (string, error) {
+	if file, _ := cmd.Flags().GetString(flag); file != "" {
+		bs, err := os.ReadFile(file)
+		if err != nil {
+			return "", errors.Wrap(err, "could not read secret")
+		}
+		return strings.TrimSpace(string(bs)), nil
+	}
+	return os.Getenv(signerSecretEnv), nil
+}
+
+func isLoopback(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func init() {
+	CmdSigner.Flags().String("keystore", defaultKeystore(), "directory of the keystore")
+	CmdSigner.Flags().String("passphrase-file", "", "file holding the passphrase of the keys")
+	CmdSigner.Flags().StringSlice("key", nil, "names or addresses of the keys to serve, all keys by default")
+	CmdSigner.Flags().String("listen", "127.0.0.1:26680", "address to serve on")
+	CmdSigner.Flags().String("tls-cert", "", "certificate to serve TLS with")
+	CmdSigner.Flags().String("tls-key", "", "private key of --tls-cert")
+	CmdSigner.Flags().String("tls-ca", "", "CA certificate that client certificates must be signed by")
+	CmdSigner.Flags().String("secret-file", "", "file holding the secret clients must present")
+}
@@ -18,6 +18,9 @@
 package commands
 
 import (
+	"context"
+	"time"
+
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -30,7 +33,9 @@ var CmdStart = &cobra.Command{
 	Use:     "start",
 	Aliases: []string{"node", "run"},
 	Short:   "boot this service",
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// errors from here on are not usage errors
+		cmd.SilenceUsage = true
 
 		_, srv := grace.New(cmd.Context())
 
@@ -38,22 +43,61 @@ var CmdStart = &cobra.Command{
 		config, _ := cmd.Flags().GetString(share.BootConfig)
 		log.Info().Str("config", config).Msg("start")
 		nodeInfo, _ := cmd.Flags().GetString(share.BootNodeInfo)
-		kern := core.NewKern(nodeInfo, config)
-		if err := kern.Boot(); err != nil {
-			log.Fatal().Err(err).Msg("start")
+		kern, err := core.NewKern(nodeInfo, config)
+		if err != nil {
+			return err
 		}
-
-		srv.Wait()
+		if err := boot(kern); err != nil {
+			return err
+		}
+		return serve(srv, kern)
 	},
 }
 
+// boot the kernel, releasing whatever it opened if it fails to start
+func boot(kern *core.Kern) error {
+	if err := kern.Boot(); err != nil {
+		if serr := shutdown(kern)(); serr != nil {
+			log.Error().Err(serr).Msg("shutdown")
+		}
+		return err
+	}
+	return nil
+}
+
+// serve kern until a signal shuts it down, returning the error of the shutdown as grace only logs it
+func serve(srv grace.Service, kern *core.Kern) error {
+	var err error
+	srv.Register(func() error {
+		err = shutdown(kern)()
+		return nil
+	})
+	srv.Wait()
+	return err
+}
+
+// How long to wait for a block to finish committing on SIGINT or SIGTERM
+const shutdownTimeout = 30 * time.Second
+
+func shutdown(kern *core.Kern) func() error {
+	return func() error {
+		log.Info().Str("service", "stopping").Msg("shutdown")
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		return kern.Shutdown(ctx)
+	}
+}
+
 func init() {
 
 	CmdStart.PersistentFlags().StringP(share.BootConfig, "c", "config/config.toml", "configuration for this service")
 	CmdStart.PersistentFlags().StringP(share.BootNodeInfo, "", "dev", "node name or id")
+	CmdStart.PersistentFlags().Bool("external-abci", false,
+		"connect to an application served by yaoguang abci instead of running it in-process")
 
 	//bind flag
 	viper.BindPFlag(share.BootConfig, CmdStart.Flags().Lookup(share.BootConfig))
 	viper.BindPFlag(share.BootNodeInfo, CmdStart.Flags().Lookup(share.BootNodeInfo))
+	viper.BindPFlag(share.ExternalABCI, CmdStart.PersistentFlags().Lookup("external-abci"))
 
 }
@@ -0,0 +1,215 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/sunvim/yaoguang/binary"
+	"github.com/sunvim/yaoguang/core"
+	"github.com/sunvim/yaoguang/crypto"
+	"github.com/sunvim/yaoguang/genesis"
+)
+
+// Ports used by each node are allocated from --starting-port in blocks of portsPerNode
+const portsPerNode = 10
+
+// testnetAccount is an entry of the accounts.json written next to the node homes. The keys are in the clear, they
+// are only meant for testing.
+type testnetAccount struct {
+	Address    crypto.Address    `json:"address"`
+	PublicKey  *crypto.PublicKey `json:"public_key"`
+	PrivateKey binary.HexBytes   `json:"private_key"`
+	CurveType  string            `json:"curve_type"`
+	Balance    uint64            `json:"balance"`
+}
+
+var CmdTestnet = &cobra.Command{
+	Use:   "testnet",
+	Short: "create the homes of a local network of validators",
+	Long: `testnet writes a node home for each of --validators validators under --output sharing one genesis,
+with each node listening on its own ports and peered with all the others. The genesis funds the
+validators and --accounts further accounts, whose keys are written to accounts.json. start.sh
+starts every node in the background.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+
+		output, _ := cmd.Flags().GetString("output")
+		numValidators, _ := cmd.Flags().GetInt("validators")
+		numAccounts, _ := cmd.Flags().GetInt("accounts")
+		chainID, _ := cmd.Flags().GetString("chain-id")
+		keyType, _ := cmd.Flags().GetString("key-type")
+		balance, _ := cmd.Flags().GetUint64("balance")
+		hostname, _ := cmd.Flags().GetString("hostname")
+		startingPort, _ := cmd.Flags().GetInt("starting-port")
+
+		curveType, err := crypto.CurveTypeFromString(keyType)
+		if err != nil || curveType == crypto.CurveTypeUnset {
+			return errors.Errorf("--key-type must be ed25519 or secp256k1, not %q", keyType)
+		}
+		if chainID == "" {
+			return errors.New("--chain-id must not be empty")
+		}
+		if numValidators < 1 {
+			return errors.New("--validators must be at least 1")
+		}
+		if numAccounts < 0 {
+			return errors.New("--accounts must not be negative")
+		}
+		if lastPort := startingPort + numValidators*portsPerNode; startingPort < 1 || lastPort > 65535 {
+			return errors.Errorf("ports %d to %d are out of range, lower --starting-port or --validators",
+				startingPort, lastPort)
+		}
+
+		homes := make([]*core.Home, numValidators)
+		for i := range homes {
+			config := core.HomeConfig(filepath.Join(output, nodeDir(i)), nodeDir(i))
+			port := startingPort + i*portsPerNode
+			config.P2P.ListenAddress = fmt.Sprintf("tcp://0.0.0.0:%d", port)
+			config.P2P.ExternalAddress = fmt.Sprintf("%s:%d", hostname, port)
+			config.RPC.ListenAddress = fmt.Sprintf("tcp://127.0.0.1:%d", port+1)
+			config.ProxyApp = fmt.Sprintf("tcp://127.0.0.1:%d", port+2)
+			config.Instrumentation.PrometheusListenAddr = fmt.Sprintf(":%d", port+3)
+			// every node shares the address of this machine
+			config.P2P.AllowDuplicateIP = true
+			config.P2P.AddrBookStrict = false
+			homes[i], err = core.InitHome(config, curveType)
+			if err != nil {
+				return err
+			}
+		}
+
+		// peers can only be wired together once every node key exists
+		for i, home := range homes {
+			var peers []string
+			for j, peer := range homes {
+				if i != j {
+					peers = append(peers, fmt.Sprintf("%s@%s", peer.NodeID, peer.Config.P2P.ExternalAddress))
+				}
+			}
+			home.Config.P2P.PersistentPeers = strings.Join(peers, ",")
+			if err := core.WriteConfig(home.Config); err != nil {
+				return err
+			}
+		}
+
+		appState := new(genesis.AppState)
+		for _, home := range homes {
+			appState.Accounts = append(appState.Accounts, genesis.Account{
+				Address:   home.Validator.GetAddress(),
+				PublicKey: home.Validator,
+				Balance:   balance,
+			})
+		}
+		accounts := make([]testnetAccount, numAccounts)
+		for i := range accounts {
+			privateKey, err := crypto.GeneratePrivateKey(nil, curveType)
+			if err != nil {
+				return errors.Wrap(err, "could not generate account key")
+			}
+			accounts[i] = testnetAccount{
+				Address:    privateKey.GetPublicKey().GetAddress(),
+				PublicKey:  privateKey.GetPublicKey(),
+				PrivateKey: privateKey.RawBytes(),
+				CurveType:  curveType.String(),
+				Balance:    balance,
+			}
+			appState.Accounts = append(appState.Accounts, genesis.Account{
+				Address:   accounts[i].Address,
+				PublicKey: accounts[i].PublicKey,
+				Balance:   balance,
+			})
+		}
+
+		genesisDoc, err := core.NewGenesisDoc(chainID, homes, appState)
+		if err != nil {
+			return err
+		}
+		for _, home := range homes {
+			if err := genesisDoc.SaveAs(home.Config.GenesisFile()); err != nil {
+				return errors.Wrap(err, "could not write genesis file")
+			}
+		}
+		if err := writeTestnetAccounts(filepath.Join(output, "accounts.json"), accounts); err != nil {
+			return err
+		}
+		if err := writeTestnetScript(filepath.Join(output, "start.sh"), numValidators); err != nil {
+			return err
+		}
+
+		for _, home := range homes {
+			log.Info().Str("home", home.Config.RootDir).Str("node-id", string(home.NodeID)).
+				Str("p2p", home.Config.P2P.ExternalAddress).Str("rpc", home.Config.RPC.ListenAddress).
+				Msg("testnet")
+		}
+		fmt.Printf("start the network with: sh %s\n", filepath.Join(output, "start.sh"))
+		return nil
+	},
+}
+
+func nodeDir(i int) string {
+	return fmt.Sprintf("node%d", i)
+}
+
+func writeTestnetAccounts(file string, accounts []testnetAccount) error {
+	bs, err := json.MarshalIndent(accounts, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "could not encode accounts")
+	}
+	if err := os.WriteFile(file, bs, 0600); err != nil {
+		return errors.Wrapf(err, "could not write %s", file)
+	}
+	return nil
+}
+
+// writeTestnetScript writes a script that starts every node with its log in its home and stops them all on exit
+func writeTestnetScript(file string, numValidators int) error {
+	script := new(strings.Builder)
+	script.WriteString("#!/bin/sh\n")
+	script.WriteString("cd \"$(dirname \"$0\")\" || exit 1\n")
+	script.WriteString("trap 'kill 0' INT TERM EXIT\n")
+	for i := 0; i < numValidators; i++ {
+		fmt.Fprintf(script, "${YAOGUANG:-yaoguang} start --config %s/config/config.toml > %s/node.log 2>&1 &\n",
+			nodeDir(i), nodeDir(i))
+	}
+	script.WriteString("wait\n")
+	if err := os.WriteFile(file, []byte(script.String()), 0755); err != nil {
+		return errors.Wrapf(err, "could not write %s", file)
+	}
+	return nil
+}
+
+func init() {
+	CmdTestnet.Flags().StringP("output", "o", "testnet", "directory to create the node homes in")
+	CmdTestnet.Flags().IntP("validators", "n", 4, "number of validator nodes")
+	CmdTestnet.Flags().Int("accounts", 4, "number of funded accounts besides the validators'")
+	CmdTestnet.Flags().String("chain-id", "yaoguang-testnet", "chain ID of the genesis")
+	CmdTestnet.Flags().String("key-type", "ed25519", "curve of the validator and account keys, ed25519 or secp256k1")
+	CmdTestnet.Flags().Uint64("balance", 1000000000, "genesis balance of each account")
+	CmdTestnet.Flags().String("hostname", "127.0.0.1", "address the nodes reach each other on")
+	CmdTestnet.Flags().Int("starting-port", 26656, fmt.Sprintf("first port of node0, each node uses %d ports",
+		portsPerNode))
+}
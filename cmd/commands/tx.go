@@ -0,0 +1,486 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/sunvim/yaoguang/abci"
+	"github.com/sunvim/yaoguang/binary"
+	"github.com/sunvim/yaoguang/crypto"
+	"github.com/sunvim/yaoguang/permission"
+	"github.com/sunvim/yaoguang/signer"
+	"github.com/sunvim/yaoguang/txs"
+	"github.com/sunvim/yaoguang/txs/payload"
+	abciTypes "github.com/tendermint/tendermint/abci/types"
+	tmbytes "github.com/tendermint/tendermint/libs/bytes"
+	rpcclient "github.com/tendermint/tendermint/rpc/client"
+	"github.com/tendermint/tendermint/types"
+	hex "github.com/tmthrgd/go-hex"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Ways of handing a transaction to the node, see the broadcast_tx_* Tendermint RPC methods
+const (
+	broadcastAsync  = "async"
+	broadcastSync   = "sync"
+	broadcastCommit = "commit"
+)
+
+var CmdTx = &cobra.Command{
+	Use:   "tx",
+	Short: "build, sign and broadcast transactions",
+	Long: `tx signs with the --from key of the keystore, or of the remote signer at --signer, and
+broadcasts to --node. The sequence of the --from account is read from the node unless given.`,
+}
+
+// txResult is what the tx commands print
+type txResult struct {
+	// Tendermint hash of the encoded transaction, to look it up through the node
+	Hash tmbytes.HexBytes `json:"hash"`
+	// Hash of the signed part of the transaction, identifies it to the app
+	TxHash    binary.HexBytes   `json:"tx_hash"`
+	Height    int64             `json:"height,omitempty"`
+	Code      uint32            `json:"code"`
+	Codespace string            `json:"codespace,omitempty"`
+	Log       string            `json:"log,omitempty"`
+	GasUsed   int64             `json:"gas_used,omitempty"`
+	Data      tmbytes.HexBytes  `json:"data,omitempty"`
+	Events    []abciTypes.Event `json:"events,omitempty"`
+}
+
+// buildPayload returns the payload to send from the --from account with its next sequence
+type buildPayload func(from crypto.Address, sequence uint64) (payload.Payload, error)
+
+var cmdTxSend = &cobra.Command{
+	Use:   "send <address> <amount>",
+	Short: "send tokens to an account",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		to, err := crypto.AddressFromHexString(args[0])
+		if err != nil {
+			return errors.Wrap(err, "invalid recipient")
+		}
+		amount, err := parseAmount(args[1])
+		if err != nil {
+			return err
+		}
+		return broadcastTx(cmd, func(from crypto.Address, sequence uint64) (payload.Payload, error) {
+			tx := payload.NewSendTx()
+			tx.AddInput(from, amount, sequence)
+			tx.AddOutput(to, amount)
+			return tx, nil
+		})
+	},
+}
+
+var cmdTxName = &cobra.Command{
+	Use:   "name <name> <amount>",
+	Short: "register, update, transfer or release a name, amount pays for the lease",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		amount, err := parseAmount(args[1])
+		if err != nil {
+			return err
+		}
+		data, _ := cmd.Flags().GetString("data")
+		release, _ := cmd.Flags().GetBool("release")
+		var newOwner *crypto.Address
+		if owner, _ := cmd.Flags().GetString("new-owner"); owner != "" {
+			address, err := crypto.AddressFromHexString(owner)
+			if err != nil {
+				return errors.Wrap(err, "invalid --new-owner")
+			}
+			newOwner = &address
+		}
+		return broadcastTx(cmd, func(from crypto.Address, sequence uint64) (payload.Payload, error) {
+			tx := payload.NewNameTx(from, amount, sequence, args[0], data)
+			tx.NewOwner = newOwner
+			tx.Release = release
+			return tx, nil
+		})
+	},
+}
+
+var cmdTxBond = &cobra.Command{
+	Use:   "bond <amount>",
+	Short: "bond tokens to a validator, by default the --from account's own key",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		amount, err := parseAmount(args[0])
+		if err != nil {
+			return err
+		}
+		var validator *crypto.PublicKey
+		if key, _ := cmd.Flags().GetString("validator"); key != "" {
+			curveType, err := keyTypeFlag(cmd)
+			if err != nil {
+				return err
+			}
+			bs, err := hex.DecodeString(key)
+			if err != nil {
+				return errors.Wrap(err, "--validator is not a hex public key")
+			}
+			if validator, err = crypto.PublicKeyFromBytes(bs, curveType); err != nil {
+				return errors.Wrap(err, "invalid --validator")
+			}
+		}
+		return broadcastTx(cmd, func(from crypto.Address, sequence uint64) (payload.Payload, error) {
+			return payload.NewBondTx(from, amount, sequence, validator), nil
+		})
+	},
+}
+
+var cmdTxUnbond = &cobra.Command{
+	Use:   "unbond <validator address> <amount>",
+	Short: "unbond tokens from a validator, they are released after the unbonding period",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		validator, err := crypto.AddressFromHexString(args[0])
+		if err != nil {
+			return errors.Wrap(err, "invalid validator")
+		}
+		amount, err := parseAmount(args[1])
+		if err != nil {
+			return err
+		}
+		return broadcastTx(cmd, func(from crypto.Address, sequence uint64) (payload.Payload, error) {
+			return payload.NewUnbondTx(from, amount, sequence, validator), nil
+		})
+	},
+}
+
+var cmdTxPermissions = &cobra.Command{
+	Use:   "permissions <set_base|unset_base|add_role|remove_role>",
+	Short: "change the permissions or roles of --target, or the global permissions",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		action := permission.Action(args[0])
+		if err := action.Validate(); err != nil {
+			return err
+		}
+		var target *crypto.Address
+		if t, _ := cmd.Flags().GetString("target"); t != "" {
+			address, err := crypto.AddressFromHexString(t)
+			if err != nil {
+				return errors.Wrap(err, "invalid --target")
+			}
+			target = &address
+		}
+		perms, _ := cmd.Flags().GetStringSlice("permission")
+		flag, err := permission.PermFlagFromStringList(perms)
+		if err != nil {
+			return err
+		}
+		value, _ := cmd.Flags().GetBool("value")
+		role, _ := cmd.Flags().GetString("role")
+		return broadcastTx(cmd, func(from crypto.Address, sequence uint64) (payload.Payload, error) {
+			tx := payload.NewPermissionsTx(from, sequence, target, action)
+			tx.Permission = flag
+			tx.Value = value
+			tx.Role = role
+			return tx, nil
+		})
+	},
+}
+
+var cmdTxPropose = &cobra.Command{
+	Use:   "propose <proposal.json>",
+	Short: "submit a governance proposal, the file holds its name, description and payloads",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		bs, err := os.ReadFile(args[0])
+		if err != nil {
+			return errors.Wrap(err, "could not read proposal")
+		}
+		proposal := new(payload.Proposal)
+		if err := json.Unmarshal(bs, proposal); err != nil {
+			return errors.Wrap(err, "could not decode proposal")
+		}
+		return broadcastTx(cmd, func(from crypto.Address, sequence uint64) (payload.Payload, error) {
+			return payload.NewProposalTx(from, sequence, proposal), nil
+		})
+	},
+}
+
+var cmdTxVote = &cobra.Command{
+	Use:   "vote <proposal hash>",
+	Short: "vote for a pending proposal as a validator",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		hash, err := hex.DecodeString(args[0])
+		if err != nil {
+			return errors.Wrap(err, "proposal hash is not hex")
+		}
+		return broadcastTx(cmd, func(from crypto.Address, sequence uint64) (payload.Payload, error) {
+			return payload.NewVoteTx(from, sequence, hash), nil
+		})
+	},
+}
+
+var cmdTxUpgrade = &cobra.Command{
+	Use:   "upgrade",
+	Short: "schedule an upgrade of the application protocol at --height, or cancel the scheduled one",
+	Long: `upgrade needs root permission. Validators can schedule one together by proposing an UpgradeTx,
+the file given to propose can be printed with --print.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, _ := cmd.Flags().GetString("name")
+		height, _ := cmd.Flags().GetUint64("height")
+		info, _ := cmd.Flags().GetString("info")
+		cancel, _ := cmd.Flags().GetBool("cancel")
+		var plan *payload.UpgradePlan
+		if !cancel {
+			plan = &payload.UpgradePlan{Name: name, Height: height, Info: info}
+			if err := plan.Validate(); err != nil {
+				return err
+			}
+		}
+		if printProposal, _ := cmd.Flags().GetBool("print"); printProposal {
+			title := "cancel upgrade"
+			if plan != nil {
+				title = "upgrade to " + plan.Name
+			}
+			return printJSON(payload.NewProposal(title, "", payload.NewUpgradeTx(crypto.Address{}, 0, plan)))
+		}
+		return broadcastTx(cmd, func(from crypto.Address, sequence uint64) (payload.Payload, error) {
+			return payload.NewUpgradeTx(from, sequence, plan), nil
+		})
+	},
+}
+
+// broadcastTx builds, signs and broadcasts a transaction then prints the result
+func broadcastTx(cmd *cobra.Command, build buildPayload) error {
+	cmd.SilenceUsage = true
+	ctx := context.Background()
+
+	mode, _ := cmd.Flags().GetString("broadcast-mode")
+	if mode != broadcastAsync && mode != broadcastSync && mode != broadcastCommit {
+		return errors.Errorf("--broadcast-mode must be %s, %s or %s", broadcastAsync, broadcastSync, broadcastCommit)
+	}
+	client, err := rpcClient(cmd)
+	if err != nil {
+		return err
+	}
+	txSigner, err := txSigner(cmd)
+	if err != nil {
+		return err
+	}
+	return sendTx(ctx, cmd, client, txSigner, build)
+}
+
+// txClient is the part of the node's RPC that sendTx uses
+type txClient interface {
+	rpcclient.ABCIClient
+	rpcclient.StatusClient
+}
+
+// sendTx builds the transaction from the --from account of txSigner, signs it and hands it to the node through
+// client as the tx flags of cmd ask
+func sendTx(ctx context.Context, cmd *cobra.Command, client txClient, txSigner crypto.AddressableSigner,
+	build buildPayload) error {
+	mode, _ := cmd.Flags().GetString("broadcast-mode")
+	chainID, _ := cmd.Flags().GetString("chain-id")
+	if chainID == "" {
+		status, err := client.Status(ctx)
+		if err != nil {
+			return errors.Wrap(err, "could not get the chain ID from the node")
+		}
+		chainID = status.NodeInfo.Network
+	}
+	sequence, _ := cmd.Flags().GetUint64("sequence")
+	if sequence == 0 {
+		acc, _, err := queryAccount(ctx, client, txSigner.GetAddress(), 0, false)
+		if err != nil {
+			return err
+		}
+		sequence = acc.Sequence + 1
+	}
+
+	p, err := build(txSigner.GetAddress(), sequence)
+	if err != nil {
+		return err
+	}
+	txEnv := txs.Enclose(chainID, p)
+	txEnv.Tx.Fee, _ = cmd.Flags().GetUint64("fee")
+	if err := txEnv.Sign(txSigner); err != nil {
+		return errors.Wrap(err, "could not sign transaction")
+	}
+	tx, err := txs.NewJSONCodec().EncodeTx(txEnv)
+	if err != nil {
+		return errors.Wrap(err, "could not encode transaction")
+	}
+
+	if simulate, _ := cmd.Flags().GetBool("simulate"); simulate {
+		rsp, err := abciQuery(ctx, client, "/tx/simulate", tx, 0, false)
+		if err != nil {
+			return err
+		}
+		result := new(abci.SimulateResponse)
+		if err := json.Unmarshal(rsp.Value, result); err != nil {
+			return errors.Wrap(err, "could not decode simulation result")
+		}
+		return printJSON(result)
+	}
+
+	result := &txResult{
+		Hash:   types.Tx(tx).Hash(),
+		TxHash: txEnv.Tx.Hash(),
+	}
+	switch mode {
+	case broadcastCommit:
+		rsp, err := client.BroadcastTxCommit(ctx, tx)
+		if err != nil {
+			return errors.Wrap(err, "could not broadcast transaction")
+		}
+		if rsp.CheckTx.Code != abciTypes.CodeTypeOK {
+			result.Code, result.Codespace, result.Log = rsp.CheckTx.Code, rsp.CheckTx.Codespace, rsp.CheckTx.Log
+			break
+		}
+		result.Height = rsp.Height
+		result.Code, result.Codespace, result.Log = rsp.DeliverTx.Code, rsp.DeliverTx.Codespace, rsp.DeliverTx.Log
+		result.GasUsed = rsp.DeliverTx.GasUsed
+		result.Data = rsp.DeliverTx.Data
+		result.Events = rsp.DeliverTx.Events
+	default:
+		broadcast := client.BroadcastTxSync
+		if mode == broadcastAsync {
+			broadcast = client.BroadcastTxAsync
+		}
+		rsp, err := broadcast(ctx, tx)
+		if err != nil {
+			return errors.Wrap(err, "could not broadcast transaction")
+		}
+		result.Code, result.Codespace, result.Log, result.Data = rsp.Code, rsp.Codespace, rsp.Log, rsp.Data
+	}
+	if err := printJSON(result); err != nil {
+		return err
+	}
+	if result.Code != abciTypes.CodeTypeOK {
+		return errors.Errorf("transaction failed with code %d", result.Code)
+	}
+	return nil
+}
+
+// txSigner is the --from key, unlocked from the keystore or held by the remote signer at --signer
+func txSigner(cmd *cobra.Command) (crypto.AddressableSigner, error) {
+	from, _ := cmd.Flags().GetString("from")
+	if from == "" {
+		return nil, errors.New("--from is required")
+	}
+	address, _ := cmd.Flags().GetString("signer")
+	if address == "" {
+		privateKey, err := unlock(cmd, from)
+		if err != nil {
+			return nil, err
+		}
+		return &privateKey, nil
+	}
+
+	fromAddress, err := crypto.AddressFromHexString(from)
+	if err != nil {
+		return nil, errors.Wrap(err, "--from must be an address with --signer")
+	}
+	secret, err := readSecret(cmd, "signer-secret-file")
+	if err != nil {
+		return nil, err
+	}
+	certFile, _ := cmd.Flags().GetString("signer-tls-cert")
+	keyFile, _ := cmd.Flags().GetString("signer-tls-key")
+	caFile, _ := cmd.Flags().GetString("signer-tls-ca")
+	var opts []grpc.DialOption
+	if caFile != "" {
+		config, err := signer.ClientTLSConfig(certFile, keyFile, caFile)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(config)))
+	} else if isLoopback(address) {
+		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	} else {
+		return nil, errors.Errorf("--signer-tls-ca is required to reach the signer at %s", address)
+	}
+	if secret != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(signer.SecretCredentials(secret, caFile == "")))
+	}
+	client, err := signer.Dial(address, opts...)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), signer.SignTimeout)
+	defer cancel()
+	return client.Signer(ctx, fromAddress)
+}
+
+func parseAmount(s string) (uint64, error) {
+	amount, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, errors.Errorf("invalid amount %q", s)
+	}
+	return amount, nil
+}
+
+// addTxFlags adds the flags that every tx command takes
+func addTxFlags(flags *pflag.FlagSet) {
+	flags.String("from", "", "name or address of the signing key")
+	flags.String("chain-id", "", "chain ID to sign for, read from the node by default")
+	flags.Uint64("sequence", 0, "sequence of the transaction, the account's next by default")
+	flags.Uint64("fee", 0, "fee paid by the --from account")
+	flags.String("broadcast-mode", broadcastSync,
+		"async returns at once, sync after CheckTx and commit once the transaction is in a block")
+	flags.Bool("simulate", false,
+		"execute the transaction against the latest state without broadcasting it")
+	flags.String("keystore", defaultKeystore(), "directory of the keystore")
+	flags.String("passphrase-file", "", "file holding the passphrase of the key")
+	flags.String("signer", "", "address of a remote signer to sign with instead of the keystore")
+	flags.String("signer-secret-file", "", "file holding the secret of the remote signer")
+	flags.String("signer-tls-ca", "", "CA certificate of the remote signer")
+	flags.String("signer-tls-cert", "", "client certificate for the remote signer")
+	flags.String("signer-tls-key", "", "private key of --signer-tls-cert")
+}
+
+func init() {
+	addNodeFlag(CmdTx)
+	addTxFlags(CmdTx.PersistentFlags())
+
+	cmdTxName.Flags().String("data", "", "data to register under the name")
+	cmdTxName.Flags().String("new-owner", "", "transfer the name to this address")
+	cmdTxName.Flags().Bool("release", false, "give up the name")
+	cmdTxBond.Flags().String("validator", "", "hex public key of the validator to bond to")
+	cmdTxBond.Flags().String("key-type", "ed25519", "curve of --validator, ed25519 or secp256k1")
+	cmdTxPermissions.Flags().String("target", "", "account to change, the global permissions by default")
+	cmdTxPermissions.Flags().StringSlice("permission", nil, "permissions to set or unset")
+	cmdTxPermissions.Flags().Bool("value", false, "value to set the permissions to")
+	cmdTxPermissions.Flags().String("role", "", "role to add or remove")
+	cmdTxUpgrade.Flags().String("name", "", "name of the upgrade, the new binary must have a handler for it")
+	cmdTxUpgrade.Flags().Uint64("height", 0, "height of the first block to run the upgraded protocol")
+	cmdTxUpgrade.Flags().String("info", "", "where operators can find the new binary")
+	cmdTxUpgrade.Flags().Bool("cancel", false, "cancel the scheduled upgrade")
+	cmdTxUpgrade.Flags().Bool("print", false, "print a proposal of the upgrade for propose instead of sending it")
+
+	CmdTx.AddCommand(cmdTxSend, cmdTxName, cmdTxBond, cmdTxUnbond, cmdTxPermissions, cmdTxPropose, cmdTxVote,
+		cmdTxUpgrade)
+}
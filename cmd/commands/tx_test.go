@@ -0,0 +1,199 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/sunvim/yaoguang/abci"
+	"github.com/sunvim/yaoguang/account"
+	"github.com/sunvim/yaoguang/codes"
+	"github.com/sunvim/yaoguang/crypto"
+	"github.com/sunvim/yaoguang/keys"
+	"github.com/sunvim/yaoguang/signer"
+	"github.com/sunvim/yaoguang/txs"
+	"github.com/sunvim/yaoguang/txs/payload"
+	abciTypes "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/libs/bytes"
+	rpcclient "github.com/tendermint/tendermint/rpc/client"
+	"github.com/tendermint/tendermint/rpc/coretypes"
+	"github.com/tendermint/tendermint/types"
+	"google.golang.org/grpc"
+)
+
+const testChainID = "yaoguang-test"
+
+var (
+	alice = crypto.PrivateKeyFromSecret("alice", crypto.CurveTypeEd25519)
+	bob   = crypto.PrivateKeyFromSecret("bob", crypto.CurveTypeEd25519)
+)
+
+// mockClient answers as a node whose app holds alice's account, it records what is sent to it
+type mockClient struct {
+	sequence  uint64
+	checkTx   abciTypes.ResponseCheckTx
+	queries   []string
+	broadcast []types.Tx
+}
+
+func (mc *mockClient) Status(context.Context) (*coretypes.ResultStatus, error) {
+	status := new(coretypes.ResultStatus)
+	status.NodeInfo.Network = testChainID
+	return status, nil
+}
+
+func (mc *mockClient) ABCIInfo(context.Context) (*coretypes.ResultABCIInfo, error) {
+	return new(coretypes.ResultABCIInfo), nil
+}
+
+func (mc *mockClient) ABCIQuery(ctx context.Context, path string,
+	data bytes.HexBytes) (*coretypes.ResultABCIQuery, error) {
+	return mc.ABCIQueryWithOptions(ctx, path, data, rpcclient.DefaultABCIQueryOptions)
+}
+
+func (mc *mockClient) ABCIQueryWithOptions(_ context.Context, path string, data bytes.HexBytes,
+	_ rpcclient.ABCIQueryOptions) (*coretypes.ResultABCIQuery, error) {
+	mc.queries = append(mc.queries, path)
+	var value []byte
+	var err error
+	switch path {
+	case "/accounts/" + alice.GetAddress().String():
+		value, err = (&account.Account{Address: alice.GetAddress(), Sequence: mc.sequence}).Encode()
+	case "/tx/simulate":
+		txEnv, derr := txs.NewJSONCodec().DecodeTx(data)
+		if derr != nil {
+			return nil, derr
+		}
+		value, err = json.Marshal(abci.SimulateResponse{TxHash: txEnv.Tx.Hash(), GasUsed: 1000})
+	default:
+		return &coretypes.ResultABCIQuery{Response: abciTypes.ResponseQuery{Code: codes.UnknownAccountCode}}, nil
+	}
+	return &coretypes.ResultABCIQuery{Response: abciTypes.ResponseQuery{Value: value}}, err
+}
+
+func (mc *mockClient) BroadcastTxCommit(_ context.Context, tx types.Tx) (*coretypes.ResultBroadcastTxCommit, error) {
+	mc.broadcast = append(mc.broadcast, tx)
+	return &coretypes.ResultBroadcastTxCommit{CheckTx: mc.checkTx, Height: 2}, nil
+}
+
+func (mc *mockClient) BroadcastTxAsync(ctx context.Context, tx types.Tx) (*coretypes.ResultBroadcastTx, error) {
+	return mc.BroadcastTxSync(ctx, tx)
+}
+
+func (mc *mockClient) BroadcastTxSync(_ context.Context, tx types.Tx) (*coretypes.ResultBroadcastTx, error) {
+	mc.broadcast = append(mc.broadcast, tx)
+	return &coretypes.ResultBroadcastTx{Code: mc.checkTx.Code, Log: mc.checkTx.Log}, nil
+}
+
+// sent decodes the transactions broadcast to the client
+func (mc *mockClient) sent(t *testing.T) []*txs.Envelope {
+	var sent []*txs.Envelope
+	for _, tx := range mc.broadcast {
+		txEnv, err := txs.NewJSONCodec().DecodeTx(tx)
+		require.NoError(t, err)
+		require.NoError(t, txEnv.Verify(testChainID))
+		sent = append(sent, txEnv)
+	}
+	return sent
+}
+
+// newTxCmd returns a command with the tx flags set from args
+func newTxCmd(t *testing.T, args ...string) *cobra.Command {
+	cmd := &cobra.Command{}
+	addTxFlags(cmd.Flags())
+	require.NoError(t, cmd.ParseFlags(args))
+	return cmd
+}
+
+func sendToBob(from crypto.Address, sequence uint64) (payload.Payload, error) {
+	send := payload.NewSendTx()
+	send.AddInput(from, 10, sequence)
+	send.AddOutput(bob.GetAddress(), 10)
+	return send, nil
+}
+
+func TestSendTx(t *testing.T) {
+	ctx := context.Background()
+
+	// The sequence follows the account's and the chain ID is read from the node
+	client := &mockClient{sequence: 4}
+	require.NoError(t, sendTx(ctx, newTxCmd(t), client, &alice, sendToBob))
+	sent := client.sent(t)
+	require.Len(t, sent, 1)
+	assert.Equal(t, uint64(5), sent[0].Tx.GetInputs()[0].Sequence)
+	assert.Equal(t, testChainID, sent[0].Tx.ChainID)
+
+	// A given sequence is used as it is
+	client = &mockClient{sequence: 4}
+	require.NoError(t, sendTx(ctx, newTxCmd(t, "--sequence", "9", "--broadcast-mode", broadcastCommit), client,
+		&alice, sendToBob))
+	assert.Empty(t, client.queries)
+	sent = client.sent(t)
+	require.Len(t, sent, 1)
+	assert.Equal(t, uint64(9), sent[0].Tx.GetInputs()[0].Sequence)
+
+	// A simulation is only run against the node's state
+	client = &mockClient{}
+	require.NoError(t, sendTx(ctx, newTxCmd(t, "--simulate"), client, &alice, sendToBob))
+	assert.Equal(t, []string{"/accounts/" + alice.GetAddress().String(), "/tx/simulate"}, client.queries)
+	assert.Empty(t, client.broadcast)
+
+	// A transaction CheckTx rejects fails the command
+	for _, mode := range []string{broadcastSync, broadcastCommit} {
+		client = &mockClient{checkTx: abciTypes.ResponseCheckTx{Code: codes.InvalidSequenceCode, Log: "replay"}}
+		err := sendTx(ctx, newTxCmd(t, "--broadcast-mode", mode), client, &alice, sendToBob)
+		assert.Error(t, err, mode)
+		assert.Len(t, client.broadcast, 1, mode)
+	}
+}
+
+func TestTxSigner(t *testing.T) {
+	dir := t.TempDir()
+	passphraseFile := filepath.Join(dir, "passphrase")
+	require.NoError(t, os.WriteFile(passphraseFile, []byte("passphrase"), 0600))
+	keystoreDir := filepath.Join(dir, "keystore")
+	_, err := keys.NewKeystore(keystoreDir, keys.LightScryptN).Import("alice", alice, "passphrase")
+	require.NoError(t, err)
+
+	_, err = txSigner(newTxCmd(t))
+	assert.Error(t, err, "--from is required")
+
+	// Without --signer the key is unlocked from the keystore
+	s, err := txSigner(newTxCmd(t, "--from", "alice", "--keystore", keystoreDir,
+		"--passphrase-file", passphraseFile))
+	require.NoError(t, err)
+	assert.Equal(t, alice.GetAddress(), s.GetAddress())
+
+	// A remote signer is reached without TLS only over loopback
+	const secret = "shared secret"
+	secretFile := filepath.Join(dir, "secret")
+	require.NoError(t, os.WriteFile(secretFile, []byte(secret), 0600))
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := grpc.NewServer(grpc.UnaryInterceptor(signer.SecretInterceptor(secret)))
+	signer.RegisterSignerServer(server, signer.NewServer(
+		[]*keys.Key{{Address: alice.GetAddress(), PublicKey: alice.GetPublicKey()}}, alice))
+	go server.Serve(listener)
+	defer server.Stop()
+
+	from := alice.GetAddress().String()
+	_, err = txSigner(newTxCmd(t, "--from", "alice", "--signer", listener.Addr().String()))
+	assert.Error(t, err, "--from must be an address with --signer")
+	_, err = txSigner(newTxCmd(t, "--from", from, "--signer", "signer.example.com:26680"))
+	assert.Error(t, err, "--signer-tls-ca is required off loopback")
+
+	s, err = txSigner(newTxCmd(t, "--from", from, "--signer", listener.Addr().String(),
+		"--signer-secret-file", secretFile))
+	require.NoError(t, err)
+	assert.IsType(t, &signer.RemoteSigner{}, s)
+	assert.Equal(t, alice.GetAddress(), s.GetAddress())
+	sig, err := s.Sign([]byte("msg"))
+	require.NoError(t, err)
+	assert.NoError(t, alice.GetPublicKey().Verify([]byte("msg"), sig))
+}
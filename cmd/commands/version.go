@@ -21,26 +21,42 @@ import (
 	"fmt"
 
 	"github.com/spf13/cobra"
+	"github.com/sunvim/yaoguang/version"
 )
 
 var (
-	Branch    = "main"
-	Author    = "mobus"
-	Email     = "<sunsc0220@gmail.com>"
-	Date      = "2022-03-08"
-	Commit    = "821288f"
-	GoVersion = "go1.17.8 linux/amd64"
+	Author = "mobus"
+	Email  = "<sunsc0220@gmail.com>"
 )
 
 var CmdVersion = &cobra.Command{
 	Use:   "version",
 	Short: "show software verison",
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("branch: ", Branch)
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		info := version.Get()
+		if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+			return printJSON(info)
+		}
+		fmt.Println("version: ", info)
+		if info.Branch != "" {
+			fmt.Println("branch: ", info.Branch)
+		}
 		fmt.Println("author: ", Author)
 		fmt.Println("email: ", Email)
-		fmt.Println("date: ", Date)
-		fmt.Println("git commit: ", Commit)
-		fmt.Println(GoVersion)
+		if info.Date != "" {
+			fmt.Println("date: ", info.Date)
+		}
+		if info.Commit != "" {
+			fmt.Println("git commit: ", info.Commit)
+		}
+		fmt.Println("modified: ", info.Modified)
+		fmt.Println("app version: ", info.AppVersion)
+		fmt.Println(info.GoVersion, info.Platform)
+		return nil
 	},
 }
+
+func init() {
+	CmdVersion.Flags().Bool("json", false, "print the build as JSON")
+}
@@ -44,6 +44,7 @@ func Execute() {
 }
 
 func init() {
-	rootCmd.AddCommand(commands.CmdVersion, commands.CmdStart)
+	rootCmd.AddCommand(commands.CmdVersion, commands.CmdInit, commands.CmdTestnet, commands.CmdKeys, commands.CmdSigner, commands.CmdTx, commands.CmdQuery, commands.CmdExport,
+		commands.CmdReplay, commands.CmdRollback, commands.CmdStart, commands.CmdABCI)
 	log.Logger = zerolog.New(zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}).With().Timestamp().Logger()
 }
@@ -35,10 +35,51 @@ const (
 
 	// Informational
 	UnsupportedRequestCode  uint32 = 400
+	InvalidSignatureCode    uint32 = 401
+	InsufficientFundsCode   uint32 = 402
 	PeerFilterForbiddenCode uint32 = 403
+	UnknownAccountCode      uint32 = 404
+	WrongChainIDCode        uint32 = 405
+	InvalidTxCode           uint32 = 406
+	UnsupportedTxTypeCode   uint32 = 407
+	IntegerOverflowCode     uint32 = 408
+	// Input sequence is not the next sequence of its account: a replay (stale) or sent out of order (future)
+	InvalidSequenceCode  uint32 = 409
+	PermissionDeniedCode uint32 = 410
+	UnknownNameCode      uint32 = 411
+	UnknownProposalCode  uint32 = 412
+	// Query for a height whose state is not (or no longer) available
+	InvalidHeightCode uint32 = 413
 
 	// Internal errors
 	EncodingErrorCode    uint32 = 500
 	TxExecutionErrorCode uint32 = 501
 	CommitErrorCode      uint32 = 502
+	// A request that is not part of consensus failed unexpectedly, it is answered rather than halting the node
+	InternalErrorCode uint32 = 503
+)
+
+// Codespace of the codes defined by yaoguang itself
+const Codespace = "yaoguang"
+
+var (
+	OK                  = Register(Codespace, TxExecutionSuccessCode, "OK", "success")
+	UnsupportedRequest  = Register(Codespace, UnsupportedRequestCode, "UnsupportedRequest", "request not supported")
+	InvalidSignature    = Register(Codespace, InvalidSignatureCode, "InvalidSignature", "bad transaction signature")
+	InsufficientFunds   = Register(Codespace, InsufficientFundsCode, "InsufficientFunds", "account balance is too low")
+	PeerFilterForbidden = Register(Codespace, PeerFilterForbiddenCode, "PeerFilterForbidden", "peer not allowed")
+	UnknownAccount      = Register(Codespace, UnknownAccountCode, "UnknownAccount", "account does not exist")
+	WrongChainID        = Register(Codespace, WrongChainIDCode, "WrongChainID", "transaction for another chain")
+	InvalidTx           = Register(Codespace, InvalidTxCode, "InvalidTx", "transaction is malformed")
+	UnsupportedTxType   = Register(Codespace, UnsupportedTxTypeCode, "UnsupportedTxType", "unsupported transaction type")
+	IntegerOverflow     = Register(Codespace, IntegerOverflowCode, "IntegerOverflow", "amount overflows")
+	InvalidSequence     = Register(Codespace, InvalidSequenceCode, "InvalidSequence", "sequence is stale or in the future")
+	PermissionDenied    = Register(Codespace, PermissionDeniedCode, "PermissionDenied", "account is not allowed")
+	UnknownName         = Register(Codespace, UnknownNameCode, "UnknownName", "name is not registered")
+	UnknownProposal     = Register(Codespace, UnknownProposalCode, "UnknownProposal", "proposal does not exist")
+	InvalidHeight       = Register(Codespace, InvalidHeightCode, "InvalidHeight", "state at height is not available")
+	EncodingError       = Register(Codespace, EncodingErrorCode, "EncodingError", "could not encode or decode")
+	TxExecutionError    = Register(Codespace, TxExecutionErrorCode, "TxExecutionError", "transaction execution failed")
+	CommitError         = Register(Codespace, CommitErrorCode, "CommitError", "could not commit block")
+	InternalError       = Register(Codespace, InternalErrorCode, "InternalError", "unexpected failure")
 )
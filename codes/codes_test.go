@@ -0,0 +1,63 @@
+package codes
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	pkgerrors "github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestError_Is(t *testing.T) {
+	err := InsufficientFunds.Errorf("account %d is broke", 7)
+	assert.True(t, errors.Is(err, InsufficientFunds))
+	assert.False(t, errors.Is(err, InvalidSignature))
+
+	wrapped := pkgerrors.Wrap(fmt.Errorf("sending: %w", err), "executing")
+	assert.True(t, errors.Is(wrapped, InsufficientFunds))
+	assert.Equal(t, err, pkgerrors.Cause(pkgerrors.WithStack(err)))
+
+	var coded *Error
+	require.True(t, errors.As(wrapped, &coded))
+	assert.Equal(t, InsufficientFunds, coded.Code())
+	assert.Equal(t, "executing: sending: account 7 is broke", wrapped.Error())
+}
+
+func TestError_Wrap(t *testing.T) {
+	cause := errors.New("bad bytes")
+	err := EncodingError.Wrap(cause, "could not decode")
+	assert.True(t, errors.Is(err, EncodingError))
+	assert.True(t, errors.Is(err, cause))
+	assert.Equal(t, "could not decode: bad bytes", err.Error())
+	assert.Nil(t, EncodingError.Wrap(nil, "nothing"))
+}
+
+func TestABCIInfo(t *testing.T) {
+	code, codespace, log := ABCIInfo(nil)
+	assert.Equal(t, TxExecutionSuccessCode, code)
+	assert.Equal(t, "", codespace)
+	assert.Equal(t, "", log)
+
+	code, codespace, log = ABCIInfo(pkgerrors.Wrap(InvalidSequence, "checking"))
+	assert.Equal(t, InvalidSequenceCode, code)
+	assert.Equal(t, Codespace, codespace)
+	assert.Equal(t, "checking: sequence is stale or in the future", log)
+
+	code, _, _ = ABCIInfo(errors.New("something unexpected"))
+	assert.Equal(t, TxExecutionErrorCode, code)
+}
+
+func TestRegistry(t *testing.T) {
+	code, ok := Lookup(Codespace, InsufficientFundsCode)
+	require.True(t, ok)
+	assert.Equal(t, "InsufficientFunds", code.Name())
+	assert.Panics(t, func() {
+		Register(Codespace, InsufficientFundsCode, "Duplicate", "")
+	})
+	all := All()
+	for i := 1; i < len(all); i++ {
+		assert.Less(t, all[i-1].Number(), all[i].Number())
+	}
+}
@@ -0,0 +1,96 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package codes
+
+import (
+	"errors"
+)
+
+// Error carries a registered code. errors.Is(err, code) reports whether err has that code anywhere in its chain,
+// including through github.com/pkg/errors wrapping.
+type Error struct {
+	code  *Code
+	msg   string
+	cause error
+}
+
+// Coded is implemented by errors that carry a registered code
+type Coded interface {
+	error
+	Code() *Code
+}
+
+var _ Coded = (*Error)(nil)
+var _ Coded = (*Code)(nil)
+
+func (e *Error) Code() *Code {
+	return e.code
+}
+
+func (e *Error) Error() string {
+	msg := e.msg
+	if msg == "" {
+		msg = e.code.description
+	}
+	if e.cause != nil {
+		return msg + ": " + e.cause.Error()
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Is matches a *Code or another *Error with the same code
+func (e *Error) Is(target error) bool {
+	switch t := target.(type) {
+	case *Code:
+		return t == e.code
+	case *Error:
+		return t.code == e.code
+	}
+	return false
+}
+
+// Code lets a *Code be returned directly as an error
+func (c *Code) Code() *Code {
+	return c
+}
+
+// FromError returns the code of the first coded error in err's chain, TxExecutionError if there is none, and OK
+// for a nil error
+func FromError(err error) *Code {
+	if err == nil {
+		return OK
+	}
+	var coded Coded
+	if errors.As(err, &coded) {
+		return coded.Code()
+	}
+	return TxExecutionError
+}
+
+// ABCIInfo returns the Code, Codespace and Log fields of an ABCI response for err
+func ABCIInfo(err error) (code uint32, codespace string, log string) {
+	if err == nil {
+		return TxExecutionSuccessCode, "", ""
+	}
+	c := FromError(err)
+	return c.Number(), c.Codespace(), err.Error()
+}
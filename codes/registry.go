@@ -0,0 +1,122 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package codes
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Code is a registered response code. A *Code is itself an error so it can be the target of errors.Is.
+type Code struct {
+	number      uint32
+	name        string
+	codespace   string
+	description string
+}
+
+type codeKey struct {
+	codespace string
+	number    uint32
+}
+
+var registry = struct {
+	sync.RWMutex
+	codes map[codeKey]*Code
+}{codes: make(map[codeKey]*Code)}
+
+// Register a code in codespace, it panics if the number is already taken since codes are part of the client API
+func Register(codespace string, number uint32, name, description string) *Code {
+	registry.Lock()
+	defer registry.Unlock()
+	key := codeKey{codespace: codespace, number: number}
+	if existing, ok := registry.codes[key]; ok {
+		panic(fmt.Errorf("code %d in codespace %s is already registered as %s", number, codespace, existing.name))
+	}
+	code := &Code{
+		number:      number,
+		name:        name,
+		codespace:   codespace,
+		description: description,
+	}
+	registry.codes[key] = code
+	return code
+}
+
+// Lookup a code by codespace and number
+func Lookup(codespace string, number uint32) (*Code, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	code, ok := registry.codes[codeKey{codespace: codespace, number: number}]
+	return code, ok
+}
+
+// All returns every registered code ordered by codespace then number
+func All() []*Code {
+	registry.RLock()
+	defer registry.RUnlock()
+	all := make([]*Code, 0, len(registry.codes))
+	for _, code := range registry.codes {
+		all = append(all, code)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].codespace != all[j].codespace {
+			return all[i].codespace < all[j].codespace
+		}
+		return all[i].number < all[j].number
+	})
+	return all
+}
+
+func (c *Code) Number() uint32 {
+	return c.number
+}
+
+func (c *Code) Name() string {
+	return c.name
+}
+
+func (c *Code) Codespace() string {
+	return c.codespace
+}
+
+func (c *Code) Description() string {
+	return c.description
+}
+
+func (c *Code) Error() string {
+	return c.description
+}
+
+func (c *Code) String() string {
+	return fmt.Sprintf("%s/%d (%s)", c.codespace, c.number, c.name)
+}
+
+// Errorf returns an error carrying this code
+func (c *Code) Errorf(format string, args ...interface{}) *Error {
+	return &Error{code: c, msg: fmt.Sprintf(format, args...)}
+}
+
+// Wrap returns an error carrying this code caused by err
+func (c *Code) Wrap(err error, msg string) *Error {
+	if err == nil {
+		return nil
+	}
+	return &Error{code: c, msg: msg, cause: err}
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package core
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"github.com/sunvim/yaoguang/blockchain"
+	"github.com/sunvim/yaoguang/execution"
+	"github.com/sunvim/yaoguang/state"
+	"github.com/tendermint/tendermint/types"
+	dbm "github.com/tendermint/tm-db"
+)
+
+// ExportGenesis dumps the application state a node committed at height, or at its last block if height is 0, to a
+// genesis doc for a chain that starts at the following height. The node must be stopped as its state database is
+// opened exclusively.
+func ExportGenesis(configFile string, height uint64, chainID string) (*types.GenesisDoc, error) {
+	config, err := loadConfig(configFile)
+	if err != nil {
+		return nil, err
+	}
+	genesisDoc, err := loadGenesis(config)
+	if err != nil {
+		return nil, err
+	}
+	stateDB, err := dbm.NewDB(stateDBName, dbm.BackendType(config.DBBackend), config.DBDir())
+	if err != nil {
+		return nil, errors.Wrap(err, "could not open state database, is the node still running?")
+	}
+	defer stateDB.Close()
+	bc, err := blockchain.LoadOrNewBlockchain(stateDB, genesisDoc)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not load blockchain state")
+	}
+	if !bc.HasBlocks() {
+		return nil, errors.New("no blocks have been committed so there is no state to export")
+	}
+	last := bc.LastBlockHeight()
+	if height == 0 {
+		height = last
+	}
+	if height < bc.InitialHeight() || height > last {
+		return nil, errors.Errorf("cannot export height %d, blocks %d to %d have been committed", height,
+			bc.InitialHeight(), last)
+	}
+	st, err := state.LoadState(stateDB, int64(last))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not load state")
+	}
+	reader, err := st.Reader(int64(height))
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read state at height %d", height)
+	}
+	appState, err := execution.ExportAppState(reader)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not export state at height %d", height)
+	}
+	appStateBytes, err := json.Marshal(appState)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not encode app state")
+	}
+
+	appVersion, err := reader.GetAppVersion()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read app version")
+	}
+
+	if chainID == "" {
+		chainID = genesisDoc.ChainID
+	}
+	exported := &types.GenesisDoc{
+		ChainID:       chainID,
+		InitialHeight: int64(height) + 1,
+		AppState:      appStateBytes,
+	}
+	if genesisDoc.ConsensusParams != nil {
+		params := *genesisDoc.ConsensusParams
+		exported.ConsensusParams = &params
+	} else {
+		exported.ConsensusParams = types.DefaultConsensusParams()
+	}
+	// the new chain carries on with the protocol the exported one had upgraded to
+	exported.ConsensusParams.Version.AppVersion = appVersion
+	for _, gv := range appState.Validators {
+		pubKey := gv.PublicKey.TendermintPubKey()
+		if pubKey == nil {
+			return nil, errors.Errorf("validator %v has no tendermint public key", gv.PublicKey)
+		}
+		exported.Validators = append(exported.Validators, types.GenesisValidator{
+			Address: pubKey.Address(),
+			PubKey:  pubKey,
+			Power:   int64(gv.Power),
+		})
+	}
+	if err := exported.ValidateAndComplete(); err != nil {
+		return nil, errors.Wrap(err, "exported genesis is invalid")
+	}
+	return exported, nil
+}
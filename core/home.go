@@ -0,0 +1,172 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sunvim/yaoguang/crypto"
+	"github.com/sunvim/yaoguang/genesis"
+	"github.com/sunvim/yaoguang/version"
+	cfg "github.com/tendermint/tendermint/config"
+	tmos "github.com/tendermint/tendermint/libs/os"
+	"github.com/tendermint/tendermint/privval"
+	"github.com/tendermint/tendermint/types"
+)
+
+// Power of each validator in a generated genesis
+const DefaultValidatorPower = 10
+
+// yaoguangConfigTemplate is appended to the tendermint config.toml
+const yaoguangConfigTemplate = `
+#######################################################
+###            Yaoguang Configuration Options       ###
+#######################################################
+[yaoguang]
+
+# Connect to an application served separately by yaoguang abci on proxy-app rather than running it in-process
+external-abci = false
+
+# Which heights of the application state to keep on disk: "nothing" prunes nothing, "everything" keeps only the
+# last height and "custom" keeps the last height, the pruning-keep-recent heights before it and every height that
+# is a multiple of pruning-keep-every, if it is not 0. Pruned heights can no longer be queried, exported, replayed
+# from or rolled back to.
+pruning = "nothing"
+pruning-keep-recent = 0
+pruning-keep-every = 0
+`
+
+// HomeConfig returns the default config of a validator node home at dir
+func HomeConfig(dir, moniker string) *cfg.Config {
+	config := cfg.DefaultConfig()
+	config.SetRoot(dir)
+	config.Moniker = moniker
+	config.Mode = cfg.ModeValidator
+	return config
+}
+
+// Home is a node home directory created by InitHome
+type Home struct {
+	Config *cfg.Config
+	NodeID types.NodeID
+	// Key the node validates with
+	Validator *crypto.PublicKey
+}
+
+// InitHome creates the directory layout of config, writes config.toml and generates a node key and a private
+// validator key of curveType. It refuses to overwrite an existing home.
+func InitHome(config *cfg.Config, curveType crypto.CurveType) (*Home, error) {
+	configFile := filepath.Join(config.RootDir, "config", "config.toml")
+	for _, file := range []string{configFile, config.PrivValidator.KeyFile(), config.NodeKeyFile()} {
+		if tmos.FileExists(file) {
+			return nil, errors.Errorf("%s already exists, remove it or choose another home", file)
+		}
+	}
+	for _, dir := range []string{config.RootDir, filepath.Dir(configFile), config.DBDir(),
+		filepath.Dir(config.PrivValidator.KeyFile()), filepath.Dir(config.PrivValidator.StateFile())} {
+		if err := tmos.EnsureDir(dir, cfg.DefaultDirPerm); err != nil {
+			return nil, errors.Wrapf(err, "could not create directory %s", dir)
+		}
+	}
+	if err := WriteConfig(config); err != nil {
+		return nil, err
+	}
+
+	nodeKey, err := types.LoadOrGenNodeKey(config.NodeKeyFile())
+	if err != nil {
+		return nil, errors.Wrap(err, "could not generate node key")
+	}
+
+	privateKey, err := crypto.GeneratePrivateKey(nil, curveType)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not generate validator key")
+	}
+	tmPrivKey, err := privateKey.TendermintPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	privval.NewFilePV(tmPrivKey, config.PrivValidator.KeyFile(), config.PrivValidator.StateFile()).Save()
+
+	return &Home{
+		Config:    config,
+		NodeID:    nodeKey.ID,
+		Validator: privateKey.GetPublicKey(),
+	}, nil
+}
+
+// WriteConfig writes config.toml with the yaoguang section into the home of config, replacing any existing file
+func WriteConfig(config *cfg.Config) error {
+	configFile := filepath.Join(config.RootDir, "config", "config.toml")
+	if err := config.WriteToTemplate(configFile); err != nil {
+		return errors.Wrapf(err, "could not write %s", configFile)
+	}
+	f, err := os.OpenFile(configFile, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		return errors.Wrapf(err, "could not open %s", configFile)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(yaoguangConfigTemplate); err != nil {
+		return errors.Wrapf(err, "could not write %s", configFile)
+	}
+	return nil
+}
+
+// NewGenesisDoc returns a genesis with a validator for each home and appState as its app_state
+func NewGenesisDoc(chainID string, homes []*Home, appState *genesis.AppState) (*types.GenesisDoc, error) {
+	appStateBytes, err := json.Marshal(appState)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not encode app state")
+	}
+	genesisDoc := &types.GenesisDoc{
+		ChainID:         chainID,
+		GenesisTime:     time.Now().UTC(),
+		InitialHeight:   1,
+		ConsensusParams: types.DefaultConsensusParams(),
+		AppState:        appStateBytes,
+	}
+	genesisDoc.ConsensusParams.Version.AppVersion = version.AppVersion
+	pubKeyTypes := make(map[string]bool)
+	genesisDoc.ConsensusParams.Validator.PubKeyTypes = nil
+	for _, home := range homes {
+		pubKey := home.Validator.TendermintPubKey()
+		if pubKey == nil {
+			return nil, fmt.Errorf("validator %v has no tendermint public key", home.Validator)
+		}
+		genesisDoc.Validators = append(genesisDoc.Validators, types.GenesisValidator{
+			Address: pubKey.Address(),
+			PubKey:  pubKey,
+			Power:   DefaultValidatorPower,
+			Name:    home.Config.Moniker,
+		})
+		// Tendermint only accepts validator keys of the types the consensus params allow
+		if keyType := home.Validator.CurveType.ABCIType(); !pubKeyTypes[keyType] {
+			pubKeyTypes[keyType] = true
+			genesisDoc.ConsensusParams.Validator.PubKeyTypes = append(
+				genesisDoc.ConsensusParams.Validator.PubKeyTypes, keyType)
+		}
+	}
+	if err := genesisDoc.ValidateAndComplete(); err != nil {
+		return nil, errors.Wrap(err, "generated genesis is invalid")
+	}
+	return genesisDoc, nil
+}
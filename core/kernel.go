@@ -18,76 +18,265 @@
 package core
 
 import (
+	"context"
+	"os"
 	"path/filepath"
+	"strings"
+	"syscall"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/viper"
 	"github.com/sunvim/yaoguang/abci"
 	"github.com/sunvim/yaoguang/blockchain"
+	"github.com/sunvim/yaoguang/execution"
+	"github.com/sunvim/yaoguang/share"
+	"github.com/sunvim/yaoguang/state"
+	"github.com/sunvim/yaoguang/txs"
 	abciclient "github.com/tendermint/tendermint/abci/client"
+	abciserver "github.com/tendermint/tendermint/abci/server"
 	cfg "github.com/tendermint/tendermint/config"
 	"github.com/tendermint/tendermint/libs/log"
 	"github.com/tendermint/tendermint/libs/service"
 	nm "github.com/tendermint/tendermint/node"
 	"github.com/tendermint/tendermint/types"
+	dbm "github.com/tendermint/tm-db"
 )
 
+const (
+	// name of the database holding application state under the tendermint db dir
+	stateDBName = "yaoguang_state"
+)
+
+// Kern runs a Tendermint node, an ABCI server for an out-of-process node, or both in one process
 type Kern struct {
-	node service.Service
+	node       service.Service
+	abciServer service.Service
+	app        *abci.App
+	pruner     *state.Pruner
+	stateDB    dbm.DB
 }
 
-func NewKern(nodeInfo, config string) *Kern {
+// NewKern creates a Tendermint node running the application in-process, or connecting to it over ABCI if
+// external-abci is set
+func NewKern(nodeInfo, config string) (*Kern, error) {
 	kern := &Kern{}
-	node, err := newTendermint(nodeInfo, config)
+	if err := kern.newTendermint(nodeInfo, config); err != nil {
+		if kern.stateDB != nil {
+			kern.stateDB.Close()
+		}
+		return nil, err
+	}
+	return kern, nil
+}
+
+// NewABCIKern serves the application over Tendermint's socket or gRPC ABCI protocol, as selected by the abci
+// option of the config, on the proxy-app address so a node started with external-abci can connect to it
+func NewABCIKern(nodeInfo, configFile string) (*Kern, error) {
+	config, err := loadConfig(configFile)
+	if err != nil {
+		return nil, err
+	}
+	genesisDoc, err := loadGenesis(config)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	kern.node = node
-	return kern
+	kern := &Kern{}
+	if err := kern.newApp(nodeInfo, config, genesisDoc); err != nil {
+		return nil, err
+	}
+	kern.abciServer, err = abciserver.NewServer(config.ProxyApp, config.ABCI, kern.app)
+	if err != nil {
+		kern.stateDB.Close()
+		return nil, errors.Wrapf(err, "could not create %s ABCI server on %s", config.ABCI, config.ProxyApp)
+	}
+	return kern, nil
 }
 
-func newTendermint(nodeInfo, configFile string) (service.Service, error) {
-	var err error
-	// read config
+func loadConfig(configFile string) (*cfg.Config, error) {
+	if _, err := os.Stat(configFile); err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.Errorf("config file %s does not exist, pass the path of a node's config.toml "+
+				"with --config or create one with yaoguang init", configFile)
+		}
+		return nil, errors.Wrapf(err, "could not read config file %s", configFile)
+	}
 	config := cfg.DefaultConfig()
-	config.RootDir = filepath.Dir(filepath.Dir(configFile))
 	viper.SetConfigFile(configFile)
 	if err := viper.ReadInConfig(); err != nil {
-		return nil, errors.Wrap(err, "viper failed to read config file")
+		return nil, errors.Wrapf(err, "could not parse config file %s", configFile)
 	}
 	if err := viper.Unmarshal(config); err != nil {
-		return nil, errors.Wrap(err, "viper failed to unmarshal config")
+		return nil, errors.Wrapf(err, "could not decode config file %s", configFile)
 	}
+	// paths in the config are relative to the home the config directory is in
+	config.SetRoot(filepath.Dir(filepath.Dir(configFile)))
 	if err := config.ValidateBasic(); err != nil {
-		return nil, errors.Wrap(err, "config is invalid")
+		return nil, errors.Wrapf(err, "config file %s is invalid", configFile)
 	}
+	return config, nil
+}
 
+func loadGenesis(config *cfg.Config) (*types.GenesisDoc, error) {
 	genesisDoc, err := types.GenesisDocFromFile(config.GenesisFile())
 	if err != nil {
-		panic(err)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, errors.Errorf("genesis file %s does not exist, set genesis-file in the config",
+				config.GenesisFile())
+		}
+		return nil, errors.Wrapf(err, "invalid genesis file %s", config.GenesisFile())
 	}
+	return genesisDoc, nil
+}
 
-	bc := blockchain.NewBlockchain()
-	bc.SetGenesisDoc(genesisDoc)
+func (k *Kern) newApp(nodeInfo string, config *cfg.Config, genesisDoc *types.GenesisDoc) error {
+	stateDB, err := dbm.NewDB(stateDBName, dbm.BackendType(config.DBBackend), config.DBDir())
+	if err != nil {
+		return errors.Wrap(err, "could not open state database")
+	}
+	bc, err := blockchain.LoadOrNewBlockchain(stateDB, genesisDoc)
+	if err != nil {
+		stateDB.Close()
+		return errors.Wrap(err, "could not load blockchain state")
+	}
+	// the state tree is versioned by height so resume from the last block the blockchain recorded
+	var st *state.State
+	if bc.HasBlocks() {
+		st, err = state.LoadState(stateDB, int64(bc.LastBlockHeight()))
+	} else {
+		st, err = state.NewInitialState(stateDB, bc.InitialHeight())
+	}
+	if err != nil {
+		stateDB.Close()
+		return errors.Wrap(err, "could not load state")
+	}
 
-	app := abci.NewApp(nodeInfo, bc, nil, nil)
+	pruning, err := state.NewPruningOptions(viper.GetString(share.Pruning), viper.GetUint64(share.PruningKeepRecent),
+		viper.GetUint64(share.PruningKeepEvery))
+	if err != nil {
+		stateDB.Close()
+		return errors.Wrap(err, "invalid pruning in config")
+	}
 
-	// create local client
-	localClient := abciclient.NewLocalCreator(app)
+	k.app = newABCIApp(nodeInfo, bc, st)
+	if pruning.Strategy != state.PruneNothing {
+		metrics := state.NopMetrics()
+		if config.Instrumentation.Prometheus {
+			metrics = state.PrometheusMetrics(config.Instrumentation.Namespace, "chain_id", genesisDoc.ChainID)
+		}
+		k.pruner = state.NewPruner(st, pruning, metrics)
+		k.app.SetPruner(k.pruner)
+	}
+	k.stateDB = stateDB
+	return nil
+}
+
+// newABCIApp wires the executors of the application to bc and st
+func newABCIApp(nodeInfo string, bc *blockchain.Blockchain, st *state.State) *abci.App {
+	checker := execution.NewBatchChecker(st, bc)
+	committer := execution.NewBatchCommitter(st, bc)
+	simulator := execution.NewSimulator(st, bc)
+	return abci.NewApp(nodeInfo, bc, st, nil, checker, committer, simulator, txs.NewJSONCodec())
+}
+
+func (k *Kern) newTendermint(nodeInfo, configFile string) error {
+	config, err := loadConfig(configFile)
+	if err != nil {
+		return err
+	}
+
+	genesisDoc, err := loadGenesis(config)
+	if err != nil {
+		return err
+	}
+
+	var clientCreator abciclient.Creator
+	if viper.GetBool(share.ExternalABCI) {
+		// the application is served by yaoguang abci, keep retrying until it is up
+		clientCreator = abciclient.NewRemoteCreator(config.ProxyApp, config.ABCI, false)
+	} else {
+		if err := k.newApp(nodeInfo, config, genesisDoc); err != nil {
+			return err
+		}
+		// create local client
+		clientCreator = abciclient.NewLocalCreator(k.app)
+	}
 
 	// create logger
 	logger, err := log.NewDefaultLogger(config.LogFormat, config.LogLevel, false)
+	if err != nil {
+		return errors.Wrapf(err, "could not create logger with log-format %q and log-level %q", config.LogFormat,
+			config.LogLevel)
+	}
 
 	// create node
-	node, err := nm.New(
+	k.node, err = nm.New(
 		config,
-		logger, localClient, genesisDoc)
+		logger, clientCreator, genesisDoc)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to create new Tendermint node")
+		return errors.Wrap(err, "failed to create new Tendermint node")
 	}
-	return node, nil
+	return nil
 }
 
 func (k *Kern) Boot() error {
-	return k.node.Start()
+	if k.pruner != nil {
+		k.pruner.Start()
+	}
+	if k.abciServer != nil {
+		if err := k.abciServer.Start(); err != nil {
+			return bootError(err, "could not start ABCI server")
+		}
+	}
+	if k.node != nil {
+		if err := k.node.Start(); err != nil {
+			return bootError(err, "could not start tendermint node")
+		}
+	}
+	return nil
+}
+
+func bootError(err error, msg string) error {
+	if errors.Is(err, syscall.EADDRINUSE) {
+		return errors.Wrap(err, msg+": a listen address is in use, stop the process using it or change the "+
+			"laddr options in the config")
+	}
+	return errors.Wrap(err, msg)
+}
+
+// Shutdown stops the node and ABCI server, waits for a Commit in progress to finish and closes the state database.
+// The node closes its own block and state stores as it stops. If ctx is done before the Commit finishes the state
+// database is left open rather than closed under a write.
+func (k *Kern) Shutdown(ctx context.Context) error {
+	var errs []error
+	if k.node != nil && k.node.IsRunning() {
+		if err := k.node.Stop(); err != nil {
+			errs = append(errs, errors.Wrap(err, "could not stop tendermint node"))
+		}
+	}
+	if k.abciServer != nil && k.abciServer.IsRunning() {
+		if err := k.abciServer.Stop(); err != nil {
+			errs = append(errs, errors.Wrap(err, "could not stop ABCI server"))
+		}
+	}
+	if k.app != nil {
+		if err := k.app.Close(ctx); err != nil {
+			return errors.Wrap(err, "gave up waiting for commit to finish, state database not closed")
+		}
+	}
+	if k.pruner != nil {
+		k.pruner.Stop()
+	}
+	if k.stateDB != nil {
+		if err := k.stateDB.Close(); err != nil {
+			errs = append(errs, errors.Wrap(err, "could not close state database"))
+		}
+	}
+	if len(errs) > 0 {
+		msgs := make([]string, len(errs))
+		for i, err := range errs {
+			msgs[i] = err.Error()
+		}
+		return errors.New(strings.Join(msgs, "; "))
+	}
+	return nil
 }
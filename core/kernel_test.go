@@ -0,0 +1,76 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/sunvim/yaoguang/crypto"
+	"github.com/sunvim/yaoguang/genesis"
+	"github.com/tendermint/tendermint/abci/types"
+)
+
+const testChainID = "yaoguang-test"
+
+// newTestHome creates the home of a single validator that commits blocks quickly and returns its config file
+func newTestHome(t *testing.T) string {
+	config := HomeConfig(t.TempDir(), "test")
+	config.LogLevel = "error"
+	config.RPC.ListenAddress = ""
+	config.P2P.ListenAddress = "tcp://127.0.0.1:0"
+	config.Consensus.TimeoutPropose = 100 * time.Millisecond
+	config.Consensus.TimeoutCommit = 10 * time.Millisecond
+	home, err := InitHome(config, crypto.CurveTypeEd25519)
+	require.NoError(t, err)
+	genesisDoc, err := NewGenesisDoc(testChainID, []*Home{home}, &genesis.AppState{
+		Accounts: []genesis.Account{{Address: home.Validator.GetAddress(), PublicKey: home.Validator, Balance: 1000}},
+	})
+	require.NoError(t, err)
+	require.NoError(t, genesisDoc.SaveAs(config.GenesisFile()))
+	return filepath.Join(config.RootDir, "config", "config.toml")
+}
+
+var lastHeightPattern = regexp.MustCompile(`(?m)^last height (\d+)$`)
+
+// runNode starts the node of configFile, waits for it to commit height and stops it again, returning the height of
+// the last block it committed. Tendermint leaves some of its databases open when it stops so the node is run in a
+// process of its own.
+func runNode(t *testing.T, configFile string, height int64) int64 {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestNodeProcess$")
+	cmd.Env = append(os.Environ(), "YAOGUANG_TEST_CONFIG="+configFile,
+		"YAOGUANG_TEST_HEIGHT="+strconv.FormatInt(height, 10))
+	out, err := cmd.Output()
+	require.NoError(t, err, "node process failed: %s", out)
+	match := lastHeightPattern.FindSubmatch(out)
+	require.NotNil(t, match, "node process did not report its height: %s", out)
+	last, err := strconv.ParseInt(string(match[1]), 10, 64)
+	require.NoError(t, err)
+	return last
+}
+
+// TestNodeProcess is the process runNode starts, it does nothing as a test of its own
+func TestNodeProcess(t *testing.T) {
+	configFile := os.Getenv("YAOGUANG_TEST_CONFIG")
+	if configFile == "" {
+		t.Skip("only run by runNode")
+	}
+	height, err := strconv.ParseInt(os.Getenv("YAOGUANG_TEST_HEIGHT"), 10, 64)
+	require.NoError(t, err)
+	kern, err := NewKern("test", configFile)
+	require.NoError(t, err)
+	require.NoError(t, kern.Boot())
+	require.Eventually(t, func() bool {
+		return kern.app.Info(types.RequestInfo{}).LastBlockHeight >= height
+	}, 30*time.Second, 10*time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	require.NoError(t, kern.Shutdown(ctx))
+	fmt.Printf("last height %d\n", kern.app.Info(types.RequestInfo{}).LastBlockHeight)
+}
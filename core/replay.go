@@ -0,0 +1,353 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package core
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/sunvim/yaoguang/abci"
+	"github.com/sunvim/yaoguang/binary"
+	"github.com/sunvim/yaoguang/blockchain"
+	"github.com/sunvim/yaoguang/state"
+	abcitypes "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/types"
+	dbm "github.com/tendermint/tm-db"
+)
+
+// ReplayResult reports the blocks a replay executed and where, if anywhere, it parted from the chain
+type ReplayResult struct {
+	From uint64 `json:"from"`
+	To   uint64 `json:"to"`
+	// app hash after the last block replayed
+	AppHash    binary.HexBytes `json:"app_hash"`
+	Divergence *Divergence     `json:"divergence,omitempty"`
+}
+
+// Divergence is the first block whose replay did not reproduce the app hash or transaction results the chain
+// recorded
+type Divergence struct {
+	Height          uint64          `json:"height"`
+	ExpectedAppHash binary.HexBytes `json:"expected_app_hash"`
+	AppHash         binary.HexBytes `json:"app_hash"`
+	// the first transaction whose result differs, if any
+	Tx *TxDivergence `json:"tx,omitempty"`
+}
+
+type TxDivergence struct {
+	Index  int             `json:"index"`
+	Hash   binary.HexBytes `json:"hash"`
+	Reason string          `json:"reason"`
+}
+
+// Replay re-executes the blocks from to to stored by a stopped node against a fresh copy of its state taken before
+// from, checking the app hash after each block and the result of each transaction against those the chain recorded.
+// A from or to of 0 replays from the first block or to the last block the application committed. The replayed state
+// is written to a new database in dbDir, or kept in memory if dbDir is empty.
+func Replay(configFile string, from, to uint64, dbDir string) (*ReplayResult, error) {
+	config, err := loadConfig(configFile)
+	if err != nil {
+		return nil, err
+	}
+	genesisDoc, err := loadGenesis(config)
+	if err != nil {
+		return nil, err
+	}
+	backend := dbm.BackendType(config.DBBackend)
+	blockStoreDB, err := dbm.NewDB(blockchain.BlockStoreDBName, backend, config.DBDir())
+	if err != nil {
+		return nil, errors.Wrap(err, "could not open block store, is the node still running?")
+	}
+	defer blockStoreDB.Close()
+	tmStateDB, err := dbm.NewDB(blockchain.TendermintStateDBName, backend, config.DBDir())
+	if err != nil {
+		return nil, errors.Wrap(err, "could not open tendermint state database, is the node still running?")
+	}
+	defer tmStateDB.Close()
+	stateDB, err := dbm.NewDB(stateDBName, backend, config.DBDir())
+	if err != nil {
+		return nil, errors.Wrap(err, "could not open state database, is the node still running?")
+	}
+	defer stateDB.Close()
+	return replay(genesisDoc, blockStoreDB, tmStateDB, stateDB, from, to, func() (dbm.DB, error) {
+		return newReplayDB(backend, dbDir)
+	})
+}
+
+// replay the blocks from to to of a node with the databases given into the database newDB returns
+func replay(genesisDoc *types.GenesisDoc, blockStoreDB, tmStateDB, stateDB dbm.DB, from, to uint64,
+	newDB func() (dbm.DB, error)) (*ReplayResult, error) {
+	bc, err := blockchain.LoadOrNewBlockchain(stateDB, genesisDoc)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not load blockchain state")
+	}
+	if !bc.HasBlocks() {
+		return nil, errors.New("no blocks have been committed so there is nothing to replay")
+	}
+	blockStore := blockchain.NewBlockStore(blockStoreDB)
+	base, err := blockStore.Base()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read block store")
+	}
+	// the block store may be a block ahead of the application if the node stopped between the two committing
+	last, err := blockStore.Height()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read block store")
+	}
+	if bc.LastBlockHeight() < last {
+		last = bc.LastBlockHeight()
+	}
+	if from == 0 {
+		from = bc.InitialHeight()
+	}
+	if to == 0 {
+		to = last
+	}
+	if from < base || to > last || from > to {
+		return nil, errors.Errorf("cannot replay blocks %d to %d, blocks %d to %d are stored and committed", from, to,
+			base, last)
+	}
+
+	replayDB, err := newDB()
+	if err != nil {
+		return nil, err
+	}
+	defer replayDB.Close()
+	replayBC, err := blockchain.LoadOrNewBlockchain(replayDB, genesisDoc)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create blockchain state for replay")
+	}
+	var replaySt *state.State
+	if from == bc.InitialHeight() {
+		replaySt, err = state.NewInitialState(replayDB, from)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not create state for replay")
+		}
+	} else {
+		replaySt, err = copyStateBefore(stateDB, replayDB, replayBC, blockStore, from)
+		if err != nil {
+			return nil, err
+		}
+	}
+	app := newABCIApp("replay", replayBC, replaySt)
+	if from == bc.InitialHeight() {
+		if err := initChain(app, genesisDoc); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &ReplayResult{From: from, To: to}
+	for height := from; height <= to; height++ {
+		block, err := blockStore.LoadBlock(height)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not load block %d", height)
+		}
+		if block == nil {
+			return nil, errors.Errorf("block %d is not in the block store", height)
+		}
+		expectedAppHash, err := appHashAfter(blockStore, bc, height)
+		if err != nil {
+			return nil, err
+		}
+		responses, appHash, err := replayBlock(app, block)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not replay block %d", height)
+		}
+		result.AppHash = appHash
+		tx, err := compareTxs(tmStateDB, block, responses)
+		if err != nil {
+			return nil, err
+		}
+		if tx != nil || !bytes.Equal(appHash, expectedAppHash) {
+			result.To = height
+			result.Divergence = &Divergence{
+				Height:          height,
+				ExpectedAppHash: expectedAppHash,
+				AppHash:         appHash,
+				Tx:              tx,
+			}
+			return result, nil
+		}
+	}
+	return result, nil
+}
+
+func newReplayDB(backend dbm.BackendType, dbDir string) (dbm.DB, error) {
+	if dbDir == "" {
+		return dbm.NewMemDB(), nil
+	}
+	db, err := dbm.NewDB(stateDBName, backend, dbDir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not open replay database in %s", dbDir)
+	}
+	it, err := db.Iterator(nil, nil)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	empty := !it.Valid()
+	it.Close()
+	if !empty {
+		db.Close()
+		return nil, errors.Errorf("%s already holds a state database, replay into an empty directory", dbDir)
+	}
+	return db, nil
+}
+
+// copyStateBefore copies the state the node committed before height into replayDB, checking it against the app
+// hash that block height recorded
+func copyStateBefore(stateDB, replayDB dbm.DB, replayBC *blockchain.Blockchain, blockStore *blockchain.BlockStore,
+	height uint64) (*state.State, error) {
+	st, err := state.LoadState(stateDB, 0)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not load state")
+	}
+	if !st.VersionExists(int64(height - 1)) {
+		return nil, errors.Errorf("state at height %d is not retained so cannot replay from height %d", height-1,
+			height)
+	}
+	replaySt, err := state.CopyState(replayDB, st, int64(height-1))
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not copy state at height %d", height-1)
+	}
+	block, err := blockStore.LoadBlock(height)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not load block %d", height)
+	}
+	if block == nil {
+		return nil, errors.Errorf("block %d is not in the block store", height)
+	}
+	if !bytes.Equal(replaySt.Hash(), block.AppHash) {
+		return nil, errors.Errorf("state at height %d has hash %X but block %d records app hash %X", height-1,
+			replaySt.Hash(), height, block.AppHash)
+	}
+	previous, err := blockStore.LoadBlock(height - 1)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not load block %d", height-1)
+	}
+	blockTime := block.Time
+	if previous != nil {
+		blockTime = previous.Time
+	}
+	if err := replayBC.Reset(height-1, blockTime, block.AppHash); err != nil {
+		return nil, errors.Wrap(err, "could not save blockchain state for replay")
+	}
+	return replaySt, nil
+}
+
+// appHashAfter returns the app hash the chain recorded after the block at height, which is carried by the header
+// of the next block or, for the last block, by the application
+func appHashAfter(blockStore *blockchain.BlockStore, bc *blockchain.Blockchain, height uint64) ([]byte, error) {
+	if height == bc.LastBlockHeight() {
+		return bc.AppHashAfterLastBlock(), nil
+	}
+	next, err := blockStore.LoadBlock(height + 1)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not load block %d", height+1)
+	}
+	if next == nil {
+		return nil, errors.Errorf("block %d is not in the block store", height+1)
+	}
+	return next.AppHash, nil
+}
+
+// initChain passes the genesis to app as Tendermint does on the first start of a node
+func initChain(app *abci.App, genesisDoc *types.GenesisDoc) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.Errorf("could not initialise chain: %v", r)
+		}
+	}()
+	validators := make([]*types.Validator, len(genesisDoc.Validators))
+	for i, val := range genesisDoc.Validators {
+		validators[i] = types.NewValidator(val.PubKey, val.Power)
+	}
+	params := genesisDoc.ConsensusParams.ToProto()
+	app.InitChain(abcitypes.RequestInitChain{
+		Time:            genesisDoc.GenesisTime,
+		ChainId:         genesisDoc.ChainID,
+		InitialHeight:   genesisDoc.InitialHeight,
+		ConsensusParams: &params,
+		Validators:      types.TM2PB.ValidatorUpdates(types.NewValidatorSet(validators)),
+		AppStateBytes:   genesisDoc.AppState,
+	})
+	return nil
+}
+
+// replayBlock runs block through app returning the result of each transaction and the app hash
+func replayBlock(app *abci.App, block *types.Block) (responses []abcitypes.ResponseDeliverTx, appHash []byte,
+	err error) {
+	// the app panics on any failure so as to halt the node
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	app.BeginBlock(abcitypes.RequestBeginBlock{
+		Hash:   block.Hash(),
+		Header: *block.Header.ToProto(),
+	})
+	for _, tx := range block.Txs {
+		responses = append(responses, app.DeliverTx(abcitypes.RequestDeliverTx{Tx: tx}))
+	}
+	app.EndBlock(abcitypes.RequestEndBlock{Height: block.Height})
+	return responses, app.Commit().Data, nil
+}
+
+// compareTxs returns the first transaction of block whose replayed result differs in its deterministic fields from
+// the one Tendermint saved, nil if they all agree or none were saved
+func compareTxs(tmStateDB dbm.DB, block *types.Block, responses []abcitypes.ResponseDeliverTx) (*TxDivergence,
+	error) {
+	saved, err := blockchain.LoadABCIResponses(tmStateDB, uint64(block.Height))
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not load results of block %d", block.Height)
+	}
+	if saved == nil {
+		return nil, nil
+	}
+	if len(saved.DeliverTxs) != len(responses) {
+		return nil, errors.Errorf("block %d has %d transactions but results of %d were saved", block.Height,
+			len(responses), len(saved.DeliverTxs))
+	}
+	for i, rsp := range responses {
+		expected := saved.DeliverTxs[i]
+		var reason string
+		switch {
+		case expected == nil:
+			continue
+		case rsp.Code != expected.Code:
+			reason = fmt.Sprintf("code %d was recorded but replay returned %d", expected.Code, rsp.Code)
+		case !bytes.Equal(rsp.Data, expected.Data):
+			reason = fmt.Sprintf("data %X was recorded but replay returned %X", expected.Data, rsp.Data)
+		case rsp.GasWanted != expected.GasWanted:
+			reason = fmt.Sprintf("gas wanted %d was recorded but replay returned %d", expected.GasWanted,
+				rsp.GasWanted)
+		case rsp.GasUsed != expected.GasUsed:
+			reason = fmt.Sprintf("gas used %d was recorded but replay returned %d", expected.GasUsed, rsp.GasUsed)
+		default:
+			continue
+		}
+		return &TxDivergence{
+			Index:  i,
+			Hash:   block.Txs[i].Hash(),
+			Reason: reason,
+		}, nil
+	}
+	return nil, nil
+}
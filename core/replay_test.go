@@ -0,0 +1,182 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/google/orderedcode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/sunvim/yaoguang/blockchain"
+	"github.com/sunvim/yaoguang/crypto"
+	"github.com/sunvim/yaoguang/genesis"
+	"github.com/sunvim/yaoguang/state"
+	"github.com/sunvim/yaoguang/txs"
+	"github.com/sunvim/yaoguang/txs/payload"
+	abcitypes "github.com/tendermint/tendermint/abci/types"
+	tmstate "github.com/tendermint/tendermint/proto/tendermint/state"
+	"github.com/tendermint/tendermint/types"
+	dbm "github.com/tendermint/tm-db"
+)
+
+var (
+	alice = crypto.PrivateKeyFromSecret("alice", crypto.CurveTypeEd25519)
+	bob   = crypto.PrivateKeyFromSecret("bob", crypto.CurveTypeEd25519)
+)
+
+// testChain is a node that has committed blocks to its databases the way Tendermint lays them out
+type testChain struct {
+	genesisDoc   *types.GenesisDoc
+	blockStoreDB dbm.DB
+	tmStateDB    dbm.DB
+	stateDB      dbm.DB
+	appHash      []byte
+}
+
+// newTestChain commits blocks each holding a transfer from alice to bob
+func newTestChain(t *testing.T, blocks int) *testChain {
+	appState, err := json.Marshal(genesis.AppState{
+		Accounts: []genesis.Account{{Address: alice.GetAddress(), PublicKey: alice.GetPublicKey(), Balance: 1000}},
+	})
+	require.NoError(t, err)
+	genesisDoc := &types.GenesisDoc{
+		ChainID:         testChainID,
+		GenesisTime:     time.Now().UTC(),
+		InitialHeight:   1,
+		ConsensusParams: types.DefaultConsensusParams(),
+		Validators: []types.GenesisValidator{{
+			PubKey: alice.GetPublicKey().TendermintPubKey(),
+			Power:  10,
+		}},
+		AppState: appState,
+	}
+	require.NoError(t, genesisDoc.ValidateAndComplete())
+	chain := &testChain{
+		genesisDoc:   genesisDoc,
+		blockStoreDB: dbm.NewMemDB(),
+		tmStateDB:    dbm.NewMemDB(),
+		stateDB:      dbm.NewMemDB(),
+	}
+	bc, err := blockchain.NewBlockchain(chain.stateDB, genesisDoc)
+	require.NoError(t, err)
+	st, err := state.NewInitialState(chain.stateDB, 1)
+	require.NoError(t, err)
+	app := newABCIApp("test", bc, st)
+	require.NoError(t, initChain(app, genesisDoc))
+
+	codec := txs.NewJSONCodec()
+	for height := int64(1); height <= int64(blocks); height++ {
+		send := payload.NewSendTx()
+		send.AddInput(alice.GetAddress(), 10, uint64(height))
+		send.AddOutput(bob.GetAddress(), 10)
+		txEnv := txs.Enclose(testChainID, send)
+		require.NoError(t, txEnv.Sign(&alice))
+		tx, err := codec.EncodeTx(txEnv)
+		require.NoError(t, err)
+
+		block := types.MakeBlock(height, []types.Tx{tx}, &types.Commit{}, nil)
+		block.ChainID = testChainID
+		block.Time = genesisDoc.GenesisTime.Add(time.Duration(height) * time.Second)
+		block.AppHash = chain.appHash
+		block.ProposerAddress = alice.GetPublicKey().TendermintPubKey().Address()
+		responses, appHash, err := replayBlock(app, block)
+		require.NoError(t, err)
+		require.Zero(t, responses[0].Code, responses[0].Log)
+		chain.saveBlock(t, block)
+		chain.saveResponses(t, height, responses)
+		chain.appHash = appHash
+	}
+	return chain
+}
+
+func (chain *testChain) saveBlock(t *testing.T, block *types.Block) {
+	parts := block.MakePartSet(types.BlockPartSizeBytes)
+	meta := types.NewBlockMeta(block, parts).ToProto()
+	bs, err := proto.Marshal(meta)
+	require.NoError(t, err)
+	require.NoError(t, chain.blockStoreDB.Set(orderedKey(t, 0, block.Height), bs))
+	for i := 0; i < int(parts.Total()); i++ {
+		part, err := parts.GetPart(i).ToProto()
+		require.NoError(t, err)
+		bs, err := proto.Marshal(part)
+		require.NoError(t, err)
+		require.NoError(t, chain.blockStoreDB.Set(orderedKey(t, 1, block.Height, int64(i)), bs))
+	}
+}
+
+func (chain *testChain) saveResponses(t *testing.T, height int64, responses []abcitypes.ResponseDeliverTx) {
+	saved := new(tmstate.ABCIResponses)
+	for i := range responses {
+		saved.DeliverTxs = append(saved.DeliverTxs, &responses[i])
+	}
+	bs, err := saved.Marshal()
+	require.NoError(t, err)
+	require.NoError(t, chain.tmStateDB.Set(orderedKey(t, 7, height), bs))
+}
+
+func (chain *testChain) replay(from, to uint64) (*ReplayResult, error) {
+	return replay(chain.genesisDoc, chain.blockStoreDB, chain.tmStateDB, chain.stateDB, from, to,
+		func() (dbm.DB, error) {
+			return dbm.NewMemDB(), nil
+		})
+}
+
+// orderedKey is a key of Tendermint's block and state stores
+func orderedKey(t *testing.T, prefix int64, parts ...int64) []byte {
+	key, err := orderedcode.Append(nil, prefix)
+	require.NoError(t, err)
+	for _, part := range parts {
+		key, err = orderedcode.Append(key, part)
+		require.NoError(t, err)
+	}
+	return key
+}
+
+func TestReplay(t *testing.T) {
+	chain := newTestChain(t, 4)
+
+	// From genesis
+	result, err := chain.replay(0, 0)
+	require.NoError(t, err)
+	assert.Nil(t, result.Divergence)
+	assert.Equal(t, uint64(1), result.From)
+	assert.Equal(t, uint64(4), result.To)
+	assert.Equal(t, chain.appHash, []byte(result.AppHash))
+
+	// From the state the node committed before a block part way along the chain
+	result, err = chain.replay(3, 4)
+	require.NoError(t, err)
+	assert.Nil(t, result.Divergence)
+	assert.Equal(t, chain.appHash, []byte(result.AppHash))
+
+	_, err = chain.replay(2, 5)
+	assert.Error(t, err, "block 5 has not been committed")
+}
+
+func TestReplay_Divergence(t *testing.T) {
+	chain := newTestChain(t, 4)
+	saved, err := blockchain.LoadABCIResponses(chain.tmStateDB, 3)
+	require.NoError(t, err)
+	saved.DeliverTxs[0].GasUsed++
+	bs, err := saved.Marshal()
+	require.NoError(t, err)
+	require.NoError(t, chain.tmStateDB.Set(orderedKey(t, 7, 3), bs))
+
+	result, err := chain.replay(2, 0)
+	require.NoError(t, err)
+	require.NotNil(t, result.Divergence)
+	assert.Equal(t, uint64(3), result.To)
+	assert.Equal(t, uint64(3), result.Divergence.Height)
+	// The state still matches, only the recorded result of the transaction does not
+	assert.Equal(t, result.Divergence.ExpectedAppHash, result.Divergence.AppHash)
+	require.NotNil(t, result.Divergence.Tx)
+	assert.Equal(t, 0, result.Divergence.Tx.Index)
+	assert.Contains(t, result.Divergence.Tx.Reason, "gas used")
+
+	bs, err = json.Marshal(result)
+	require.NoError(t, err)
+	assert.Contains(t, string(bs), `"divergence":{"height":3,"expected_app_hash":`)
+	assert.Contains(t, string(bs), `"tx":{"index":0,`)
+}
@@ -0,0 +1,127 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package core
+
+import (
+	"bytes"
+
+	"github.com/pkg/errors"
+	"github.com/sunvim/yaoguang/blockchain"
+	"github.com/sunvim/yaoguang/state"
+	tmcommands "github.com/tendermint/tendermint/cmd/tendermint/commands"
+	cfg "github.com/tendermint/tendermint/config"
+	"github.com/tendermint/tendermint/types"
+	dbm "github.com/tendermint/tm-db"
+)
+
+// Rollback reverts the application state and blockchain state of a stopped node to the block at height, deleting
+// the state of later blocks, and returns the app hash after that block. Tendermint's state is rolled back by one
+// block, as tendermint rollback does, so when the node is next started Tendermint replays the blocks after height
+// through the application and applies its last block again rather than expecting the app hash it recorded for it.
+func Rollback(configFile string, height uint64) ([]byte, error) {
+	config, err := loadConfig(configFile)
+	if err != nil {
+		return nil, err
+	}
+	genesisDoc, err := loadGenesis(config)
+	if err != nil {
+		return nil, err
+	}
+	stateDB, err := dbm.NewDB(stateDBName, dbm.BackendType(config.DBBackend), config.DBDir())
+	if err != nil {
+		return nil, errors.Wrap(err, "could not open state database, is the node still running?")
+	}
+	defer stateDB.Close()
+
+	bc, err := blockchain.LoadOrNewBlockchain(stateDB, genesisDoc)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not load blockchain state")
+	}
+	if !bc.HasBlocks() {
+		return nil, errors.New("no blocks have been committed so there is nothing to roll back")
+	}
+	st, err := state.NewState(stateDB)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not load state")
+	}
+	// the state is rolled back before the blockchain state, so after an interrupted rollback the state may already be
+	// at height while the blockchain still records the later blocks, checking against the blockchain lets the same
+	// rollback be run again
+	last := bc.LastBlockHeight()
+	if height < bc.InitialHeight() || height >= last {
+		return nil, errors.Errorf("cannot roll back to height %d, blocks %d to %d have been committed", height,
+			bc.InitialHeight(), last)
+	}
+	if !st.VersionExists(int64(height)) {
+		return nil, errors.Errorf("state at height %d is not retained so cannot be rolled back to", height)
+	}
+	block, next, err := loadRollbackBlocks(config, height)
+	if err != nil {
+		return nil, err
+	}
+	appHash, err := st.HashAt(int64(height))
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read state at height %d", height)
+	}
+	if !bytes.Equal(appHash, next.AppHash) {
+		return nil, errors.Errorf("state at height %d has hash %X but block %d records app hash %X", height,
+			appHash, height+1, next.AppHash)
+	}
+
+	// Tendermint leaves its state alone if it has already been rolled back, so this can be run again too
+	if _, _, err := tmcommands.RollbackState(config); err != nil {
+		return nil, errors.Wrap(err, "could not roll back tendermint state")
+	}
+	if err := st.Rollback(int64(height)); err != nil {
+		return nil, err
+	}
+	if err := bc.Reset(height, block.Time, appHash); err != nil {
+		return nil, errors.Wrap(err, "could not save blockchain state")
+	}
+	return appHash, nil
+}
+
+// loadRollbackBlocks loads the block at height and the block after it, which records the app hash after height.
+// Tendermint replays the blocks after height from its block store so they must all be there.
+func loadRollbackBlocks(config *cfg.Config, height uint64) (block, next *types.Block, err error) {
+	blockStoreDB, err := dbm.NewDB(blockchain.BlockStoreDBName, dbm.BackendType(config.DBBackend), config.DBDir())
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "could not open block store, is the node still running?")
+	}
+	defer blockStoreDB.Close()
+	blockStore := blockchain.NewBlockStore(blockStoreDB)
+	base, err := blockStore.Base()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "could not read block store")
+	}
+	if base == 0 || base > height {
+		return nil, nil, errors.Errorf("block %d is not in the block store, which starts at block %d", height, base)
+	}
+	block, err = blockStore.LoadBlock(height)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "could not load block %d", height)
+	}
+	next, err = blockStore.LoadBlock(height + 1)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "could not load block %d", height+1)
+	}
+	if block == nil || next == nil {
+		return nil, nil, errors.Errorf("blocks %d and %d must be in the block store to roll back", height, height+1)
+	}
+	return block, next, nil
+}
@@ -0,0 +1,46 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/google/orderedcode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/sunvim/yaoguang/blockchain"
+	tmstate "github.com/tendermint/tendermint/proto/tendermint/state"
+	dbm "github.com/tendermint/tm-db"
+)
+
+func TestRollback(t *testing.T) {
+	configFile := newTestHome(t)
+	last := runNode(t, configFile, 5)
+
+	_, err := Rollback(configFile, uint64(last))
+	assert.Error(t, err, "the last block cannot be rolled back to")
+
+	appHash, err := Rollback(configFile, 2)
+	require.NoError(t, err)
+	assert.NotEmpty(t, appHash)
+
+	// Tendermint applies its last block again rather than expect the app hash it recorded after it
+	config, err := loadConfig(configFile)
+	require.NoError(t, err)
+	assert.Equal(t, last-1, loadTendermintState(t, config.DBDir()).LastBlockHeight)
+
+	// Blocks 3 to last are replayed when the node is started again, and it carries on from there
+	assert.Greater(t, runNode(t, configFile, last+2), last+1)
+}
+
+func loadTendermintState(t *testing.T, dbDir string) *tmstate.State {
+	db, err := dbm.NewGoLevelDB(blockchain.TendermintStateDBName, dbDir)
+	require.NoError(t, err)
+	defer db.Close()
+	// the key Tendermint saves its state under
+	key, err := orderedcode.Append(nil, int64(8))
+	require.NoError(t, err)
+	bs, err := db.Get(key)
+	require.NoError(t, err)
+	st := new(tmstate.State)
+	require.NoError(t, st.Unmarshal(bs))
+	return st
+}
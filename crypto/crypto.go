@@ -69,6 +69,12 @@ type Signer interface {
 	Sign(msg []byte) (*Signature, error)
 }
 
+// AddressableSigner can sign on behalf of the address it exposes
+type AddressableSigner interface {
+	Addressable
+	Signer
+}
+
 // Signable is an interface for all signable things.
 // It typically removes signatures before serializing.
 type Signable interface {
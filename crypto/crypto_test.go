@@ -40,6 +40,8 @@ func TestSigning(t *testing.T) {
 			require.NoError(t, err)
 			err = pk.GetPublicKey().Verify(msg, sig)
 			require.NoError(t, err)
+			err = pk.GetPublicKey().Verify([]byte("Flipity flobity flop"), sig)
+			require.Error(t, err)
 		})
 
 	}
@@ -63,3 +65,25 @@ func TestSigning(t *testing.T) {
 		require.NoError(t, err)
 	})
 }
+
+func TestTendermintKeys(t *testing.T) {
+	for _, curveType := range []CurveType{CurveTypeEd25519, CurveTypeSecp256k1} {
+		privateKey, err := GeneratePrivateKey(nil, curveType)
+		require.NoError(t, err)
+		tmPrivKey, err := privateKey.TendermintPrivateKey()
+		require.NoError(t, err)
+		tmPubKey := privateKey.GetPublicKey().TendermintPubKey()
+		require.NotNil(t, tmPubKey)
+		assert.Equal(t, tmPrivKey.PubKey(), tmPubKey)
+		assert.Equal(t, tmPubKey.Address(), privateKey.GetPublicKey().TendermintAddress())
+
+		publicKey, err := PublicKeyFromTendermintPubKey(tmPubKey)
+		require.NoError(t, err)
+		assert.Equal(t, privateKey.GetPublicKey(), publicKey)
+		abciPubKey, err := publicKey.ABCIPubKey()
+		require.NoError(t, err)
+		publicKey, err = PublicKeyFromABCIPubKey(abciPubKey)
+		require.NoError(t, err)
+		assert.Equal(t, privateKey.GetPublicKey(), publicKey)
+	}
+}
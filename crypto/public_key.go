@@ -1,6 +1,7 @@
 package crypto
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 
@@ -84,10 +85,15 @@ func (p *PublicKey) Verify(msg []byte, signature *Signature) error {
 		return fmt.Errorf("signature '%X' is not a valid ed25519 signature for message: %s",
 			signature.Signature, string(msg))
 	case CurveTypeSecp256k1:
-		_, _, err := btcec.RecoverCompact(btcec.S256(), signature.Signature, Keccak256(msg))
+		pub, _, err := btcec.RecoverCompact(btcec.S256(), signature.Signature, Keccak256(msg))
 		if err != nil {
 			return fmt.Errorf("signature verification for secp256k1 key failed: %v", err)
 		}
+		// Any well-formed signature recovers some key, it must be this one
+		if !bytes.Equal(pub.SerializeUncompressed(), p.PublicKey) {
+			return fmt.Errorf("signature '%X' is not a valid secp256k1 signature by %X for message: %s",
+				signature.Signature, p.PublicKey, string(msg))
+		}
 		return nil
 	default:
 		return fmt.Errorf("invalid curve type")
@@ -6,7 +6,9 @@ import (
 	"github.com/btcsuite/btcd/btcec"
 	tmCrypto "github.com/tendermint/tendermint/crypto"
 	tmEd25519 "github.com/tendermint/tendermint/crypto/ed25519"
+	tmEncoding "github.com/tendermint/tendermint/crypto/encoding"
 	tmSecp256k1 "github.com/tendermint/tendermint/crypto/secp256k1"
+	tmProtoCrypto "github.com/tendermint/tendermint/proto/tendermint/crypto"
 )
 
 func PublicKeyFromTendermintPubKey(pubKey tmCrypto.PubKey) (*PublicKey, error) {
@@ -14,20 +16,47 @@ func PublicKeyFromTendermintPubKey(pubKey tmCrypto.PubKey) (*PublicKey, error) {
 	case tmEd25519.PubKey:
 		return PublicKeyFromBytes(pk[:], CurveTypeEd25519)
 	case tmSecp256k1.PubKey:
-		return PublicKeyFromBytes(pk[:], CurveTypeSecp256k1)
+		// Tendermint uses the compressed form
+		pub, err := btcec.ParsePubKey(pk, btcec.S256())
+		if err != nil {
+			return nil, fmt.Errorf("could not parse tendermint secp256k1 public key: %w", err)
+		}
+		return PublicKeyFromBytes(pub.SerializeUncompressed(), CurveTypeSecp256k1)
 	default:
 		return nil, fmt.Errorf("unrecognised tendermint public key type: %v", pk)
 	}
 }
 
+// PublicKeyFromABCIPubKey converts the public key of a validator update
+func PublicKeyFromABCIPubKey(pk tmProtoCrypto.PublicKey) (*PublicKey, error) {
+	pubKey, err := tmEncoding.PubKeyFromProto(pk)
+	if err != nil {
+		return nil, err
+	}
+	return PublicKeyFromTendermintPubKey(pubKey)
+}
+
 // PublicKey extensions
 
+// ABCIPubKey is the public key as it appears in validator updates
+func (p PublicKey) ABCIPubKey() (tmProtoCrypto.PublicKey, error) {
+	pubKey := p.TendermintPubKey()
+	if pubKey == nil {
+		return tmProtoCrypto.PublicKey{}, fmt.Errorf("no tendermint public key for curve type %v", p.CurveType)
+	}
+	return tmEncoding.PubKeyToProto(pubKey)
+}
+
 func (p PublicKey) TendermintPubKey() tmCrypto.PubKey {
 	switch p.CurveType {
 	case CurveTypeEd25519:
 		return tmEd25519.PubKey(p.PublicKey)
 	case CurveTypeSecp256k1:
-		return tmSecp256k1.PubKey(p.PublicKey)
+		pub, err := btcec.ParsePubKey(p.PublicKey, btcec.S256())
+		if err != nil {
+			return nil
+		}
+		return tmSecp256k1.PubKey(pub.SerializeCompressed())
 	default:
 		return nil
 	}
@@ -38,13 +67,27 @@ func (p PublicKey) TendermintAddress() tmCrypto.Address {
 	case CurveTypeEd25519:
 		return tmCrypto.Address(p.GetAddress().Bytes())
 	case CurveTypeSecp256k1:
-		// Tendermint represents addresses like Bitcoin
-		return tmCrypto.Address(RIPEMD160(SHA256(p.PublicKey[:])))
+		// Tendermint represents addresses like Bitcoin, from the compressed public key
+		return p.TendermintPubKey().Address()
 	default:
 		panic(fmt.Sprintf("unknown CurveType %d", p.CurveType))
 	}
 }
 
+// PrivateKey extensions
+
+// TendermintPrivateKey converts the key for use as a Tendermint validator or node key
+func (p PrivateKey) TendermintPrivateKey() (tmCrypto.PrivKey, error) {
+	switch p.CurveType {
+	case CurveTypeEd25519:
+		return tmEd25519.PrivKey(p.PrivateKey), nil
+	case CurveTypeSecp256k1:
+		return tmSecp256k1.PrivKey(p.PrivateKey), nil
+	default:
+		return nil, ErrInvalidCurve(p.CurveType)
+	}
+}
+
 // Signature extensions
 
 func (sig Signature) TendermintSignature() []byte {
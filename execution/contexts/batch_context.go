@@ -0,0 +1,115 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package contexts
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/sunvim/yaoguang/codes"
+	"github.com/sunvim/yaoguang/execution/exec"
+	"github.com/sunvim/yaoguang/state"
+	"github.com/sunvim/yaoguang/txs"
+	"github.com/sunvim/yaoguang/txs/payload"
+)
+
+// BatchContext runs each step of a BatchTx with the context for its type. Every step writes to the same cache, which
+// the executor throws away if a step fails.
+type BatchContext struct {
+	Contexts map[payload.Type]Context
+	// CheckPermissions is run before each step
+	CheckPermissions func(p payload.Payload, st *state.Cache) error
+}
+
+// ErrBatchStep reports the step of a batch that failed, it carries the code of the step's error
+type ErrBatchStep struct {
+	Index int
+	Type  payload.Type
+	Err   error
+}
+
+func (e ErrBatchStep) Error() string {
+	return fmt.Sprintf("batch step %d (%v) failed: %v", e.Index, e.Type, e.Err)
+}
+
+func (e ErrBatchStep) Code() *codes.Code {
+	return codes.FromError(e.Err)
+}
+
+func (e ErrBatchStep) Unwrap() error {
+	return e.Err
+}
+
+func (ctx *BatchContext) Execute(txe *exec.TxExecution, p payload.Payload, st *state.Cache) error {
+	tx, ok := p.(*payload.BatchTx)
+	if !ok {
+		return codes.InvalidTx.Errorf("payload must be BatchTx, but is: %v", p)
+	}
+	if len(tx.Payloads) == 0 {
+		return codes.InvalidTx.Errorf("batch has no steps")
+	}
+	signers := make(map[string]bool, len(tx.Inputs))
+	for _, input := range tx.Inputs {
+		if input.Amount > 0 {
+			return codes.InvalidTx.Errorf("batch inputs do not take an amount, put it in the step")
+		}
+		signers[string(input.Address.Bytes())] = true
+	}
+	for i, step := range tx.Payloads {
+		if step == nil || step.Payload == nil {
+			return ErrBatchStep{Index: i, Err: codes.InvalidTx.Errorf("empty step")}
+		}
+		if err := txs.CheckInputs(step.Payload); err != nil {
+			return ErrBatchStep{Index: i, Type: step.Type(), Err: err}
+		}
+		for _, input := range step.GetInputs() {
+			if !signers[string(input.Address.Bytes())] {
+				return ErrBatchStep{Index: i, Type: step.Type(),
+					Err: codes.InvalidTx.Errorf("input %v is not an input of the batch", input.Address)}
+			}
+			if input.Sequence != 0 {
+				return ErrBatchStep{Index: i, Type: step.Type(),
+					Err: codes.InvalidTx.Errorf("step inputs must not carry a sequence")}
+			}
+		}
+	}
+
+	for i, step := range tx.Payloads {
+		if err := ctx.step(txe, step.Payload, st); err != nil {
+			return ErrBatchStep{Index: i, Type: step.Type(), Err: err}
+		}
+		txe.Event("batch",
+			"step", strconv.Itoa(i),
+			"type", step.Type().String())
+	}
+	return nil
+}
+
+func (ctx *BatchContext) step(txe *exec.TxExecution, p payload.Payload, st *state.Cache) error {
+	if p.Type() == payload.TypeBatch {
+		return codes.UnsupportedTxType.Errorf("batches cannot be nested")
+	}
+	stepCtx, ok := ctx.Contexts[p.Type()]
+	if !ok {
+		return codes.UnsupportedTxType.Errorf("unsupported transaction type %v", p.Type())
+	}
+	if err := ctx.CheckPermissions(p, st); err != nil {
+		return err
+	}
+	return stepCtx.Execute(txe, p, st)
+}
@@ -0,0 +1,30 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package contexts
+
+import (
+	"github.com/sunvim/yaoguang/execution/exec"
+	"github.com/sunvim/yaoguang/state"
+	"github.com/sunvim/yaoguang/txs/payload"
+)
+
+// Context executes one type of payload against a transaction-scoped state cache. The cache is only written back
+// when Execute returns nil so a context may leave it in any state on error.
+type Context interface {
+	Execute(txe *exec.TxExecution, p payload.Payload, st *state.Cache) error
+}
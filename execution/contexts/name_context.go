@@ -0,0 +1,159 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package contexts
+
+import (
+	"strconv"
+
+	"github.com/sunvim/yaoguang/binary"
+	"github.com/sunvim/yaoguang/codes"
+	"github.com/sunvim/yaoguang/execution/exec"
+	"github.com/sunvim/yaoguang/names"
+	"github.com/sunvim/yaoguang/state"
+	"github.com/sunvim/yaoguang/txs/payload"
+)
+
+const nameEventType = "name"
+
+// NameContext maintains the name registry. The lease fee is burnt.
+type NameContext struct{}
+
+func (ctx *NameContext) Execute(txe *exec.TxExecution, p payload.Payload, st *state.Cache) error {
+	tx, ok := p.(*payload.NameTx)
+	if !ok {
+		return codes.InvalidTx.Errorf("payload must be NameTx, but is: %v", p)
+	}
+	if err := names.ValidateName(tx.Name); err != nil {
+		return err
+	}
+	if err := names.ValidateData(tx.Data); err != nil {
+		return err
+	}
+	txe.UseGas(exec.GasNameUpdate)
+
+	entry, err := st.GetName(tx.Name)
+	if err != nil {
+		return err
+	}
+	height := txe.Height
+	costPerBlock := names.CostPerBlock(tx.Name, tx.Data)
+	registered := entry != nil && !entry.Expired(height)
+	if registered && entry.Owner != tx.Input.Address {
+		return codes.PermissionDenied.Errorf("name %s is owned by %v not %v", tx.Name, entry.Owner,
+			tx.Input.Address)
+	}
+
+	acc, err := st.GetAccount(tx.Input.Address)
+	if err != nil {
+		return err
+	}
+	if acc == nil {
+		return codes.UnknownAccount.Errorf("input account %v does not exist", tx.Input.Address)
+	}
+	if err := acc.SubtractFromBalance(tx.Input.Amount); err != nil {
+		return err
+	}
+	if err := st.UpdateAccount(acc); err != nil {
+		return err
+	}
+
+	if !registered {
+		if tx.Release || tx.NewOwner != nil {
+			return codes.UnknownName.Errorf("name %s is not registered", tx.Name)
+		}
+		blocks := tx.Input.Amount / costPerBlock
+		if blocks < names.MinLeaseBlocks {
+			return codes.InsufficientFunds.Errorf("fee %d buys a lease of %d blocks at %d per block but names must "+
+				"be registered for at least %d blocks", tx.Input.Amount, blocks, costPerBlock, names.MinLeaseBlocks)
+		}
+		entry = &names.Entry{
+			Name:    tx.Name,
+			Owner:   tx.Input.Address,
+			Data:    tx.Data,
+			Expires: height + blocks,
+		}
+		return ctx.update(txe, st, entry, "register")
+	}
+
+	if tx.Release {
+		if tx.Input.Amount > 0 {
+			return codes.InvalidTx.Errorf("releasing name %s does not take a fee", tx.Name)
+		}
+		if err := st.RemoveName(tx.Name); err != nil {
+			return err
+		}
+		txe.Event(nameEventType,
+			"action", "release",
+			"name", entry.Name,
+			"owner", entry.Owner.String())
+		return nil
+	}
+
+	// Carry over what is left of the lease at the old rate and extend it at the rate for the new data
+	credit := (entry.Expires - height) * names.CostPerBlock(entry.Name, entry.Data)
+	if binary.IsUint64SumOverflow(credit, tx.Input.Amount) {
+		return codes.IntegerOverflow.Errorf("lease credit for name %s overflows", tx.Name)
+	}
+	credit += tx.Input.Amount
+	entry.Data = tx.Data
+	entry.Expires = height + credit/costPerBlock
+	if entry.Expired(height) {
+		return codes.InsufficientFunds.Errorf("lease credit %d does not cover a block of name %s at %d per block",
+			credit, tx.Name, costPerBlock)
+	}
+	action := "update"
+	if tx.NewOwner != nil && *tx.NewOwner != entry.Owner {
+		entry.Owner = *tx.NewOwner
+		action = "transfer"
+	}
+	return ctx.update(txe, st, entry, action)
+}
+
+func (ctx *NameContext) update(txe *exec.TxExecution, st *state.Cache, entry *names.Entry, action string) error {
+	if err := st.UpdateName(entry); err != nil {
+		return err
+	}
+	txe.Event(nameEventType,
+		"action", action,
+		"name", entry.Name,
+		"owner", entry.Owner.String(),
+		"expires", strconv.FormatUint(entry.Expires, 10))
+	return nil
+}
+
+// ExpireNames removes the names whose leases will have run out by the next block
+func ExpireNames(be *exec.BlockExecution, st *state.Cache) error {
+	var expired []*names.Entry
+	err := st.IterateExpiredNames(be.Height+1, func(entry *names.Entry) error {
+		expired = append(expired, entry)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, entry := range expired {
+		if err := st.RemoveName(entry.Name); err != nil {
+			return err
+		}
+		be.Event(nameEventType,
+			"action", "expire",
+			"name", entry.Name,
+			"owner", entry.Owner.String())
+	}
+	return nil
+}
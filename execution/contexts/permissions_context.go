@@ -0,0 +1,118 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package contexts
+
+import (
+	"strconv"
+
+	"github.com/sunvim/yaoguang/codes"
+	"github.com/sunvim/yaoguang/execution/exec"
+	"github.com/sunvim/yaoguang/permission"
+	"github.com/sunvim/yaoguang/state"
+	"github.com/sunvim/yaoguang/txs/payload"
+)
+
+// PermissionsContext changes global or account permissions. The executor has already checked the input holds
+// ModifyPermissions.
+type PermissionsContext struct{}
+
+func (ctx *PermissionsContext) Execute(txe *exec.TxExecution, p payload.Payload, st *state.Cache) error {
+	tx, ok := p.(*payload.PermissionsTx)
+	if !ok {
+		return codes.InvalidTx.Errorf("payload must be PermissionsTx, but is: %v", p)
+	}
+	if err := tx.Action.Validate(); err != nil {
+		return codes.InvalidTx.Wrap(err, "invalid PermissionsTx")
+	}
+	if tx.Input.Amount > 0 {
+		return codes.InvalidTx.Errorf("permission changes do not take an amount")
+	}
+	txe.UseGas(exec.GasPermissions)
+
+	global, err := st.GetGlobalPermissions()
+	if err != nil {
+		return err
+	}
+	switch tx.Action {
+	case permission.SetBase, permission.UnsetBase:
+		if tx.Permission == 0 || tx.Permission&^permission.AllPermFlags != 0 {
+			return codes.InvalidTx.Errorf("invalid permission bits %b", tx.Permission)
+		}
+		if tx.Permission&permission.Root != 0 {
+			// Only root may hand out or take away root
+			acc, err := st.GetAccount(tx.Input.Address)
+			if err != nil {
+				return err
+			}
+			if acc == nil || !permission.HasPermission(global, acc.Permissions.Base, permission.Root) {
+				return codes.PermissionDenied.Errorf("%v must have root permission to change root",
+					tx.Input.Address)
+			}
+		}
+	case permission.AddRole, permission.RemoveRole:
+		if tx.Target == nil {
+			return codes.InvalidTx.Errorf("roles are held by accounts, PermissionsTx must have a target")
+		}
+		if err := permission.ValidateRole(tx.Role); err != nil {
+			return codes.InvalidTx.Wrap(err, "invalid PermissionsTx")
+		}
+	}
+
+	target := "global"
+	if tx.Target == nil {
+		apply(&global, tx)
+		if err := st.SetGlobalPermissions(global); err != nil {
+			return err
+		}
+	} else {
+		target = tx.Target.String()
+		acc, err := st.GetAccount(*tx.Target)
+		if err != nil {
+			return err
+		}
+		if acc == nil {
+			return codes.UnknownAccount.Errorf("target account %v does not exist", *tx.Target)
+		}
+		switch tx.Action {
+		case permission.AddRole:
+			acc.Permissions.AddRole(tx.Role)
+		case permission.RemoveRole:
+			acc.Permissions.RemoveRole(tx.Role)
+		default:
+			apply(&acc.Permissions.Base, tx)
+		}
+		if err := st.UpdateAccount(acc); err != nil {
+			return err
+		}
+	}
+	txe.Event("permissions",
+		"action", string(tx.Action),
+		"target", target,
+		"permission", tx.Permission.String(),
+		"value", strconv.FormatBool(tx.Value),
+		"role", tx.Role)
+	return nil
+}
+
+func apply(base *permission.BasePermissions, tx *payload.PermissionsTx) {
+	if tx.Action == permission.SetBase {
+		base.Set(tx.Permission, tx.Value)
+	} else {
+		base.Unset(tx.Permission)
+	}
+}
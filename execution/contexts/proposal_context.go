@@ -0,0 +1,157 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package contexts
+
+import (
+	"strconv"
+
+	"github.com/sunvim/yaoguang/codes"
+	"github.com/sunvim/yaoguang/execution/exec"
+	"github.com/sunvim/yaoguang/state"
+	"github.com/sunvim/yaoguang/txs"
+	"github.com/sunvim/yaoguang/txs/payload"
+)
+
+const (
+	// ProposalVotingBlocks is how long a proposal stays open for votes
+	ProposalVotingBlocks = 1000
+
+	proposalEventType = "proposal"
+)
+
+// ProposalContext records proposals and votes, proposals are tallied and executed at the end of the block
+type ProposalContext struct {
+	// Supported reports whether payloads of a type can be executed by a proposal
+	Supported func(typ payload.Type) bool
+}
+
+func (ctx *ProposalContext) Execute(txe *exec.TxExecution, p payload.Payload, st *state.Cache) error {
+	tx, ok := p.(*payload.ProposalTx)
+	if !ok {
+		return codes.InvalidTx.Errorf("payload must be ProposalTx, but is: %v", p)
+	}
+	if tx.Input.Amount > 0 {
+		return codes.InvalidTx.Errorf("proposals and votes do not take an amount")
+	}
+	if (tx.Proposal == nil) == (tx.ProposalHash == nil) {
+		return codes.InvalidTx.Errorf("ProposalTx must either submit a proposal or vote for one")
+	}
+	txe.UseGas(exec.GasProposal)
+	if tx.Proposal != nil {
+		return ctx.submit(txe, tx, st)
+	}
+	return ctx.vote(txe, tx, st)
+}
+
+func (ctx *ProposalContext) submit(txe *exec.TxExecution, tx *payload.ProposalTx, st *state.Cache) error {
+	if len(tx.Proposal.Payloads) == 0 {
+		return codes.InvalidTx.Errorf("proposal %s has nothing to execute", tx.Proposal.Name)
+	}
+	for i, any := range tx.Proposal.Payloads {
+		if any == nil || any.Payload == nil {
+			return codes.InvalidTx.Errorf("payload %d of proposal %s is empty", i, tx.Proposal.Name)
+		}
+		if any.Type() == payload.TypeProposal || !ctx.Supported(any.Type()) {
+			return codes.UnsupportedTxType.Errorf("payload %d of proposal %s has type %v which cannot be proposed",
+				i, tx.Proposal.Name, any.Type())
+		}
+		if err := checkProposed(any.Payload); err != nil {
+			return codes.InvalidTx.Errorf("payload %d of proposal %s is invalid: %v", i, tx.Proposal.Name, err)
+		}
+	}
+	proposalHash, err := tx.Proposal.Hash()
+	if err != nil {
+		return codes.EncodingError.Wrap(err, "could not hash proposal")
+	}
+	existing, err := st.GetBallot(proposalHash)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return codes.InvalidTx.Errorf("proposal %s has already been submitted", proposalHash)
+	}
+	ballot := &payload.Ballot{
+		Proposal: tx.Proposal,
+		Proposer: tx.Input.Address,
+		Height:   txe.Height,
+		Expires:  txe.Height + ProposalVotingBlocks,
+		State:    payload.ProposalStatePending,
+	}
+	power, err := st.Power(tx.Input.Address)
+	if err != nil {
+		return err
+	}
+	if power.Sign() > 0 {
+		ballot.Votes = append(ballot.Votes, tx.Input.Address)
+	}
+	if err := st.UpdateBallot(proposalHash, ballot); err != nil {
+		return err
+	}
+	txe.Return = proposalHash
+	txe.Event(proposalEventType,
+		"action", "submit",
+		"hash", proposalHash.String(),
+		"name", tx.Proposal.Name,
+		"proposer", tx.Input.Address.String(),
+		"expires", strconv.FormatUint(ballot.Expires, 10))
+	return nil
+}
+
+func (ctx *ProposalContext) vote(txe *exec.TxExecution, tx *payload.ProposalTx, st *state.Cache) error {
+	ballot, err := st.GetBallot(tx.ProposalHash)
+	if err != nil {
+		return err
+	}
+	if ballot == nil {
+		return codes.UnknownProposal.Errorf("proposal %s does not exist", tx.ProposalHash)
+	}
+	if ballot.State != payload.ProposalStatePending || ballot.Expires <= txe.Height {
+		return codes.InvalidTx.Errorf("proposal %s is no longer open for votes", tx.ProposalHash)
+	}
+	power, err := st.Power(tx.Input.Address)
+	if err != nil {
+		return err
+	}
+	if power.Sign() <= 0 {
+		return codes.PermissionDenied.Errorf("%v is not a validator so cannot vote", tx.Input.Address)
+	}
+	if ballot.HasVoted(tx.Input.Address) {
+		return codes.InvalidTx.Errorf("%v has already voted for proposal %s", tx.Input.Address, tx.ProposalHash)
+	}
+	ballot.Votes = append(ballot.Votes, tx.Input.Address)
+	if err := st.UpdateBallot(tx.ProposalHash, ballot); err != nil {
+		return err
+	}
+	txe.Event(proposalEventType,
+		"action", "vote",
+		"hash", tx.ProposalHash.String(),
+		"voter", tx.Input.Address.String())
+	return nil
+}
+
+// checkProposed makes the checks that do not depend on state up front, so a malformed payload is rejected when it is
+// proposed rather than when it is executed
+func checkProposed(p payload.Payload) error {
+	if err := txs.CheckInputs(p); err != nil {
+		return err
+	}
+	if tx, ok := p.(*payload.UpgradeTx); ok && tx.Plan != nil {
+		return tx.Plan.Validate()
+	}
+	return nil
+}
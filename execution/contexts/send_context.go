@@ -0,0 +1,85 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package contexts
+
+import (
+	"strconv"
+
+	"github.com/sunvim/yaoguang/account"
+	"github.com/sunvim/yaoguang/binary"
+	"github.com/sunvim/yaoguang/codes"
+	"github.com/sunvim/yaoguang/execution/exec"
+	"github.com/sunvim/yaoguang/state"
+	"github.com/sunvim/yaoguang/txs/payload"
+)
+
+type SendContext struct{}
+
+func (ctx *SendContext) Execute(txe *exec.TxExecution, p payload.Payload, st *state.Cache) error {
+	tx, ok := p.(*payload.SendTx)
+	if !ok {
+		return codes.InvalidTx.Errorf("payload must be SendTx, but is: %v", p)
+	}
+	var inTotal, outTotal uint64
+	for _, input := range tx.Inputs {
+		acc, err := st.GetAccount(input.Address)
+		if err != nil {
+			return err
+		}
+		if acc == nil {
+			return codes.UnknownAccount.Errorf("input account %v does not exist", input.Address)
+		}
+		if err := acc.SubtractFromBalance(input.Amount); err != nil {
+			return err
+		}
+		if err := st.UpdateAccount(acc); err != nil {
+			return err
+		}
+		if binary.IsUint64SumOverflow(inTotal, input.Amount) {
+			return codes.IntegerOverflow.Errorf("SendTx input total overflows")
+		}
+		inTotal += input.Amount
+	}
+	for _, output := range tx.Outputs {
+		acc, err := st.GetAccount(output.Address)
+		if err != nil {
+			return err
+		}
+		if acc == nil {
+			acc = account.NewAccount(output.Address)
+		}
+		if err := acc.AddToBalance(output.Amount); err != nil {
+			return err
+		}
+		if err := st.UpdateAccount(acc); err != nil {
+			return err
+		}
+		if binary.IsUint64SumOverflow(outTotal, output.Amount) {
+			return codes.IntegerOverflow.Errorf("SendTx output total overflows")
+		}
+		outTotal += output.Amount
+		txe.UseGas(exec.GasTransfer)
+		txe.Event("transfer",
+			"recipient", output.Address.String(),
+			"amount", strconv.FormatUint(output.Amount, 10))
+	}
+	if inTotal != outTotal {
+		return codes.InvalidTx.Errorf("SendTx inputs total %d but outputs total %d", inTotal, outTotal)
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package contexts
+
+import (
+	"math/big"
+	"strconv"
+
+	"github.com/sunvim/yaoguang/binary"
+	"github.com/sunvim/yaoguang/codes"
+	"github.com/sunvim/yaoguang/execution/exec"
+	"github.com/sunvim/yaoguang/state"
+	"github.com/sunvim/yaoguang/txs/payload"
+	"github.com/sunvim/yaoguang/validators"
+)
+
+const (
+	// UnbondingBlocks is how long unbonded stake stays locked before it is returned
+	UnbondingBlocks = 1000
+	// MaxTotalPower is the largest total voting power Tendermint accepts for a validator set
+	MaxTotalPower = int64(1<<63-1) / 8
+)
+
+// BondContext locks tokens as validator voting power
+type BondContext struct {
+	// ValidatorKeyTypes are the types of key the consensus params allow for a validator
+	ValidatorKeyTypes func() []string
+}
+
+func (ctx *BondContext) Execute(txe *exec.TxExecution, p payload.Payload, st *state.Cache) error {
+	tx, ok := p.(*payload.BondTx)
+	if !ok {
+		return codes.InvalidTx.Errorf("payload must be BondTx, but is: %v", p)
+	}
+	if tx.Input.Amount == 0 {
+		return codes.InvalidTx.Errorf("nothing to bond")
+	}
+	acc, err := st.GetAccount(tx.Input.Address)
+	if err != nil {
+		return err
+	}
+	if acc == nil {
+		return codes.UnknownAccount.Errorf("input account %v does not exist", tx.Input.Address)
+	}
+	validator := tx.Validator
+	if validator == nil {
+		validator = acc.PublicKey
+	}
+	if !validator.IsSet() {
+		return codes.InvalidTx.Errorf("no validator key to bond to")
+	}
+	if !ctx.allowsKeyType(validator.CurveType.ABCIType()) {
+		return codes.InvalidTx.Errorf("validator keys of type %v are not allowed by the consensus params",
+			validator.CurveType)
+	}
+	power, err := st.Power(validator.GetAddress())
+	if err != nil {
+		return err
+	}
+	if power.Sign() == 0 && validator.GetAddress() != tx.Input.Address {
+		return codes.PermissionDenied.Errorf("%v is not a validator, only its own account may bond to it",
+			validator.GetAddress())
+	}
+	if err := acc.SubtractFromBalance(tx.Input.Amount); err != nil {
+		return err
+	}
+	if err := st.UpdateAccount(acc); err != nil {
+		return err
+	}
+	power = new(big.Int).Add(power, new(big.Int).SetUint64(tx.Input.Amount))
+	if _, err := st.SetPower(validator, power); err != nil {
+		return err
+	}
+	total, err := validators.TotalPower(st)
+	if err != nil {
+		return err
+	}
+	if total.Cmp(big.NewInt(MaxTotalPower)) > 0 {
+		return codes.IntegerOverflow.Errorf("bonding %d would take the total validator power over the maximum",
+			tx.Input.Amount)
+	}
+	delegation, err := st.GetDelegation(validator.GetAddress(), tx.Input.Address)
+	if err != nil {
+		return err
+	}
+	if binary.IsUint64SumOverflow(delegation, tx.Input.Amount) {
+		return codes.IntegerOverflow.Errorf("delegation overflows")
+	}
+	if err := st.SetDelegation(validator.GetAddress(), tx.Input.Address, delegation+tx.Input.Amount); err != nil {
+		return err
+	}
+	txe.Event("bond",
+		"validator", validator.GetAddress().String(),
+		"delegator", tx.Input.Address.String(),
+		"amount", strconv.FormatUint(tx.Input.Amount, 10),
+		"power", power.String())
+	return nil
+}
+
+func (ctx *BondContext) allowsKeyType(keyType string) bool {
+	for _, allowed := range ctx.ValidatorKeyTypes() {
+		if allowed == keyType {
+			return true
+		}
+	}
+	return false
+}
+
+// UnbondContext removes voting power and queues the tokens behind it for release
+type UnbondContext struct{}
+
+func (ctx *UnbondContext) Execute(txe *exec.TxExecution, p payload.Payload, st *state.Cache) error {
+	tx, ok := p.(*payload.UnbondTx)
+	if !ok {
+		return codes.InvalidTx.Errorf("payload must be UnbondTx, but is: %v", p)
+	}
+	if tx.Input.Amount == 0 {
+		return codes.InvalidTx.Errorf("nothing to unbond")
+	}
+	delegation, err := st.GetDelegation(tx.Validator, tx.Input.Address)
+	if err != nil {
+		return err
+	}
+	if delegation < tx.Input.Amount {
+		return codes.InsufficientFunds.Errorf("%v has %d bonded to %v but tried to unbond %d", tx.Input.Address,
+			delegation, tx.Validator, tx.Input.Amount)
+	}
+	if err := st.SetDelegation(tx.Validator, tx.Input.Address, delegation-tx.Input.Amount); err != nil {
+		return err
+	}
+	publicKey, power, err := st.GetValidator(tx.Validator)
+	if err != nil {
+		return err
+	}
+	if publicKey == nil {
+		return codes.InvalidTx.Errorf("%v is not a validator", tx.Validator)
+	}
+	power = new(big.Int).Sub(power, new(big.Int).SetUint64(tx.Input.Amount))
+	if power.Sign() < 0 {
+		power.SetInt64(0)
+	}
+	total, err := validators.TotalPower(st)
+	if err != nil {
+		return err
+	}
+	if total.Sub(total, new(big.Int).SetUint64(tx.Input.Amount)).Sign() <= 0 {
+		return codes.InvalidTx.Errorf("cannot unbond the last of the voting power")
+	}
+	if _, err := st.SetPower(publicKey, power); err != nil {
+		return err
+	}
+	unbonding := &validators.Unbonding{
+		Validator: tx.Validator,
+		Delegator: tx.Input.Address,
+		Amount:    tx.Input.Amount,
+		Release:   txe.Height + UnbondingBlocks,
+	}
+	if err := st.AddUnbonding(unbonding); err != nil {
+		return err
+	}
+	txe.Event("unbond",
+		"validator", tx.Validator.String(),
+		"delegator", tx.Input.Address.String(),
+		"amount", strconv.FormatUint(tx.Input.Amount, 10),
+		"power", power.String(),
+		"release", strconv.FormatUint(unbonding.Release, 10))
+	return nil
+}
+
+// ReleaseUnbondings credits the unbondings that are due at the end of the block to their delegators
+func ReleaseUnbondings(be *exec.BlockExecution, st *state.Cache) error {
+	var released []*validators.Unbonding
+	err := st.IterateUnbondings(be.Height, func(unbonding *validators.Unbonding) error {
+		released = append(released, unbonding)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, unbonding := range released {
+		acc, err := st.GetAccount(unbonding.Delegator)
+		if err != nil {
+			return err
+		}
+		if acc == nil {
+			return codes.UnknownAccount.Errorf("delegator %v does not exist", unbonding.Delegator)
+		}
+		if err := acc.AddToBalance(unbonding.Amount); err != nil {
+			return err
+		}
+		if err := st.UpdateAccount(acc); err != nil {
+			return err
+		}
+		if err := st.RemoveUnbonding(unbonding); err != nil {
+			return err
+		}
+		be.Event("release",
+			"validator", unbonding.Validator.String(),
+			"delegator", unbonding.Delegator.String(),
+			"amount", strconv.FormatUint(unbonding.Amount, 10))
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package contexts
+
+import (
+	"strconv"
+
+	"github.com/sunvim/yaoguang/codes"
+	"github.com/sunvim/yaoguang/execution/exec"
+	"github.com/sunvim/yaoguang/state"
+	"github.com/sunvim/yaoguang/txs/payload"
+)
+
+// UpgradeContext schedules or cancels an upgrade. The executor has already checked the input holds Root, or the
+// upgrade was passed by a proposal.
+type UpgradeContext struct{}
+
+func (ctx *UpgradeContext) Execute(txe *exec.TxExecution, p payload.Payload, st *state.Cache) error {
+	tx, ok := p.(*payload.UpgradeTx)
+	if !ok {
+		return codes.InvalidTx.Errorf("payload must be UpgradeTx, but is: %v", p)
+	}
+	if tx.Input.Amount > 0 {
+		return codes.InvalidTx.Errorf("upgrades do not take an amount")
+	}
+	txe.UseGas(exec.GasPermissions)
+
+	if tx.Plan == nil {
+		plan, err := st.GetUpgradePlan()
+		if err != nil {
+			return err
+		}
+		if plan == nil {
+			return codes.InvalidTx.Errorf("there is no scheduled upgrade to cancel")
+		}
+		if err := st.SetUpgradePlan(nil); err != nil {
+			return err
+		}
+		txe.Event("upgrade",
+			"action", "cancelled",
+			"name", plan.Name,
+			"height", strconv.FormatUint(plan.Height, 10))
+		return nil
+	}
+	if err := tx.Plan.Validate(); err != nil {
+		return codes.InvalidTx.Wrap(err, "invalid UpgradeTx")
+	}
+	// The upgrade runs at the start of its block so the earliest is the one after this
+	if tx.Plan.Height <= txe.Height {
+		return codes.InvalidTx.Errorf("upgrade %s at height %d must be after the current block %d", tx.Plan.Name,
+			tx.Plan.Height, txe.Height)
+	}
+	if err := st.SetUpgradePlan(tx.Plan); err != nil {
+		return err
+	}
+	txe.Event("upgrade",
+		"action", "scheduled",
+		"name", tx.Plan.Name,
+		"height", strconv.FormatUint(tx.Plan.Height, 10))
+	return nil
+}
@@ -0,0 +1,48 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package execution
+
+import (
+	"fmt"
+
+	"github.com/sunvim/yaoguang/codes"
+	"github.com/sunvim/yaoguang/crypto"
+)
+
+// ErrInvalidSequence is returned when an input does not carry exactly the next sequence of its account
+type ErrInvalidSequence struct {
+	Address  crypto.Address
+	Expected uint64
+	Got      uint64
+}
+
+func (e ErrInvalidSequence) Error() string {
+	if e.Got < e.Expected {
+		return fmt.Sprintf("stale sequence %d for account %v: sequence %d was expected, transaction may be a replay",
+			e.Got, e.Address, e.Expected)
+	}
+	return fmt.Sprintf("future sequence %d for account %v: sequence %d was expected", e.Got, e.Address, e.Expected)
+}
+
+func (e ErrInvalidSequence) Code() *codes.Code {
+	return codes.InvalidSequence
+}
+
+func (e ErrInvalidSequence) Is(target error) bool {
+	return target == codes.InvalidSequence
+}
@@ -0,0 +1,41 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package exec
+
+import (
+	"github.com/tendermint/tendermint/abci/types"
+)
+
+// BlockExecution records the outcome of end of block processing
+type BlockExecution struct {
+	Height uint64        `json:"height"`
+	Events []types.Event `json:"events,omitempty"`
+	// Changes to the validator set for Tendermint to apply
+	ValidatorUpdates []types.ValidatorUpdate `json:"validator_updates,omitempty"`
+	// Application protocol version for Tendermint to record in headers from the next block, 0 if unchanged
+	AppVersion uint64 `json:"app_version,omitempty"`
+}
+
+func NewBlockExecution(height uint64) *BlockExecution {
+	return &BlockExecution{Height: height}
+}
+
+// Event appends an event of typ with indexed attributes given as alternating keys and values
+func (be *BlockExecution) Event(typ string, keyValues ...string) {
+	be.Events = append(be.Events, NewEvent(typ, keyValues...))
+}
@@ -0,0 +1,35 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package exec
+
+import (
+	"github.com/tendermint/tendermint/abci/types"
+)
+
+// NewEvent returns an event of typ with indexed attributes given as alternating keys and values
+func NewEvent(typ string, keyValues ...string) types.Event {
+	event := types.Event{Type: typ}
+	for i := 0; i+1 < len(keyValues); i += 2 {
+		event.Attributes = append(event.Attributes, types.EventAttribute{
+			Key:   keyValues[i],
+			Value: keyValues[i+1],
+			Index: true,
+		})
+	}
+	return event
+}
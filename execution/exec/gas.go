@@ -0,0 +1,39 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package exec
+
+// Gas schedule. Costs depend only on the transaction and the state it touches so simulation gives an exact estimate.
+const (
+	// Charged to every transaction
+	GasTxBase uint64 = 1000
+	// Charged per byte of the signed transaction
+	GasPerTxByte uint64 = 10
+	// Charged per balance credited
+	GasTransfer uint64 = 500
+	// Charged per name registry change
+	GasNameUpdate uint64 = 2000
+	// Charged per proposal submitted or voted for
+	GasProposal uint64 = 2000
+	// Charged per permission or role change
+	GasPermissions uint64 = 2000
+)
+
+// UseGas adds gas to the amount consumed by the transaction
+func (txe *TxExecution) UseGas(gas uint64) {
+	txe.GasUsed += gas
+}
@@ -0,0 +1,52 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package exec
+
+import (
+	"github.com/sunvim/yaoguang/binary"
+	"github.com/sunvim/yaoguang/txs"
+	"github.com/sunvim/yaoguang/txs/payload"
+	"github.com/tendermint/tendermint/abci/types"
+)
+
+// TxExecution records the outcome of running a transaction
+type TxExecution struct {
+	TxHash binary.HexBytes `json:"tx_hash"`
+	TxType payload.Type    `json:"tx_type"`
+	Height uint64          `json:"height"`
+	// Gas consumed according to the gas schedule
+	GasUsed uint64 `json:"gas_used"`
+	// Fee charged
+	Fee uint64 `json:"fee,omitempty"`
+	// Data returned by the payload
+	Return binary.HexBytes `json:"return,omitempty"`
+	Events []types.Event   `json:"events,omitempty"`
+}
+
+func NewTxExecution(txEnv *txs.Envelope, height uint64) *TxExecution {
+	return &TxExecution{
+		TxHash: txEnv.Tx.Hash(),
+		TxType: txEnv.Tx.Type(),
+		Height: height,
+	}
+}
+
+// Event appends an event of typ with indexed attributes given as alternating keys and values
+func (txe *TxExecution) Event(typ string, keyValues ...string) {
+	txe.Events = append(txe.Events, NewEvent(typ, keyValues...))
+}
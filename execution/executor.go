@@ -0,0 +1,351 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package execution
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"sync"
+
+	"github.com/sunvim/yaoguang/account"
+	"github.com/sunvim/yaoguang/codes"
+	"github.com/sunvim/yaoguang/execution/contexts"
+	"github.com/sunvim/yaoguang/execution/exec"
+	"github.com/sunvim/yaoguang/genesis"
+	"github.com/sunvim/yaoguang/permission"
+	"github.com/sunvim/yaoguang/state"
+	"github.com/sunvim/yaoguang/txs"
+	"github.com/sunvim/yaoguang/txs/payload"
+	"github.com/sunvim/yaoguang/upgrade"
+	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
+)
+
+type Executor interface {
+	Execute(txEnv *txs.Envelope) (*exec.TxExecution, error)
+}
+
+type BatchExecutor interface {
+	Executor
+	// Reset discards all changes accumulated since the last commit
+	Reset()
+}
+
+type BatchCommitter interface {
+	BatchExecutor
+	// InitChain loads the genesis app state and the application protocol version from the genesis consensus params,
+	// they are committed with the first block
+	InitChain(appVersion uint64, appState *genesis.AppState) error
+	// BeginBlock runs the start of block processing for the block at height, including any upgrade scheduled for it
+	BeginBlock(height uint64) (*exec.BlockExecution, error)
+	// EndBlock runs the end of block processing for the block at height
+	EndBlock(height uint64) (*exec.BlockExecution, error)
+	// Commit the changes of the current block to state returning the new app hash
+	Commit(header *tmproto.Header) (appHash []byte, err error)
+}
+
+// Blockchain is the view of chain progress the executor needs
+type Blockchain interface {
+	ChainID() string
+	LastBlockHeight() uint64
+	ValidatorKeyTypes() []string
+}
+
+type executor struct {
+	sync.Mutex
+	// a committer keeps sequence bumps for transactions that fail since they are included in the block regardless
+	committing bool
+	// a simulator runs each transaction against a fresh cache and does not require signatures
+	simulating bool
+	state      *state.State
+	stateCache *state.Cache
+	blockchain Blockchain
+	contexts   map[payload.Type]contexts.Context
+	// handlers for the upgrades this binary can run
+	upgrades *upgrade.Registry
+	// app version set by an upgrade at the start of the current block
+	upgradedAppVersion uint64
+}
+
+var _ BatchCommitter = (*executor)(nil)
+
+// NewBatchChecker returns an executor for CheckTx. Its cache accumulates the sequences of transactions pending in
+// the mempool so an account may have several in flight, and must be Reset after each block is committed.
+func NewBatchChecker(backend *state.State, blockchain Blockchain) BatchExecutor {
+	return newExecutor(false, backend, blockchain)
+}
+
+// NewBatchCommitter returns an executor for DeliverTx that commits each block to backend
+func NewBatchCommitter(backend *state.State, blockchain Blockchain) BatchCommitter {
+	return newExecutor(true, backend, blockchain)
+}
+
+// NewSimulator returns an executor that dry-runs each transaction against a throwaway cache of the latest committed
+// state. Signatures are not checked so clients can preview a transaction before signing it.
+func NewSimulator(backend *state.State, blockchain Blockchain) Executor {
+	exe := newExecutor(false, backend, blockchain)
+	exe.simulating = true
+	return exe
+}
+
+func newExecutor(committing bool, backend *state.State, blockchain Blockchain) *executor {
+	exe := &executor{
+		committing: committing,
+		state:      backend,
+		stateCache: backend.Cache(),
+		blockchain: blockchain,
+		upgrades:   upgrade.Handlers,
+		contexts: map[payload.Type]contexts.Context{
+			payload.TypeSend:        &contexts.SendContext{},
+			payload.TypeName:        &contexts.NameContext{},
+			payload.TypeBond:        &contexts.BondContext{ValidatorKeyTypes: blockchain.ValidatorKeyTypes},
+			payload.TypeUnbond:      &contexts.UnbondContext{},
+			payload.TypePermissions: &contexts.PermissionsContext{},
+			payload.TypeUpgrade:     &contexts.UpgradeContext{},
+		},
+	}
+	exe.contexts[payload.TypeBatch] = &contexts.BatchContext{
+		Contexts:         exe.contexts,
+		CheckPermissions: checkPermissions,
+	}
+	exe.contexts[payload.TypeProposal] = &contexts.ProposalContext{
+		Supported: func(typ payload.Type) bool {
+			_, ok := exe.contexts[typ]
+			return ok
+		},
+	}
+	return exe
+}
+
+func (exe *executor) Execute(txEnv *txs.Envelope) (*exec.TxExecution, error) {
+	exe.Lock()
+	defer exe.Unlock()
+
+	if exe.simulating {
+		exe.stateCache = exe.state.Cache()
+		if txEnv.Tx == nil || txEnv.Tx.Payload == nil {
+			return nil, codes.InvalidTx.Errorf("envelope contains no transaction")
+		}
+		if txEnv.Tx.ChainID != exe.blockchain.ChainID() {
+			return nil, codes.WrongChainID.Errorf("transaction is for chain %q but this is chain %q", txEnv.Tx.ChainID,
+				exe.blockchain.ChainID())
+		}
+	} else if err := txEnv.Verify(exe.blockchain.ChainID()); err != nil {
+		return nil, err
+	}
+	ctx, ok := exe.contexts[txEnv.Tx.Type()]
+	if !ok {
+		return nil, codes.UnsupportedTxType.Errorf("unsupported transaction type %v", txEnv.Tx.Type())
+	}
+	signBytes, err := txEnv.Tx.SignBytes()
+	if err != nil {
+		return nil, codes.EncodingError.Wrap(err, "could not get sign bytes")
+	}
+	txe := exec.NewTxExecution(txEnv, exe.blockchain.LastBlockHeight()+1)
+	txe.UseGas(exec.GasTxBase + exec.GasPerTxByte*uint64(len(signBytes)))
+
+	// In a block the sequence bump is kept whatever else fails, the fee whenever it could be paid, and the payload's
+	// changes only if it succeeds
+	seqCache := state.NewCache(exe.stateCache)
+	if err := exe.bumpSequences(txEnv, seqCache); err != nil {
+		return nil, err
+	}
+	feeCache := state.NewCache(seqCache)
+	err = exe.chargeFee(txEnv, txe, feeCache)
+	if err == nil {
+		txCache := state.NewCache(feeCache)
+		err = checkPermissions(txEnv.Tx.Payload, txCache)
+		if err == nil {
+			err = ctx.Execute(txe, txEnv.Tx.Payload, txCache)
+		}
+		if err == nil {
+			err = txCache.Write(feeCache)
+		}
+		if werr := feeCache.Write(seqCache); werr != nil {
+			return nil, werr
+		}
+	}
+	if err == nil || exe.committing {
+		if werr := seqCache.Write(exe.stateCache); werr != nil {
+			return nil, werr
+		}
+	}
+	return txe, err
+}
+
+// Each input must carry exactly the next sequence for its account, so a transaction can be included at most once
+// and the transactions of an account are applied in the order they were signed
+func (exe *executor) bumpSequences(txEnv *txs.Envelope, st *state.Cache) error {
+	inputs := txEnv.Tx.GetInputs()
+	if len(inputs) == 0 {
+		return codes.InvalidTx.Errorf("transaction has no inputs")
+	}
+	// a simulation is not verified so may still have a missing input
+	if err := txs.CheckInputs(txEnv.Tx.Payload); err != nil {
+		return err
+	}
+	seen := make(map[string]bool, len(inputs))
+	for _, input := range inputs {
+		if seen[string(input.Address.Bytes())] {
+			return codes.InvalidTx.Errorf("account %v appears more than once in inputs", input.Address)
+		}
+		seen[string(input.Address.Bytes())] = true
+		acc, err := st.GetAccount(input.Address)
+		if err != nil {
+			return err
+		}
+		if acc == nil {
+			return codes.UnknownAccount.Errorf("input account %v does not exist", input.Address)
+		}
+		if input.Sequence != acc.Sequence+1 {
+			return ErrInvalidSequence{
+				Address:  input.Address,
+				Expected: acc.Sequence + 1,
+				Got:      input.Sequence,
+			}
+		}
+		acc.Sequence = input.Sequence
+		if acc.PublicKey == nil {
+			acc.PublicKey = txEnv.PublicKeyOf(input.Address)
+		}
+		if err := st.UpdateAccount(acc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The fee is burnt along with the sequence bump so a failing transaction still pays for its place in the block
+func (exe *executor) chargeFee(txEnv *txs.Envelope, txe *exec.TxExecution, st *state.Cache) error {
+	if txEnv.Tx.Fee == 0 {
+		return nil
+	}
+	payer := txEnv.Tx.GetInputs()[0].Address
+	acc, err := st.GetAccount(payer)
+	if err != nil {
+		return err
+	}
+	if err := acc.SubtractFromBalance(txEnv.Tx.Fee); err != nil {
+		return err
+	}
+	if err := st.UpdateAccount(acc); err != nil {
+		return err
+	}
+	txe.Fee = txEnv.Tx.Fee
+	txe.Event("fee",
+		"payer", payer.String(),
+		"amount", strconv.FormatUint(txEnv.Tx.Fee, 10))
+	return nil
+}
+
+func (exe *executor) Reset() {
+	exe.Lock()
+	defer exe.Unlock()
+	exe.stateCache = exe.state.Cache()
+}
+
+func (exe *executor) InitChain(appVersion uint64, appState *genesis.AppState) error {
+	exe.Lock()
+	defer exe.Unlock()
+	if appVersion > 0 {
+		if err := exe.stateCache.SetAppVersion(appVersion); err != nil {
+			return err
+		}
+	}
+	for _, ga := range appState.Accounts {
+		existing, err := exe.stateCache.GetAccount(ga.Address)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			return fmt.Errorf("genesis account %v is defined more than once", ga.Address)
+		}
+		acc := account.NewAccount(ga.Address)
+		acc.PublicKey = ga.PublicKey
+		acc.Sequence = ga.Sequence
+		acc.Balance = ga.Balance
+		if ga.Permissions != nil {
+			acc.Permissions = *ga.Permissions
+			acc.Permissions.Roles = nil
+			for _, role := range ga.Permissions.Roles {
+				if err := permission.ValidateRole(role); err != nil {
+					return fmt.Errorf("genesis account %v: %w", ga.Address, err)
+				}
+				acc.Permissions.AddRole(role)
+			}
+		}
+		if err := exe.stateCache.UpdateAccount(acc); err != nil {
+			return err
+		}
+	}
+	if appState.GlobalPermissions != nil {
+		if err := exe.stateCache.SetGlobalPermissions(*appState.GlobalPermissions); err != nil {
+			return err
+		}
+	}
+	for i, gv := range appState.Validators {
+		if !gv.PublicKey.IsSet() {
+			return fmt.Errorf("genesis validator %d has no public key", i)
+		}
+		power, err := exe.stateCache.Power(gv.PublicKey.GetAddress())
+		if err != nil {
+			return err
+		}
+		if power.Sign() > 0 {
+			return fmt.Errorf("genesis validator %v is defined more than once", gv.PublicKey.GetAddress())
+		}
+		if _, err := exe.stateCache.SetPower(gv.PublicKey, new(big.Int).SetUint64(gv.Power)); err != nil {
+			return err
+		}
+	}
+	return importChainState(exe.stateCache, appState)
+}
+
+func (exe *executor) EndBlock(height uint64) (*exec.BlockExecution, error) {
+	exe.Lock()
+	defer exe.Unlock()
+	be := exec.NewBlockExecution(height)
+	if err := contexts.ExpireNames(be, exe.stateCache); err != nil {
+		return nil, err
+	}
+	if err := contexts.ReleaseUnbondings(be, exe.stateCache); err != nil {
+		return nil, err
+	}
+	if err := exe.tallyProposals(be); err != nil {
+		return nil, err
+	}
+	if err := exe.updateValidators(be); err != nil {
+		return nil, err
+	}
+	exe.updateAppVersion(be)
+	return be, nil
+}
+
+func (exe *executor) Commit(header *tmproto.Header) ([]byte, error) {
+	exe.Lock()
+	defer exe.Unlock()
+	hash, version, err := exe.state.Commit(exe.stateCache)
+	if err != nil {
+		return nil, err
+	}
+	exe.stateCache = exe.state.Cache()
+	if version != header.Height {
+		return nil, fmt.Errorf("committed state version %d does not match block height %d", version, header.Height)
+	}
+	return hash, nil
+}
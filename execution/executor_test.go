@@ -0,0 +1,705 @@
+package execution
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/sunvim/yaoguang/account"
+	"github.com/sunvim/yaoguang/binary"
+	"github.com/sunvim/yaoguang/codes"
+	"github.com/sunvim/yaoguang/crypto"
+	"github.com/sunvim/yaoguang/execution/contexts"
+	"github.com/sunvim/yaoguang/execution/exec"
+	"github.com/sunvim/yaoguang/genesis"
+	"github.com/sunvim/yaoguang/names"
+	"github.com/sunvim/yaoguang/permission"
+	"github.com/sunvim/yaoguang/state"
+	"github.com/sunvim/yaoguang/txs"
+	"github.com/sunvim/yaoguang/txs/payload"
+	"github.com/sunvim/yaoguang/version"
+	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
+	dbm "github.com/tendermint/tm-db"
+)
+
+const chainID = "yaoguang-test"
+
+type testBlockchain struct {
+	height   uint64
+	keyTypes []string
+}
+
+func (bc *testBlockchain) ChainID() string             { return chainID }
+func (bc *testBlockchain) LastBlockHeight() uint64     { return bc.height }
+func (bc *testBlockchain) ValidatorKeyTypes() []string { return bc.keyTypes }
+
+var (
+	alice = crypto.PrivateKeyFromSecret("alice", crypto.CurveTypeEd25519)
+	bob   = crypto.PrivateKeyFromSecret("bob", crypto.CurveTypeSecp256k1)
+)
+
+func TestExecutor_Sequence(t *testing.T) {
+	st, bc := newTestState(t)
+	committer := NewBatchCommitter(st, bc)
+
+	_, err := committer.Execute(sendTx(t, 1, 10))
+	require.NoError(t, err)
+
+	// Replay
+	_, err = committer.Execute(sendTx(t, 1, 10))
+	requireSequenceError(t, err, 2, 1)
+
+	// Skipped a sequence
+	_, err = committer.Execute(sendTx(t, 3, 10))
+	requireSequenceError(t, err, 2, 3)
+
+	_, err = committer.Execute(sendTx(t, 2, 10))
+	require.NoError(t, err)
+	commit(t, committer, bc)
+
+	acc, err := st.Cache().GetAccount(alice.GetAddress())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), acc.Sequence)
+	assert.Equal(t, uint64(80), acc.Balance)
+}
+
+func TestExecutor_CheckerTracksPendingSequences(t *testing.T) {
+	st, bc := newTestState(t)
+	checker := NewBatchChecker(st, bc)
+	committer := NewBatchCommitter(st, bc)
+
+	// Several transactions from one account can wait in the mempool
+	for seq := uint64(1); seq <= 3; seq++ {
+		_, err := checker.Execute(sendTx(t, seq, 10))
+		require.NoError(t, err)
+	}
+	_, err := checker.Execute(sendTx(t, 2, 10))
+	requireSequenceError(t, err, 4, 2)
+
+	// Only the first made it into the block, the others are rechecked against committed state
+	_, err = committer.Execute(sendTx(t, 1, 10))
+	require.NoError(t, err)
+	commit(t, committer, bc)
+	checker.Reset()
+
+	_, err = checker.Execute(sendTx(t, 1, 10))
+	requireSequenceError(t, err, 2, 1)
+	_, err = checker.Execute(sendTx(t, 2, 10))
+	require.NoError(t, err)
+}
+
+func TestExecutor_FailedTxConsumesSequence(t *testing.T) {
+	st, bc := newTestState(t)
+	checker := NewBatchChecker(st, bc)
+	committer := NewBatchCommitter(st, bc)
+
+	// Overspend
+	_, err := checker.Execute(sendTx(t, 1, 1000))
+	require.True(t, errors.Is(err, codes.InsufficientFunds))
+	// A rejected CheckTx does not hold up the sequence
+	_, err = checker.Execute(sendTx(t, 1, 10))
+	require.NoError(t, err)
+
+	// But a failed transaction in a block has still been included
+	_, err = committer.Execute(sendTx(t, 1, 1000))
+	require.Error(t, err)
+	_, err = committer.Execute(sendTx(t, 1, 10))
+	requireSequenceError(t, err, 2, 1)
+}
+
+func TestExecutor_Fee(t *testing.T) {
+	st, bc := newTestState(t)
+	committer := NewBatchCommitter(st, bc)
+	feeTx := func(sequence, amount, fee uint64) *txs.Envelope {
+		tx := payload.NewSendTx()
+		tx.AddInput(alice.GetAddress(), amount, sequence)
+		tx.AddOutput(bob.GetAddress(), amount)
+		txEnv := txs.Enclose(chainID, tx)
+		txEnv.Tx.Fee = fee
+		require.NoError(t, txEnv.Sign(&alice))
+		return txEnv
+	}
+
+	txe, err := committer.Execute(feeTx(1, 10, 5))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5), txe.Fee)
+	// The fee is paid even though the transfer fails
+	_, err = committer.Execute(feeTx(2, 100, 5))
+	assert.True(t, errors.Is(err, codes.InsufficientFunds))
+	// Cannot pay the fee at all, the transaction is still in the block so its sequence is used
+	_, err = committer.Execute(feeTx(3, 0, 1000))
+	assert.True(t, errors.Is(err, codes.InsufficientFunds))
+	commit(t, committer, bc)
+
+	acc, err := st.Cache().GetAccount(alice.GetAddress())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(80), acc.Balance)
+	assert.Equal(t, uint64(3), acc.Sequence)
+}
+
+func TestExecutor_FeeDrained(t *testing.T) {
+	st, bc := newTestState(t)
+	checker := NewBatchChecker(st, bc)
+	committer := NewBatchCommitter(st, bc)
+	feeTx := func(sequence, amount, fee uint64) *txs.Envelope {
+		txEnv := sendTx(t, sequence, amount)
+		txEnv.Tx.Fee = fee
+		require.NoError(t, txEnv.Sign(&alice))
+		return txEnv
+	}
+
+	// Both pass CheckTx alone, but in one block the first leaves nothing for the fee of the second
+	drain, stranded := feeTx(1, 95, 5), feeTx(2, 0, 5)
+	_, err := committer.Execute(drain)
+	require.NoError(t, err)
+	_, err = committer.Execute(stranded)
+	assert.True(t, errors.Is(err, codes.InsufficientFunds), "expected insufficient funds but got: %v", err)
+	commit(t, committer, bc)
+
+	acc, err := st.Cache().GetAccount(alice.GetAddress())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), acc.Balance)
+	assert.Equal(t, uint64(2), acc.Sequence)
+
+	// Once alice is funded again the envelope from the block cannot be replayed
+	refund := payload.NewSendTx()
+	refund.AddInput(bob.GetAddress(), 100, 1)
+	refund.AddOutput(alice.GetAddress(), 100)
+	txEnv := txs.Enclose(chainID, refund)
+	require.NoError(t, txEnv.Sign(&bob))
+	_, err = committer.Execute(txEnv)
+	require.NoError(t, err)
+	commit(t, committer, bc)
+	_, err = checker.Execute(stranded)
+	requireSequenceError(t, err, 3, 2)
+	_, err = committer.Execute(stranded)
+	requireSequenceError(t, err, 3, 2)
+}
+
+func TestExecutor_Names(t *testing.T) {
+	st, bc := newTestState(t)
+	committer := NewBatchCommitter(st, bc)
+	cost := names.CostPerBlock("mob", "")
+
+	// Too short a lease
+	_, err := committer.Execute(nameTx(t, bob, 1, cost, "mob", ""))
+	assert.True(t, errors.Is(err, codes.InsufficientFunds), "expected insufficient funds but got: %v", err)
+
+	_, err = committer.Execute(nameTx(t, bob, 2, 10*cost, "mob", ""))
+	require.NoError(t, err)
+
+	_, err = committer.Execute(nameTx(t, alice, 1, 10*cost, "mob", "alice"))
+	assert.True(t, errors.Is(err, codes.PermissionDenied), "expected permission denied but got: %v", err)
+
+	// The remaining lease is carried over to the new owner
+	txEnv := nameTx(t, bob, 3, 0, "mob", "")
+	txEnv.Tx.Payload.(*payload.NameTx).NewOwner = addressOf(alice)
+	require.NoError(t, txEnv.Sign(&bob))
+	txe, err := committer.Execute(txEnv)
+	require.NoError(t, err)
+	require.Len(t, txe.Events, 1)
+	assert.Equal(t, "name", txe.Events[0].Type)
+	commit(t, committer, bc)
+
+	entry, err := st.Cache().GetName("mob")
+	require.NoError(t, err)
+	require.NotNil(t, entry)
+	assert.Equal(t, alice.GetAddress(), entry.Owner)
+	assert.Equal(t, uint64(12), entry.Expires)
+	acc, err := st.Cache().GetAccount(bob.GetAddress())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1000-10*cost), acc.Balance)
+
+	// Swept at the end of the block before it expires
+	be, err := committer.EndBlock(10)
+	require.NoError(t, err)
+	assert.Len(t, be.Events, 0)
+	be, err = committer.EndBlock(11)
+	require.NoError(t, err)
+	assert.Len(t, be.Events, 1)
+	entry, err = st.Cache().GetName("mob")
+	require.NoError(t, err)
+	assert.NotNil(t, entry, "sweep is not committed")
+	commit(t, committer, bc)
+	entry, err = st.Cache().GetName("mob")
+	require.NoError(t, err)
+	assert.Nil(t, entry)
+}
+
+func TestExecutor_Proposal(t *testing.T) {
+	st, bc := newTestState(t)
+	committer := NewBatchCommitter(st, bc)
+
+	send := payload.NewSendTx()
+	send.AddInput(bob.GetAddress(), 100, 0)
+	send.AddOutput(alice.GetAddress(), 100)
+	proposal := payload.NewProposal("refund", "", send)
+	proposalHash, err := proposal.Hash()
+	require.NoError(t, err)
+
+	txEnv := txs.Enclose(chainID, payload.NewProposalTx(alice.GetAddress(), 1, proposal))
+	require.NoError(t, txEnv.Sign(&alice))
+	txe, err := committer.Execute(txEnv)
+	require.NoError(t, err)
+	assert.Equal(t, proposalHash, txe.Return)
+
+	// Alice alone does not hold enough power
+	be, err := committer.EndBlock(bc.height + 1)
+	require.NoError(t, err)
+	assert.Len(t, be.Events, 0)
+	commit(t, committer, bc)
+	assert.Equal(t, []string{proposalHash.String()}, pendingBallots(t, st))
+
+	txEnv = txs.Enclose(chainID, payload.NewVoteTx(bob.GetAddress(), 1, proposalHash))
+	require.NoError(t, txEnv.Sign(&bob))
+	_, err = committer.Execute(txEnv)
+	require.NoError(t, err)
+	_, err = committer.Execute(txEnv)
+	requireSequenceError(t, err, 2, 1)
+
+	be, err = committer.EndBlock(bc.height + 1)
+	require.NoError(t, err)
+	// The transfer and the outcome of the proposal
+	assert.Len(t, be.Events, 2)
+	commit(t, committer, bc)
+
+	ballot, err := st.Cache().GetBallot(proposalHash)
+	require.NoError(t, err)
+	assert.Equal(t, payload.ProposalStateExecuted, ballot.State)
+	assert.Len(t, ballot.Votes, 2)
+	// A finished ballot is kept but no longer tallied
+	assert.Empty(t, pendingBallots(t, st))
+	acc, err := st.Cache().GetAccount(alice.GetAddress())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(200), acc.Balance)
+
+	// Votes close once a proposal has been executed
+	txEnv = txs.Enclose(chainID, payload.NewVoteTx(bob.GetAddress(), 2, proposalHash))
+	require.NoError(t, txEnv.Sign(&bob))
+	_, err = committer.Execute(txEnv)
+	assert.True(t, errors.Is(err, codes.InvalidTx), "expected invalid tx but got: %v", err)
+}
+
+func TestExecutor_ProposalMalformedPayload(t *testing.T) {
+	st, bc := newTestState(t)
+	committer := NewBatchCommitter(st, bc)
+
+	for i, p := range []payload.Payload{
+		&payload.UpgradeTx{},
+		&payload.SendTx{Inputs: []*payload.TxInput{nil}},
+		&payload.UpgradeTx{Input: &payload.TxInput{Address: alice.GetAddress()}, Plan: &payload.UpgradePlan{}},
+	} {
+		proposal := payload.NewProposal("bad", "", p)
+		txEnv := txs.Enclose(chainID, payload.NewProposalTx(alice.GetAddress(), uint64(i+1), proposal))
+		require.NoError(t, txEnv.Sign(&alice))
+		_, err := committer.Execute(txEnv)
+		assert.True(t, errors.Is(err, codes.InvalidTx), "expected invalid tx for %v but got: %v", p, err)
+	}
+}
+
+type panicContext struct{}
+
+func (panicContext) Execute(*exec.TxExecution, payload.Payload, *state.Cache) error {
+	panic("boom")
+}
+
+func TestExecutor_ProposalExecutionFails(t *testing.T) {
+	st, bc := newTestState(t)
+	exe := newExecutor(true, st, bc)
+	exe.contexts[payload.TypeName] = panicContext{}
+
+	// Ballots that bypassed the checks at submission, both hold every vote so are executed at the end of the block
+	ballots := map[string]*payload.Proposal{
+		"missing input": payload.NewProposal("missing input", "", &payload.UpgradeTx{}),
+		"panics": payload.NewProposal("panics", "",
+			&payload.NameTx{Input: &payload.TxInput{Address: alice.GetAddress()}, Name: "foo"}),
+	}
+	hashes := make(map[string][]byte)
+	for name, proposal := range ballots {
+		hash, err := proposal.Hash()
+		require.NoError(t, err)
+		hashes[name] = hash
+		require.NoError(t, exe.stateCache.UpdateBallot(hash, &payload.Ballot{
+			Proposal: proposal,
+			Votes:    []crypto.Address{alice.GetAddress(), bob.GetAddress()},
+			Expires:  bc.height + 10,
+			State:    payload.ProposalStatePending,
+		}))
+	}
+	be, err := exe.EndBlock(bc.height + 1)
+	require.NoError(t, err)
+	assert.Len(t, be.Events, 2)
+	commit(t, exe, bc)
+
+	for name, hash := range hashes {
+		ballot, err := st.Cache().GetBallot(hash)
+		require.NoError(t, err)
+		assert.Equal(t, payload.ProposalStateFailed, ballot.State, name)
+		assert.NotEmpty(t, ballot.Error, name)
+	}
+	assert.Empty(t, pendingBallots(t, st))
+}
+
+func TestExecutor_ProposalInputs(t *testing.T) {
+	st, bc := newTestState(t)
+	exe := newExecutor(true, st, bc)
+	carol := crypto.PrivateKeyFromSecret("carol", crypto.CurveTypeEd25519)
+	require.NoError(t, exe.stateCache.UpdateAccount(&account.Account{Address: carol.GetAddress(), Balance: 100}))
+
+	// Carol neither proposed nor voted so cannot be made to pay
+	fromCarol := payload.NewSendTx()
+	fromCarol.AddInput(carol.GetAddress(), 100, 0)
+	fromCarol.AddOutput(alice.GetAddress(), 100)
+	// Bob voted but may not change permissions
+	byBob := payload.NewPermissionsTx(bob.GetAddress(), 0, addressOf(bob), permission.SetBase)
+	byBob.Permission = permission.Root
+	byBob.Value = true
+	ballots := map[string]*payload.Proposal{
+		"not a voter":   payload.NewProposal("not a voter", "", fromCarol),
+		"no permission": payload.NewProposal("no permission", "", byBob),
+	}
+	reasons := map[string]string{
+		"not a voter":   "neither proposed nor voted",
+		"no permission": "does not have modifyPermissions permission",
+	}
+	hashes := make(map[string][]byte)
+	for name, proposal := range ballots {
+		hash, err := proposal.Hash()
+		require.NoError(t, err)
+		hashes[name] = hash
+		require.NoError(t, exe.stateCache.UpdateBallot(hash, &payload.Ballot{
+			Proposal: proposal,
+			Proposer: alice.GetAddress(),
+			Votes:    []crypto.Address{alice.GetAddress(), bob.GetAddress()},
+			Expires:  bc.height + 10,
+			State:    payload.ProposalStatePending,
+		}))
+	}
+	_, err := exe.EndBlock(bc.height + 1)
+	require.NoError(t, err)
+	commit(t, exe, bc)
+
+	for name, hash := range hashes {
+		ballot, err := st.Cache().GetBallot(hash)
+		require.NoError(t, err)
+		assert.Equal(t, payload.ProposalStateFailed, ballot.State, name)
+		assert.Contains(t, ballot.Error, reasons[name], name)
+	}
+	acc, err := st.Cache().GetAccount(carol.GetAddress())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(100), acc.Balance)
+	acc, err = st.Cache().GetAccount(bob.GetAddress())
+	require.NoError(t, err)
+	root, _ := acc.Permissions.Base.Get(permission.Root)
+	assert.False(t, root)
+}
+
+func TestExecutor_Staking(t *testing.T) {
+	st, bc := newTestState(t)
+	committer := NewBatchCommitter(st, bc)
+	carol := crypto.PrivateKeyFromSecret("carol", crypto.CurveTypeEd25519)
+
+	execute := func(signer crypto.PrivateKey, p payload.Payload) error {
+		txEnv := txs.Enclose(chainID, p)
+		require.NoError(t, txEnv.Sign(&signer))
+		_, err := committer.Execute(txEnv)
+		return err
+	}
+	require.NoError(t, execute(bob, payload.NewBondTx(bob.GetAddress(), 100, 1, nil)))
+	require.NoError(t, execute(alice, payload.NewBondTx(alice.GetAddress(), 50, 1, bob.GetPublicKey())))
+	err := execute(alice, payload.NewBondTx(alice.GetAddress(), 10, 2, carol.GetPublicKey()))
+	assert.True(t, errors.Is(err, codes.PermissionDenied), "expected permission denied but got: %v", err)
+
+	be, err := committer.EndBlock(bc.height + 1)
+	require.NoError(t, err)
+	require.Len(t, be.ValidatorUpdates, 1)
+	assert.Equal(t, int64(154), be.ValidatorUpdates[0].Power)
+	commit(t, committer, bc)
+
+	// Cannot take out more than was delegated
+	err = execute(alice, payload.NewUnbondTx(alice.GetAddress(), 60, 3, bob.GetAddress()))
+	assert.True(t, errors.Is(err, codes.InsufficientFunds), "expected insufficient funds but got: %v", err)
+	require.NoError(t, execute(alice, payload.NewUnbondTx(alice.GetAddress(), 50, 4, bob.GetAddress())))
+	be, err = committer.EndBlock(bc.height + 1)
+	require.NoError(t, err)
+	require.Len(t, be.ValidatorUpdates, 1)
+	assert.Equal(t, int64(104), be.ValidatorUpdates[0].Power)
+	commit(t, committer, bc)
+
+	acc, err := st.Cache().GetAccount(alice.GetAddress())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(50), acc.Balance)
+
+	// Returned once the unbonding period has passed
+	be, err = committer.EndBlock(bc.height + contexts.UnbondingBlocks)
+	require.NoError(t, err)
+	assert.Len(t, be.Events, 1)
+	assert.Len(t, be.ValidatorUpdates, 0)
+	commit(t, committer, bc)
+	acc, err = st.Cache().GetAccount(alice.GetAddress())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(100), acc.Balance)
+}
+
+func TestExecutor_BondLimits(t *testing.T) {
+	st, err := state.NewState(dbm.NewMemDB())
+	require.NoError(t, err)
+	bc := &testBlockchain{keyTypes: []string{"ed25519"}}
+	committer := NewBatchCommitter(st, bc)
+	carol := crypto.PrivateKeyFromSecret("carol", crypto.CurveTypeEd25519)
+	err = committer.InitChain(version.AppVersion, &genesis.AppState{
+		Accounts: []genesis.Account{
+			{Address: alice.GetAddress(), PublicKey: alice.GetPublicKey(), Balance: 100},
+			{Address: bob.GetAddress(), PublicKey: bob.GetPublicKey(), Balance: 100},
+			{Address: carol.GetAddress(), PublicKey: carol.GetPublicKey(), Balance: uint64(contexts.MaxTotalPower)},
+		},
+		Validators: []genesis.Validator{{PublicKey: alice.GetPublicKey(), Power: 10}},
+	})
+	require.NoError(t, err)
+	commit(t, committer, bc)
+	execute := func(signer crypto.PrivateKey, p payload.Payload) error {
+		txEnv := txs.Enclose(chainID, p)
+		require.NoError(t, txEnv.Sign(&signer))
+		_, err := committer.Execute(txEnv)
+		return err
+	}
+
+	// Tendermint would refuse a validator with a key of a type the consensus params do not list
+	err = execute(bob, payload.NewBondTx(bob.GetAddress(), 10, 1, nil))
+	assert.True(t, errors.Is(err, codes.InvalidTx), "expected invalid tx but got: %v", err)
+
+	// The limit is on the power of the whole set rather than of one validator
+	require.NoError(t, execute(carol, payload.NewBondTx(carol.GetAddress(), uint64(contexts.MaxTotalPower)-10, 1, nil)))
+	err = execute(carol, payload.NewBondTx(carol.GetAddress(), 1, 2, nil))
+	assert.True(t, errors.Is(err, codes.IntegerOverflow), "expected integer overflow but got: %v", err)
+}
+
+func TestExecutor_Permissions(t *testing.T) {
+	st, bc := newTestState(t)
+	committer := NewBatchCommitter(st, bc)
+	execute := func(signer crypto.PrivateKey, p payload.Payload) error {
+		txEnv := txs.Enclose(chainID, p)
+		require.NoError(t, txEnv.Sign(&signer))
+		_, err := committer.Execute(txEnv)
+		return err
+	}
+	requirePermissionDenied := func(err error) {
+		require.True(t, errors.Is(err, codes.PermissionDenied), "expected permission denied but got: %v", err)
+	}
+	setBase := func(address crypto.Address, sequence uint64, target *crypto.Address, perm permission.PermFlag,
+		value bool) *payload.PermissionsTx {
+		tx := payload.NewPermissionsTx(address, sequence, target, permission.SetBase)
+		tx.Permission = perm
+		tx.Value = value
+		return tx
+	}
+
+	// Bob cannot change permissions
+	requirePermissionDenied(execute(bob, setBase(bob.GetAddress(), 1, nil, permission.Send, false)))
+
+	// Alice can, but not hand out root
+	requirePermissionDenied(execute(alice, setBase(alice.GetAddress(), 1, addressOf(bob), permission.Root, true)))
+	require.NoError(t, execute(alice, setBase(alice.GetAddress(), 2, addressOf(bob), permission.Send, false)))
+	send := payload.NewSendTx()
+	send.AddInput(bob.GetAddress(), 10, 2)
+	send.AddOutput(alice.GetAddress(), 10)
+	requirePermissionDenied(execute(bob, send))
+
+	addRole := payload.NewPermissionsTx(alice.GetAddress(), 3, addressOf(bob), permission.AddRole)
+	addRole.Role = "game-operator"
+	require.NoError(t, execute(alice, addRole))
+
+	// Revoking globally applies to everyone without their own setting
+	require.NoError(t, execute(alice, setBase(alice.GetAddress(), 4, nil, permission.Name, false)))
+	commit(t, committer, bc)
+
+	acc, err := st.Cache().GetAccount(bob.GetAddress())
+	require.NoError(t, err)
+	assert.True(t, acc.Permissions.HasRole("game-operator"))
+	_, err = committer.Execute(nameTx(t, alice, 5, 1000, "alice", ""))
+	requirePermissionDenied(err)
+}
+
+func TestExecutor_Batch(t *testing.T) {
+	st, bc := newTestState(t)
+	committer := NewBatchCommitter(st, bc)
+	batchTx := func(sequence uint64, amount uint64) *txs.Envelope {
+		send := payload.NewSendTx()
+		send.AddInput(alice.GetAddress(), 10, 0)
+		send.AddOutput(bob.GetAddress(), 10)
+		name := payload.NewNameTx(bob.GetAddress(), amount, 0, "bob", "")
+		tx := payload.NewBatchTx(send, name)
+		tx.AddInput(alice.GetAddress(), sequence)
+		tx.AddInput(bob.GetAddress(), sequence)
+		txEnv := txs.Enclose(chainID, tx)
+		require.NoError(t, txEnv.Sign(&alice, &bob))
+		return txEnv
+	}
+
+	// Bob cannot afford the name so the transfer does not happen either
+	_, err := committer.Execute(batchTx(1, 2000))
+	var errStep contexts.ErrBatchStep
+	require.True(t, errors.As(err, &errStep), "expected batch step error but got: %v", err)
+	assert.Equal(t, 1, errStep.Index)
+	assert.True(t, errors.Is(err, codes.InsufficientFunds))
+	assert.Equal(t, codes.InsufficientFundsCode, codes.FromError(err).Number())
+
+	txe, err := committer.Execute(batchTx(2, 10*names.CostPerBlock("bob", "")))
+	require.NoError(t, err)
+	// transfer, batch step, name, batch step
+	assert.Len(t, txe.Events, 4)
+	commit(t, committer, bc)
+
+	acc, err := st.Cache().GetAccount(alice.GetAddress())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(90), acc.Balance)
+	entry, err := st.Cache().GetName("bob")
+	require.NoError(t, err)
+	assert.NotNil(t, entry)
+}
+
+func newTestState(t *testing.T) (*state.State, *testBlockchain) {
+	st, err := state.NewState(dbm.NewMemDB())
+	require.NoError(t, err)
+	// As for a genesis generated with validators of both types
+	bc := &testBlockchain{keyTypes: []string{"ed25519", "secp256k1"}}
+	committer := NewBatchCommitter(st, bc)
+	err = committer.InitChain(version.AppVersion, &genesis.AppState{
+		Accounts: []genesis.Account{
+			{Address: alice.GetAddress(), PublicKey: alice.GetPublicKey(), Balance: 100,
+				Permissions: &permission.AccountPermissions{
+					Base: permission.BasePermissions{
+						Perms:  permission.ModifyPermissions,
+						SetBit: permission.ModifyPermissions,
+					},
+				},
+			},
+			{Address: bob.GetAddress(), PublicKey: bob.GetPublicKey(), Balance: 1000},
+		},
+		Validators: []genesis.Validator{
+			{PublicKey: alice.GetPublicKey(), Power: 6},
+			{PublicKey: bob.GetPublicKey(), Power: 4},
+		},
+	})
+	require.NoError(t, err)
+	commit(t, committer, bc)
+	return st, bc
+}
+
+func pendingBallots(t *testing.T, st *state.State) []string {
+	var hashes []string
+	err := st.Cache().IteratePendingBallots(func(hash binary.HexBytes, ballot *payload.Ballot) error {
+		assert.Equal(t, payload.ProposalStatePending, ballot.State)
+		hashes = append(hashes, hash.String())
+		return nil
+	})
+	require.NoError(t, err)
+	return hashes
+}
+
+func commit(t *testing.T, committer BatchCommitter, bc *testBlockchain) {
+	_, err := committer.Commit(&tmproto.Header{Height: int64(bc.height + 1)})
+	require.NoError(t, err)
+	bc.height++
+}
+
+func sendTx(t *testing.T, sequence, amount uint64) *txs.Envelope {
+	tx := payload.NewSendTx()
+	tx.AddInput(alice.GetAddress(), amount, sequence)
+	tx.AddOutput(bob.GetAddress(), amount)
+	txEnv := txs.Enclose(chainID, tx)
+	require.NoError(t, txEnv.Sign(&alice))
+	return txEnv
+}
+
+func nameTx(t *testing.T, signer crypto.PrivateKey, sequence, amount uint64, name, data string) *txs.Envelope {
+	txEnv := txs.Enclose(chainID, payload.NewNameTx(signer.GetAddress(), amount, sequence, name, data))
+	require.NoError(t, txEnv.Sign(&signer))
+	return txEnv
+}
+
+func addressOf(signer crypto.PrivateKey) *crypto.Address {
+	address := signer.GetAddress()
+	return &address
+}
+
+func requireSequenceError(t *testing.T, err error, expected, got uint64) {
+	var errSequence ErrInvalidSequence
+	require.True(t, errors.As(err, &errSequence), "expected sequence error but got: %v", err)
+	assert.True(t, errors.Is(err, codes.InvalidSequence))
+	assert.Equal(t, expected, errSequence.Expected)
+	assert.Equal(t, got, errSequence.Got)
+}
+
+func TestSimulator(t *testing.T) {
+	st, bc := newTestState(t)
+	simulator := NewSimulator(st, bc)
+
+	// Unsigned
+	tx := payload.NewSendTx()
+	tx.AddInput(alice.GetAddress(), 10, 1)
+	tx.AddOutput(bob.GetAddress(), 10)
+	txEnv := txs.Enclose(chainID, tx)
+	txe, err := simulator.Execute(txEnv)
+	require.NoError(t, err)
+	assert.Greater(t, txe.GasUsed, exec.GasTxBase)
+	assert.Len(t, txe.Events, 1)
+
+	// Signing does not change the estimate
+	require.NoError(t, txEnv.Sign(&alice))
+	txeSigned, err := simulator.Execute(txEnv)
+	require.NoError(t, err)
+	assert.Equal(t, txe.GasUsed, txeSigned.GasUsed)
+
+	// Nothing was kept
+	acc, err := st.Cache().GetAccount(alice.GetAddress())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), acc.Sequence)
+	assert.Equal(t, uint64(100), acc.Balance)
+
+	_, err = simulator.Execute(sendTx(t, 1, 1000))
+	assert.Error(t, err)
+}
+
+func TestExecutor_MissingInputs(t *testing.T) {
+	st, bc := newTestState(t)
+	executors := map[string]Executor{
+		"checker":   NewBatchChecker(st, bc),
+		"committer": NewBatchCommitter(st, bc),
+		"simulator": NewSimulator(st, bc),
+	}
+	send := payload.NewSendTx()
+	send.Inputs = []*payload.TxInput{nil}
+	batchSend := payload.NewSendTx()
+	batchSend.Inputs = []*payload.TxInput{nil}
+	batch := payload.NewBatchTx(batchSend)
+	batch.AddInput(alice.GetAddress(), 1)
+	sendNoOutput := payload.NewSendTx()
+	sendNoOutput.AddInput(alice.GetAddress(), 10, 1)
+	sendNoOutput.Outputs = []*payload.TxOutput{nil}
+	batchNoOutput := payload.NewSendTx()
+	batchNoOutput.AddInput(alice.GetAddress(), 10, 0)
+	batchNoOutput.Outputs = []*payload.TxOutput{nil}
+	malformed := []payload.Payload{
+		&payload.NameTx{}, &payload.BondTx{}, &payload.UnbondTx{}, &payload.PermissionsTx{}, &payload.ProposalTx{},
+		&payload.UpgradeTx{}, send, sendNoOutput,
+	}
+	for name, executor := range executors {
+		for _, p := range malformed {
+			txEnv := txs.Enclose(chainID, p)
+			require.NoError(t, txEnv.Sign(&alice))
+			_, err := executor.Execute(txEnv)
+			assert.True(t, errors.Is(err, codes.InvalidTx), "%s %v: %v", name, p.Type(), err)
+		}
+	}
+	for i, batch := range []*payload.BatchTx{batch, payload.NewBatchTx(batchNoOutput)} {
+		if i > 0 {
+			batch.AddInput(alice.GetAddress(), 2)
+		}
+		txEnv := txs.Enclose(chainID, batch)
+		require.NoError(t, txEnv.Sign(&alice))
+		_, err := executors["committer"].Execute(txEnv)
+		assert.True(t, errors.Is(err, codes.InvalidTx), "batch %d: %v", i, err)
+	}
+}
@@ -0,0 +1,191 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package execution
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+
+	"github.com/sunvim/yaoguang/account"
+	"github.com/sunvim/yaoguang/binary"
+	"github.com/sunvim/yaoguang/crypto"
+	"github.com/sunvim/yaoguang/genesis"
+	"github.com/sunvim/yaoguang/names"
+	"github.com/sunvim/yaoguang/state"
+	"github.com/sunvim/yaoguang/txs/payload"
+	"github.com/sunvim/yaoguang/validators"
+)
+
+// ExportAppState dumps the state read by reader to a genesis app state that InitChain loads back, with the app
+// version of the reader, to the same keys and values so a chain started from it at the following height has the same
+// application state
+func ExportAppState(reader *state.Reader) (*genesis.AppState, error) {
+	appState := new(genesis.AppState)
+	err := reader.IterateAccounts(func(acc *account.Account) error {
+		permissions := acc.Permissions
+		permissions.Roles = append([]string(nil), acc.Permissions.Roles...)
+		appState.Accounts = append(appState.Accounts, genesis.Account{
+			Address:     acc.Address,
+			PublicKey:   acc.PublicKey,
+			Sequence:    acc.Sequence,
+			Balance:     acc.Balance,
+			Permissions: &permissions,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not export accounts: %w", err)
+	}
+	err = reader.IterateValidators(func(id crypto.Addressable, power *big.Int) error {
+		if !power.IsUint64() {
+			return fmt.Errorf("power %v of validator %v does not fit in a genesis validator", power,
+				id.GetAddress())
+		}
+		appState.Validators = append(appState.Validators, genesis.Validator{
+			PublicKey: id.GetPublicKey(),
+			Power:     power.Uint64(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not export validators: %w", err)
+	}
+	hasGlobal, err := reader.HasGlobalPermissions()
+	if err != nil {
+		return nil, err
+	}
+	if hasGlobal {
+		global, err := reader.GetGlobalPermissions()
+		if err != nil {
+			return nil, err
+		}
+		appState.GlobalPermissions = &global
+	}
+	err = reader.IterateNames(func(entry *names.Entry) error {
+		appState.Names = append(appState.Names, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not export names: %w", err)
+	}
+	err = reader.IterateBallots(func(proposalHash binary.HexBytes, ballot *payload.Ballot) error {
+		appState.Proposals = append(appState.Proposals, genesis.Proposal{
+			Hash:   append(binary.HexBytes(nil), proposalHash...),
+			Ballot: ballot,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not export proposals: %w", err)
+	}
+	err = reader.IterateDelegations(func(validator, delegator crypto.Address, amount uint64) error {
+		appState.Delegations = append(appState.Delegations, genesis.Delegation{
+			Validator: validator,
+			Delegator: delegator,
+			Amount:    amount,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not export delegations: %w", err)
+	}
+	err = reader.IterateAllUnbondings(func(unbonding *validators.Unbonding) error {
+		appState.Unbondings = append(appState.Unbondings, unbonding)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not export unbondings: %w", err)
+	}
+	appState.Upgrade, err = reader.GetUpgradePlan()
+	if err != nil {
+		return nil, err
+	}
+	return appState, nil
+}
+
+// importChainState loads the sections of the genesis app state that carry the state of an exported chain
+func importChainState(cache *state.Cache, appState *genesis.AppState) error {
+	for _, entry := range appState.Names {
+		existing, err := cache.GetName(entry.Name)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			return fmt.Errorf("genesis name %s is defined more than once", entry.Name)
+		}
+		if err := names.ValidateName(entry.Name); err != nil {
+			return fmt.Errorf("genesis name %s: %w", entry.Name, err)
+		}
+		if err := cache.UpdateName(entry); err != nil {
+			return err
+		}
+	}
+	for i, gp := range appState.Proposals {
+		if gp.Ballot == nil || gp.Ballot.Proposal == nil {
+			return fmt.Errorf("genesis proposal %d has no ballot", i)
+		}
+		hash, err := gp.Ballot.Proposal.Hash()
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(hash, gp.Hash) {
+			return fmt.Errorf("genesis proposal %v has hash %v", gp.Hash, hash)
+		}
+		existing, err := cache.GetBallot(gp.Hash)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			return fmt.Errorf("genesis proposal %v is defined more than once", gp.Hash)
+		}
+		if err := cache.UpdateBallot(gp.Hash, gp.Ballot); err != nil {
+			return err
+		}
+	}
+	for _, gd := range appState.Delegations {
+		if gd.Amount == 0 {
+			return fmt.Errorf("genesis delegation from %v to %v has no amount", gd.Delegator, gd.Validator)
+		}
+		existing, err := cache.GetDelegation(gd.Validator, gd.Delegator)
+		if err != nil {
+			return err
+		}
+		if existing > 0 {
+			return fmt.Errorf("genesis delegation from %v to %v is defined more than once", gd.Delegator,
+				gd.Validator)
+		}
+		if err := cache.SetDelegation(gd.Validator, gd.Delegator, gd.Amount); err != nil {
+			return err
+		}
+	}
+	for _, unbonding := range appState.Unbondings {
+		if err := cache.AddUnbonding(unbonding); err != nil {
+			return err
+		}
+	}
+	if appState.Upgrade != nil {
+		if err := appState.Upgrade.Validate(); err != nil {
+			return fmt.Errorf("genesis upgrade: %w", err)
+		}
+		if err := cache.SetUpgradePlan(appState.Upgrade); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package execution
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/sunvim/yaoguang/crypto"
+	"github.com/sunvim/yaoguang/genesis"
+	"github.com/sunvim/yaoguang/names"
+	"github.com/sunvim/yaoguang/state"
+	"github.com/sunvim/yaoguang/storage"
+	"github.com/sunvim/yaoguang/txs"
+	"github.com/sunvim/yaoguang/txs/payload"
+	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
+	dbm "github.com/tendermint/tm-db"
+)
+
+func TestExportAppState(t *testing.T) {
+	st, bc := newTestState(t)
+	committer := NewBatchCommitter(st, bc)
+	execute := func(signer crypto.PrivateKey, p payload.Payload) {
+		txEnv := txs.Enclose(chainID, p)
+		require.NoError(t, txEnv.Sign(&signer))
+		_, err := committer.Execute(txEnv)
+		require.NoError(t, err)
+	}
+	execute(bob, payload.NewNameTx(bob.GetAddress(), 10*names.CostPerBlock("mob", "bob"), 1, "mob", "bob"))
+	execute(bob, payload.NewBondTx(bob.GetAddress(), 100, 2, nil))
+	execute(alice, payload.NewBondTx(alice.GetAddress(), 50, 1, bob.GetPublicKey()))
+	execute(alice, payload.NewUnbondTx(alice.GetAddress(), 20, 2, bob.GetAddress()))
+	send := payload.NewSendTx()
+	send.AddInput(bob.GetAddress(), 10, 0)
+	send.AddOutput(alice.GetAddress(), 10)
+	execute(alice, payload.NewProposalTx(alice.GetAddress(), 3, payload.NewProposal("refund", "", send)))
+	_, err := committer.EndBlock(bc.height + 1)
+	require.NoError(t, err)
+	commit(t, committer, bc)
+
+	reader, err := st.Reader(int64(bc.height))
+	require.NoError(t, err)
+	appState, err := ExportAppState(reader)
+	require.NoError(t, err)
+	assert.Len(t, appState.Names, 1)
+	assert.Len(t, appState.Proposals, 1)
+	assert.Len(t, appState.Delegations, 2)
+	assert.Len(t, appState.Unbondings, 1)
+
+	// Round trip through JSON as the genesis file does
+	appVersion, err := reader.GetAppVersion()
+	require.NoError(t, err)
+	bs, err := appState.JSONBytes()
+	require.NoError(t, err)
+	imported, importedHash := importAppState(t, bs, appVersion, bc.height+1)
+	assert.Equal(t, keyValues(t, st), keyValues(t, imported))
+
+	// The same data gives the same app hash
+	_, hash := importAppState(t, bs, appVersion, bc.height+1)
+	assert.Equal(t, importedHash, hash)
+
+	reader, err = imported.Reader(int64(bc.height + 1))
+	require.NoError(t, err)
+	reexported, err := ExportAppState(reader)
+	require.NoError(t, err)
+	reexportedBytes, err := reexported.JSONBytes()
+	require.NoError(t, err)
+	assert.Equal(t, string(bs), string(reexportedBytes))
+}
+
+func importAppState(t *testing.T, bs []byte, appVersion, initialHeight uint64) (*state.State, []byte) {
+	appState, err := genesis.AppStateFromJSON(bs)
+	require.NoError(t, err)
+	st, err := state.NewInitialState(dbm.NewMemDB(), initialHeight)
+	require.NoError(t, err)
+	bc := &testBlockchain{height: initialHeight - 1}
+	committer := NewBatchCommitter(st, bc)
+	require.NoError(t, committer.InitChain(appVersion, appState))
+	hash, err := committer.Commit(&tmproto.Header{Height: int64(initialHeight)})
+	require.NoError(t, err)
+	return st, hash
+}
+
+func keyValues(t *testing.T, st *state.State) map[string]string {
+	kvs := make(map[string]string)
+	err := storage.Iterate(st, nil, nil, func(key, value []byte) error {
+		kvs[string(key)] = string(value)
+		return nil
+	})
+	require.NoError(t, err)
+	return kvs
+}
@@ -0,0 +1,161 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package execution
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/sunvim/yaoguang/binary"
+	"github.com/sunvim/yaoguang/codes"
+	"github.com/sunvim/yaoguang/execution/contexts"
+	"github.com/sunvim/yaoguang/execution/exec"
+	"github.com/sunvim/yaoguang/state"
+	"github.com/sunvim/yaoguang/txs"
+	"github.com/sunvim/yaoguang/txs/payload"
+	"github.com/sunvim/yaoguang/validators"
+	"github.com/tendermint/tendermint/abci/types"
+)
+
+// A proposal is executed once its voters hold more than ProposalThresholdNumerator/ProposalThresholdDenominator of
+// the total validator power
+const (
+	ProposalThresholdNumerator   = 2
+	ProposalThresholdDenominator = 3
+)
+
+type pendingBallot struct {
+	hash   binary.HexBytes
+	ballot *payload.Ballot
+}
+
+// tallyProposals executes the pending proposals that have reached the threshold and expires those that have run out
+// of time. Votes are weighed by the current power of each voter.
+func (exe *executor) tallyProposals(be *exec.BlockExecution) error {
+	var pending []pendingBallot
+	err := exe.stateCache.IteratePendingBallots(func(hash binary.HexBytes, ballot *payload.Ballot) error {
+		pending = append(pending, pendingBallot{hash: hash, ballot: ballot})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	total, err := validators.TotalPower(exe.stateCache)
+	if err != nil {
+		return err
+	}
+	threshold := new(big.Int).Mul(total, big.NewInt(ProposalThresholdNumerator))
+	for _, pb := range pending {
+		votes := new(big.Int)
+		for _, voter := range pb.ballot.Votes {
+			power, err := exe.stateCache.Power(voter)
+			if err != nil {
+				return err
+			}
+			votes.Add(votes, power)
+		}
+		votes.Mul(votes, big.NewInt(ProposalThresholdDenominator))
+		switch {
+		case total.Sign() > 0 && votes.Cmp(threshold) > 0:
+			err = exe.executeProposal(be, pb.hash, pb.ballot)
+		case pb.ballot.Expires <= be.Height+1:
+			pb.ballot.State = payload.ProposalStateExpired
+			err = exe.stateCache.UpdateBallot(pb.hash, pb.ballot)
+		default:
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		be.Event("proposal",
+			"action", pb.ballot.State.String(),
+			"hash", pb.hash.String())
+	}
+	return nil
+}
+
+// executeProposal runs every payload of the proposal in a cache that is only kept if they all succeed
+func (exe *executor) executeProposal(be *exec.BlockExecution, hash binary.HexBytes, ballot *payload.Ballot) error {
+	cache := state.NewCache(exe.stateCache)
+	var events []types.Event
+	var err error
+	for i, any := range ballot.Proposal.Payloads {
+		ctx, ok := exe.contexts[any.Type()]
+		if !ok || any.Type() == payload.TypeProposal {
+			err = fmt.Errorf("payload %d has unsupported type %v", i, any.Type())
+			break
+		}
+		txe := &exec.TxExecution{
+			TxHash: hash,
+			TxType: any.Type(),
+			Height: be.Height,
+		}
+		if err = executeProposed(ctx, txe, any.Payload, ballot, cache); err != nil {
+			err = fmt.Errorf("payload %d: %w", i, err)
+			break
+		}
+		events = append(events, txe.Events...)
+	}
+	if err != nil {
+		ballot.State = payload.ProposalStateFailed
+		ballot.Error = err.Error()
+	} else {
+		if err := cache.Write(exe.stateCache); err != nil {
+			return err
+		}
+		ballot.State = payload.ProposalStateExecuted
+		be.Events = append(be.Events, events...)
+	}
+	return exe.stateCache.UpdateBallot(hash, ballot)
+}
+
+// The vote of the validators stands in for the permission these payloads need, so an upgrade can be scheduled
+// without a root account
+var authorizedByVote = map[payload.Type]bool{
+	payload.TypeUpgrade: true,
+}
+
+// executeProposed runs a proposed payload, a payload that would panic fails the proposal rather than halt the chain.
+// Proposed payloads are not signed so each input must have consented by proposing or voting for the ballot, and
+// must hold the permission the payload needs as if it had sent it.
+func executeProposed(ctx contexts.Context, txe *exec.TxExecution, p payload.Payload, ballot *payload.Ballot,
+	cache *state.Cache) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("execution panicked: %v", r)
+		}
+	}()
+	if err := txs.CheckInputs(p); err != nil {
+		return err
+	}
+	for _, input := range p.GetInputs() {
+		if input.Address != ballot.Proposer && !ballot.HasVoted(input.Address) {
+			return codes.PermissionDenied.Errorf("input %v neither proposed nor voted for the proposal",
+				input.Address)
+		}
+	}
+	if !authorizedByVote[p.Type()] {
+		if err := checkPermissions(p, cache); err != nil {
+			return err
+		}
+	}
+	return ctx.Execute(txe, p, cache)
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package execution
+
+import (
+	"github.com/sunvim/yaoguang/codes"
+	"github.com/sunvim/yaoguang/permission"
+	"github.com/sunvim/yaoguang/state"
+	"github.com/sunvim/yaoguang/txs/payload"
+)
+
+// The permission each input of a payload must hold, payloads not listed need none. Unbonding is always allowed so
+// revoking Bond cannot trap stake.
+var permissionOf = map[payload.Type]permission.PermFlag{
+	payload.TypeSend:        permission.Send,
+	payload.TypeName:        permission.Name,
+	payload.TypeProposal:    permission.Proposal,
+	payload.TypeBond:        permission.Bond,
+	payload.TypePermissions: permission.ModifyPermissions,
+	payload.TypeUpgrade:     permission.Root,
+}
+
+// checkPermissions ensures every input of p may run it
+func checkPermissions(p payload.Payload, st *state.Cache) error {
+	perm, ok := permissionOf[p.Type()]
+	if !ok {
+		return nil
+	}
+	global, err := st.GetGlobalPermissions()
+	if err != nil {
+		return err
+	}
+	for _, input := range p.GetInputs() {
+		acc, err := st.GetAccount(input.Address)
+		if err != nil {
+			return err
+		}
+		if acc == nil {
+			return codes.UnknownAccount.Errorf("input account %v does not exist", input.Address)
+		}
+		if !permission.HasPermission(global, acc.Permissions.Base, perm) {
+			return codes.PermissionDenied.Errorf("account %v does not have %v permission required by %v",
+				input.Address, perm, p.Type())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package execution
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/sunvim/yaoguang/crypto"
+	"github.com/sunvim/yaoguang/execution/exec"
+	"github.com/sunvim/yaoguang/state"
+	"github.com/tendermint/tendermint/abci/types"
+)
+
+// updateValidators reports every validator whose power differs from the last committed block, including those that
+// have been removed, so Tendermint can apply the changes
+func (exe *executor) updateValidators(be *exec.BlockExecution) error {
+	// Committed validators in address order so every node reports removals in the same order
+	var committed []crypto.Addressable
+	committedPower := make(map[crypto.Address]*big.Int)
+	err := state.NewReader(exe.state).IterateValidators(func(id crypto.Addressable, power *big.Int) error {
+		committed = append(committed, id)
+		committedPower[id.GetAddress()] = power
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	current := make(map[crypto.Address]bool)
+	err = exe.stateCache.IterateValidators(func(id crypto.Addressable, power *big.Int) error {
+		current[id.GetAddress()] = true
+		if previous, ok := committedPower[id.GetAddress()]; ok && previous.Cmp(power) == 0 {
+			return nil
+		}
+		return appendValidatorUpdate(be, id.GetPublicKey(), power)
+	})
+	if err != nil {
+		return err
+	}
+	for _, id := range committed {
+		if !current[id.GetAddress()] {
+			if err := appendValidatorUpdate(be, id.GetPublicKey(), new(big.Int)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func appendValidatorUpdate(be *exec.BlockExecution, publicKey *crypto.PublicKey, power *big.Int) error {
+	pubKey, err := publicKey.ABCIPubKey()
+	if err != nil {
+		return fmt.Errorf("could not convert validator %v: %w", publicKey.GetAddress(), err)
+	}
+	if !power.IsInt64() {
+		return fmt.Errorf("power %v of validator %v does not fit in an int64", power, publicKey.GetAddress())
+	}
+	be.ValidatorUpdates = append(be.ValidatorUpdates, types.ValidatorUpdate{
+		PubKey: pubKey,
+		Power:  power.Int64(),
+	})
+	return nil
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package execution
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/sunvim/yaoguang/execution/exec"
+	"github.com/sunvim/yaoguang/state"
+	"github.com/sunvim/yaoguang/upgrade"
+)
+
+func (exe *executor) BeginBlock(height uint64) (*exec.BlockExecution, error) {
+	exe.Lock()
+	defer exe.Unlock()
+	be := exec.NewBlockExecution(height)
+	plan, err := exe.stateCache.GetUpgradePlan()
+	if err != nil {
+		return nil, err
+	}
+	if plan == nil || plan.Height != height {
+		return be, nil
+	}
+	handler := exe.upgrades.Get(plan.Name)
+	if handler == nil {
+		return nil, fmt.Errorf("%w: this binary cannot run upgrade %s scheduled at height %d, restart the node with "+
+			"one that can (%s)", upgrade.ErrUnknownUpgrade, plan.Name, plan.Height, plan.Info)
+	}
+	current, err := exe.stateCache.GetAppVersion()
+	if err != nil {
+		return nil, err
+	}
+	if handler.AppVersion <= current {
+		return nil, fmt.Errorf("upgrade %s would move app version %d back to %d", plan.Name, current,
+			handler.AppVersion)
+	}
+	// Run the migration in its own cache so a failure leaves no partial changes behind
+	cache := state.NewCache(exe.stateCache)
+	if handler.Migrate != nil {
+		if err := handler.Migrate(cache); err != nil {
+			return nil, fmt.Errorf("upgrade %s failed: %w", plan.Name, err)
+		}
+	}
+	if err := cache.SetAppVersion(handler.AppVersion); err != nil {
+		return nil, err
+	}
+	if err := cache.SetUpgradePlan(nil); err != nil {
+		return nil, err
+	}
+	if err := cache.Write(exe.stateCache); err != nil {
+		return nil, err
+	}
+	exe.upgradedAppVersion = handler.AppVersion
+	be.Event("upgrade",
+		"action", "applied",
+		"name", plan.Name,
+		"app_version", strconv.FormatUint(handler.AppVersion, 10))
+	return be, nil
+}
+
+// updateAppVersion reports an app version changed by an upgrade in this block so Tendermint records it in headers
+func (exe *executor) updateAppVersion(be *exec.BlockExecution) {
+	be.AppVersion = exe.upgradedAppVersion
+	exe.upgradedAppVersion = 0
+}
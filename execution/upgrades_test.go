@@ -0,0 +1,96 @@
+package execution
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/sunvim/yaoguang/codes"
+	"github.com/sunvim/yaoguang/state"
+	"github.com/sunvim/yaoguang/txs"
+	"github.com/sunvim/yaoguang/txs/payload"
+	"github.com/sunvim/yaoguang/upgrade"
+	"github.com/sunvim/yaoguang/version"
+)
+
+func TestExecutor_Upgrade(t *testing.T) {
+	st, bc := newTestState(t)
+	committer := NewBatchCommitter(st, bc)
+	exe := committer.(*executor)
+	exe.upgrades = upgrade.NewRegistry()
+
+	// Only root may schedule an upgrade directly
+	plan := &payload.UpgradePlan{Name: "v2", Height: bc.height + 3}
+	txEnv := txs.Enclose(chainID, payload.NewUpgradeTx(alice.GetAddress(), 1, plan))
+	require.NoError(t, txEnv.Sign(&alice))
+	_, err := committer.Execute(txEnv)
+	assert.True(t, errors.Is(err, codes.PermissionDenied), "expected permission denied but got: %v", err)
+
+	// Validators schedule it with a proposal
+	txEnv = txs.Enclose(chainID, payload.NewProposalTx(alice.GetAddress(), 2,
+		payload.NewProposal("upgrade", "", payload.NewUpgradeTx(alice.GetAddress(), 0, plan))))
+	require.NoError(t, txEnv.Sign(&alice))
+	txe, err := committer.Execute(txEnv)
+	require.NoError(t, err)
+	txEnv = txs.Enclose(chainID, payload.NewVoteTx(bob.GetAddress(), 1, txe.Return))
+	require.NoError(t, txEnv.Sign(&bob))
+	_, err = committer.Execute(txEnv)
+	require.NoError(t, err)
+	endBlock(t, committer, bc)
+
+	scheduled, err := state.NewReader(st).GetUpgradePlan()
+	require.NoError(t, err)
+	assert.Equal(t, plan, scheduled)
+
+	// Nothing happens before the upgrade height
+	be, err := committer.BeginBlock(bc.height + 1)
+	require.NoError(t, err)
+	assert.Len(t, be.Events, 0)
+	endBlock(t, committer, bc)
+
+	// A binary without the handler halts
+	_, err = committer.BeginBlock(plan.Height)
+	assert.True(t, errors.Is(err, upgrade.ErrUnknownUpgrade), "expected unknown upgrade but got: %v", err)
+
+	exe.upgrades.MustRegister(&upgrade.Handler{
+		Name:       "v2",
+		AppVersion: version.AppVersion + 1,
+		Migrate: func(st *state.Cache) error {
+			acc, err := st.GetAccount(bob.GetAddress())
+			if err != nil {
+				return err
+			}
+			acc.Balance *= 2
+			return st.UpdateAccount(acc)
+		},
+	})
+	be, err = committer.BeginBlock(plan.Height)
+	require.NoError(t, err)
+	assert.Len(t, be.Events, 1)
+	be, err = committer.EndBlock(plan.Height)
+	require.NoError(t, err)
+	assert.Equal(t, version.AppVersion+1, be.AppVersion)
+	commit(t, committer, bc)
+
+	reader := state.NewReader(st)
+	appVersion, err := reader.GetAppVersion()
+	require.NoError(t, err)
+	assert.Equal(t, version.AppVersion+1, appVersion)
+	scheduled, err = reader.GetUpgradePlan()
+	require.NoError(t, err)
+	assert.Nil(t, scheduled)
+	acc, err := reader.GetAccount(bob.GetAddress())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2000), acc.Balance)
+
+	// The version is only reported in the block of the upgrade
+	endBlock(t, committer, bc)
+}
+
+func endBlock(t *testing.T, committer BatchCommitter, bc *testBlockchain) {
+	be, err := committer.EndBlock(bc.height + 1)
+	require.NoError(t, err)
+	assert.Zero(t, be.AppVersion)
+	commit(t, committer, bc)
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package genesis
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/sunvim/yaoguang/binary"
+	"github.com/sunvim/yaoguang/crypto"
+	"github.com/sunvim/yaoguang/names"
+	"github.com/sunvim/yaoguang/permission"
+	"github.com/sunvim/yaoguang/txs/payload"
+	"github.com/sunvim/yaoguang/validators"
+)
+
+// AppState is the yaoguang section (app_state) of a Tendermint genesis file
+type AppState struct {
+	Accounts []Account `json:"accounts,omitempty"`
+	// Validators of the first block, when empty they are taken from the validators of the genesis file
+	Validators []Validator `json:"validators,omitempty"`
+	// Permissions of accounts that do not set their own, defaults to permission.DefaultBasePermissions
+	GlobalPermissions *permission.BasePermissions `json:"global_permissions,omitempty"`
+	// The remaining sections carry the state of a running chain exported to start a new one from
+	Names       []*names.Entry          `json:"names,omitempty"`
+	Proposals   []Proposal              `json:"proposals,omitempty"`
+	Delegations []Delegation            `json:"delegations,omitempty"`
+	Unbondings  []*validators.Unbonding `json:"unbondings,omitempty"`
+	// Upgrade scheduled on the exported chain
+	Upgrade *payload.UpgradePlan `json:"upgrade,omitempty"`
+}
+
+type Account struct {
+	Address   crypto.Address    `json:"address"`
+	PublicKey *crypto.PublicKey `json:"public_key,omitempty"`
+	// Sequence of the last transaction the account signed
+	Sequence uint64 `json:"sequence,omitempty"`
+	Balance  uint64 `json:"balance"`
+	// Permissions and roles held by the account
+	Permissions *permission.AccountPermissions `json:"permissions,omitempty"`
+}
+
+type Validator struct {
+	PublicKey *crypto.PublicKey `json:"public_key"`
+	Power     uint64            `json:"power"`
+}
+
+// Proposal is a proposal still open for votes, keyed by its hash
+type Proposal struct {
+	Hash   binary.HexBytes `json:"hash"`
+	Ballot *payload.Ballot `json:"ballot"`
+}
+
+// Delegation is stake bonded by a delegator to a validator
+type Delegation struct {
+	Validator crypto.Address `json:"validator"`
+	Delegator crypto.Address `json:"delegator"`
+	Amount    uint64         `json:"amount"`
+}
+
+// AppStateFromJSON decodes the app_state of a genesis file, an absent app_state is an empty AppState
+func AppStateFromJSON(bs []byte) (*AppState, error) {
+	appState := new(AppState)
+	if len(bs) == 0 || string(bs) == "null" {
+		return appState, nil
+	}
+	if err := json.Unmarshal(bs, appState); err != nil {
+		return nil, fmt.Errorf("could not decode genesis app_state: %w", err)
+	}
+	return appState, nil
+}
+
+func (as *AppState) JSONBytes() ([]byte, error) {
+	return json.MarshalIndent(as, "", "  ")
+}
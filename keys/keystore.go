@@ -0,0 +1,329 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package keys
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sunvim/yaoguang/binary"
+	"github.com/sunvim/yaoguang/crypto"
+	"golang.org/x/crypto/scrypt"
+)
+
+// scrypt cost parameters of new key files. StandardScryptN takes about a second to unlock a key, LightScryptN is for
+// tests and throwaway keys.
+const (
+	StandardScryptN = 1 << 18
+	LightScryptN    = 1 << 12
+	scryptR         = 8
+	scryptP         = 1
+	scryptKeyLen    = 32
+	// a key file asking for more work than this is corrupt or hostile, unlocking it would hang or exhaust memory
+	maxScryptN  = 1 << 20
+	maxScryptRP = 1 << 5
+
+	kdfScrypt    = "scrypt"
+	cipherAESGCM = "aes-256-gcm"
+)
+
+var (
+	ErrKeyNotFound     = errors.New("key not found")
+	ErrWrongPassphrase = errors.New("could not decrypt key, wrong passphrase")
+)
+
+// Key is the public part of a key in the keystore
+type Key struct {
+	// Optional unique name to refer to the key by instead of its address
+	Name      string            `json:"name,omitempty"`
+	Address   crypto.Address    `json:"address"`
+	PublicKey *crypto.PublicKey `json:"public_key"`
+}
+
+// keyFile is the on-disk form of a key, the private key bytes are sealed with AES-GCM under a key derived from the
+// passphrase with scrypt. The address is the additional data so a sealed key cannot be passed off as another.
+type keyFile struct {
+	Key
+	Crypto sealedKey `json:"crypto"`
+}
+
+type sealedKey struct {
+	Cipher     string          `json:"cipher"`
+	CipherText binary.HexBytes `json:"ciphertext"`
+	Nonce      binary.HexBytes `json:"nonce"`
+	KDF        string          `json:"kdf"`
+	KDFParams  scryptParams    `json:"kdfparams"`
+}
+
+type scryptParams struct {
+	N      int             `json:"n"`
+	R      int             `json:"r"`
+	P      int             `json:"p"`
+	KeyLen int             `json:"dklen"`
+	Salt   binary.HexBytes `json:"salt"`
+}
+
+// Keystore keeps passphrase-encrypted keys as one JSON file per key in a directory
+type Keystore struct {
+	dir     string
+	scryptN int
+}
+
+// NewKeystore returns a keystore in dir, which is created when the first key is added
+func NewKeystore(dir string, scryptN int) *Keystore {
+	return &Keystore{
+		dir:     dir,
+		scryptN: scryptN,
+	}
+}
+
+// Generate a new key of curveType and add it to the keystore
+func (ks *Keystore) Generate(name string, curveType crypto.CurveType, passphrase string) (*Key, error) {
+	privateKey, err := crypto.GeneratePrivateKey(nil, curveType)
+	if err != nil {
+		return nil, err
+	}
+	return ks.Import(name, privateKey, passphrase)
+}
+
+// Import adds privateKey to the keystore encrypted with passphrase
+func (ks *Keystore) Import(name string, privateKey crypto.PrivateKey, passphrase string) (*Key, error) {
+	if strings.ContainsAny(name, " \t\n/\\") {
+		return nil, fmt.Errorf("key name %q must not contain whitespace or slashes", name)
+	}
+	key := Key{
+		Name:      name,
+		Address:   privateKey.GetAddress(),
+		PublicKey: privateKey.GetPublicKey(),
+	}
+	if _, err := crypto.AddressFromHexString(name); err == nil {
+		return nil, fmt.Errorf("key name %q must not be an address", name)
+	}
+	keys, err := ks.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, existing := range keys {
+		if existing.Address == key.Address {
+			return nil, fmt.Errorf("key %v is already in the keystore", key.Address)
+		}
+		if name != "" && existing.Name == name {
+			return nil, fmt.Errorf("a key named %q is already in the keystore", name)
+		}
+	}
+	sealed, err := ks.seal(privateKey, key.Address, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	bs, err := json.MarshalIndent(keyFile{Key: key, Crypto: *sealed}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(ks.dir, 0700); err != nil {
+		return nil, fmt.Errorf("could not create keystore directory: %w", err)
+	}
+	if err := os.WriteFile(ks.path(key.Address), bs, 0600); err != nil {
+		return nil, fmt.Errorf("could not write key file: %w", err)
+	}
+	return &key, nil
+}
+
+// List the keys in the keystore ordered by name then address
+func (ks *Keystore) List() ([]*Key, error) {
+	entries, err := os.ReadDir(ks.dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not read keystore directory: %w", err)
+	}
+	var keys []*Key
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		kf, err := readKeyFile(filepath.Join(ks.dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, &kf.Key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Name != keys[j].Name {
+			return keys[i].Name < keys[j].Name
+		}
+		return keys[i].Address.String() < keys[j].Address.String()
+	})
+	return keys, nil
+}
+
+// Get the key with nameOrAddress
+func (ks *Keystore) Get(nameOrAddress string) (*Key, error) {
+	kf, err := ks.find(nameOrAddress)
+	if err != nil {
+		return nil, err
+	}
+	return &kf.Key, nil
+}
+
+// Unlock decrypts the private key with nameOrAddress
+func (ks *Keystore) Unlock(nameOrAddress, passphrase string) (crypto.PrivateKey, error) {
+	kf, err := ks.find(nameOrAddress)
+	if err != nil {
+		return crypto.PrivateKey{}, err
+	}
+	return kf.open(passphrase)
+}
+
+// Delete the key with nameOrAddress
+func (ks *Keystore) Delete(nameOrAddress string) error {
+	kf, err := ks.find(nameOrAddress)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(ks.path(kf.Address)); err != nil {
+		return fmt.Errorf("could not delete key file: %w", err)
+	}
+	return nil
+}
+
+func (ks *Keystore) find(nameOrAddress string) (*keyFile, error) {
+	if address, err := crypto.AddressFromHexString(nameOrAddress); err == nil {
+		kf, err := readKeyFile(ks.path(address))
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %v", ErrKeyNotFound, address)
+		}
+		return kf, err
+	}
+	keys, err := ks.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		if key.Name == nameOrAddress {
+			return readKeyFile(ks.path(key.Address))
+		}
+	}
+	return nil, fmt.Errorf("%w: %q", ErrKeyNotFound, nameOrAddress)
+}
+
+func (ks *Keystore) path(address crypto.Address) string {
+	return filepath.Join(ks.dir, address.String()+".json")
+}
+
+func readKeyFile(path string) (*keyFile, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	kf := new(keyFile)
+	if err := json.Unmarshal(bs, kf); err != nil {
+		return nil, fmt.Errorf("could not decode key file %s: %w", path, err)
+	}
+	if kf.PublicKey == nil || kf.PublicKey.GetAddress() != kf.Address {
+		return nil, fmt.Errorf("key file %s: public key does not match address %v", path, kf.Address)
+	}
+	return kf, nil
+}
+
+func (ks *Keystore) seal(privateKey crypto.PrivateKey, address crypto.Address, passphrase string) (*sealedKey, error) {
+	params := scryptParams{
+		N:      ks.scryptN,
+		R:      scryptR,
+		P:      scryptP,
+		KeyLen: scryptKeyLen,
+		Salt:   make([]byte, 32),
+	}
+	if _, err := io.ReadFull(rand.Reader, params.Salt); err != nil {
+		return nil, err
+	}
+	aead, err := params.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return &sealedKey{
+		Cipher:     cipherAESGCM,
+		CipherText: aead.Seal(nil, nonce, privateKey.RawBytes(), address.Bytes()),
+		Nonce:      nonce,
+		KDF:        kdfScrypt,
+		KDFParams:  params,
+	}, nil
+}
+
+func (kf *keyFile) open(passphrase string) (crypto.PrivateKey, error) {
+	if kf.Crypto.Cipher != cipherAESGCM || kf.Crypto.KDF != kdfScrypt {
+		return crypto.PrivateKey{}, fmt.Errorf("unsupported key encryption %s with %s", kf.Crypto.Cipher,
+			kf.Crypto.KDF)
+	}
+	aead, err := kf.Crypto.KDFParams.aead(passphrase)
+	if err != nil {
+		return crypto.PrivateKey{}, err
+	}
+	if len(kf.Crypto.Nonce) != aead.NonceSize() {
+		return crypto.PrivateKey{}, fmt.Errorf("key file nonce has length %d but %s takes %d", len(kf.Crypto.Nonce),
+			cipherAESGCM, aead.NonceSize())
+	}
+	raw, err := aead.Open(nil, kf.Crypto.Nonce, kf.Crypto.CipherText, kf.Address.Bytes())
+	if err != nil {
+		return crypto.PrivateKey{}, ErrWrongPassphrase
+	}
+	privateKey, err := crypto.PrivateKeyFromRawBytes(raw, kf.PublicKey.CurveType)
+	if err != nil {
+		return crypto.PrivateKey{}, err
+	}
+	if privateKey.GetAddress() != kf.Address {
+		return crypto.PrivateKey{}, fmt.Errorf("decrypted key does not match address %v", kf.Address)
+	}
+	return privateKey, nil
+}
+
+func (params scryptParams) aead(passphrase string) (cipher.AEAD, error) {
+	if params.KeyLen != scryptKeyLen {
+		return nil, fmt.Errorf("key file has derived key length %d but %s takes %d", params.KeyLen, cipherAESGCM,
+			scryptKeyLen)
+	}
+	if params.N > maxScryptN {
+		return nil, fmt.Errorf("key file has scrypt N of %d, more than the maximum of %d", params.N, maxScryptN)
+	}
+	if params.R > maxScryptRP || params.P > maxScryptRP {
+		return nil, fmt.Errorf("key file has scrypt r of %d and p of %d, the maximum of each is %d", params.R,
+			params.P, maxScryptRP)
+	}
+	derived, err := scrypt.Key([]byte(passphrase), params.Salt, params.N, params.R, params.P, params.KeyLen)
+	if err != nil {
+		return nil, fmt.Errorf("could not derive key from passphrase: %w", err)
+	}
+	block, err := aes.NewCipher(derived)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
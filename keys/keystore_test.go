@@ -0,0 +1,101 @@
+package keys
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/sunvim/yaoguang/crypto"
+)
+
+func TestKeystore(t *testing.T) {
+	ks := NewKeystore(t.TempDir(), LightScryptN)
+
+	keys, err := ks.List()
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	for _, curveType := range []crypto.CurveType{crypto.CurveTypeEd25519, crypto.CurveTypeSecp256k1} {
+		t.Run(curveType.String(), func(t *testing.T) {
+			key, err := ks.Generate("key-"+curveType.String(), curveType, "secret")
+			require.NoError(t, err)
+			assert.Equal(t, key.PublicKey.GetAddress(), key.Address)
+
+			byName, err := ks.Get(key.Name)
+			require.NoError(t, err)
+			byAddress, err := ks.Get(key.Address.String())
+			require.NoError(t, err)
+			assert.Equal(t, key, byName)
+			assert.Equal(t, key, byAddress)
+
+			privateKey, err := ks.Unlock(key.Name, "secret")
+			require.NoError(t, err)
+			assert.Equal(t, key.Address, privateKey.GetAddress())
+			msg := []byte("message")
+			sig, err := privateKey.Sign(msg)
+			require.NoError(t, err)
+			assert.NoError(t, key.PublicKey.Verify(msg, sig))
+
+			_, err = ks.Unlock(key.Name, "wrong")
+			assert.True(t, errors.Is(err, ErrWrongPassphrase))
+		})
+	}
+
+	keys, err = ks.List()
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	assert.Equal(t, "key-ed25519", keys[0].Name)
+
+	t.Run("Import", func(t *testing.T) {
+		privateKey := crypto.PrivateKeyFromSecret("imported", crypto.CurveTypeSecp256k1)
+		key, err := ks.Import("", privateKey, "other")
+		require.NoError(t, err)
+		unlocked, err := ks.Unlock(key.Address.String(), "other")
+		require.NoError(t, err)
+		assert.Equal(t, privateKey.RawBytes(), unlocked.RawBytes())
+
+		_, err = ks.Import("", privateKey, "other")
+		assert.Error(t, err, "the same key cannot be imported twice")
+		_, err = ks.Import("key-ed25519", crypto.PrivateKeyFromSecret("clash", crypto.CurveTypeEd25519), "other")
+		assert.Error(t, err, "names are unique")
+		_, err = ks.Import(key.Address.String(), crypto.PrivateKeyFromSecret("clash", crypto.CurveTypeEd25519), "")
+		assert.Error(t, err, "a name cannot be an address")
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		require.NoError(t, ks.Delete("key-ed25519"))
+		_, err := ks.Get("key-ed25519")
+		assert.True(t, errors.Is(err, ErrKeyNotFound))
+		assert.True(t, errors.Is(ks.Delete("key-ed25519"), ErrKeyNotFound))
+		keys, err := ks.List()
+		require.NoError(t, err)
+		assert.Len(t, keys, 2)
+	})
+}
+
+func TestKeystore_CorruptKeyFile(t *testing.T) {
+	ks := NewKeystore(t.TempDir(), LightScryptN)
+	key, err := ks.Import("key", crypto.PrivateKeyFromSecret("key", crypto.CurveTypeEd25519), "secret")
+	require.NoError(t, err)
+	original, err := readKeyFile(ks.path(key.Address))
+	require.NoError(t, err)
+
+	for name, corrupt := range map[string]func(kf *keyFile){
+		"short nonce":   func(kf *keyFile) { kf.Crypto.Nonce = kf.Crypto.Nonce[:4] },
+		"short key":     func(kf *keyFile) { kf.Crypto.KDFParams.KeyLen = 16 },
+		"huge scrypt":   func(kf *keyFile) { kf.Crypto.KDFParams.N = 1 << 40 },
+		"huge scrypt r": func(kf *keyFile) { kf.Crypto.KDFParams.R = 1 << 20 },
+	} {
+		kf := *original
+		corrupt(&kf)
+		bs, err := json.Marshal(kf)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(ks.path(key.Address), bs, 0600))
+		_, err = ks.Unlock("key", "secret")
+		assert.Error(t, err, name)
+		assert.False(t, errors.Is(err, ErrWrongPassphrase), name)
+	}
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package names
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/sunvim/yaoguang/codes"
+	"github.com/sunvim/yaoguang/crypto"
+)
+
+const (
+	MaxNameLength = 64
+	MaxDataLength = 1 << 10
+	// The shortest lease that can be bought when registering a name
+	MinLeaseBlocks = 5
+	// Lease fee per block is NameBaseCost plus NameByteCost for each byte of name and data
+	NameBaseCost uint64 = 32
+	NameByteCost uint64 = 1
+)
+
+var nameRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// Entry is a leased human-readable handle resolving to its owner's address
+type Entry struct {
+	Name  string         `json:"name"`
+	Owner crypto.Address `json:"owner"`
+	Data  string         `json:"data,omitempty"`
+	// Height of the first block at which the lease has run out
+	Expires uint64 `json:"expires"`
+}
+
+// CostPerBlock is the lease fee per block for name holding data
+func CostPerBlock(name, data string) uint64 {
+	return NameBaseCost + NameByteCost*uint64(len(name)+len(data))
+}
+
+func ValidateName(name string) error {
+	if len(name) == 0 || len(name) > MaxNameLength {
+		return codes.InvalidTx.Errorf("name must have between 1 and %d characters but has %d", MaxNameLength,
+			len(name))
+	}
+	if !nameRegexp.MatchString(name) {
+		return codes.InvalidTx.Errorf("name %q must start with a lowercase letter or digit and contain only "+
+			"lowercase letters, digits, '.', '_' or '-'", name)
+	}
+	return nil
+}
+
+func ValidateData(data string) error {
+	if len(data) > MaxDataLength {
+		return codes.InvalidTx.Errorf("name data must be at most %d bytes but is %d", MaxDataLength, len(data))
+	}
+	return nil
+}
+
+// Expired reports whether the lease has run out by height
+func (e *Entry) Expired(height uint64) bool {
+	return e.Expires <= height
+}
+
+func (e *Entry) Encode() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+func Decode(bs []byte) (*Entry, error) {
+	entry := new(Entry)
+	if err := json.Unmarshal(bs, entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (e *Entry) String() string {
+	return fmt.Sprintf("NameEntry{%s -> %v; Expires: %d}", e.Name, e.Owner, e.Expires)
+}
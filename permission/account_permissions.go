@@ -0,0 +1,78 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package permission
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// MaxRoleLength is the longest role name allowed
+const MaxRoleLength = 64
+
+var roleRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// AccountPermissions are the permissions of a single account and the roles it holds. Roles carry no meaning to the
+// executor, applications use them to recognise operators.
+type AccountPermissions struct {
+	Base  BasePermissions `json:"base"`
+	Roles []string        `json:"roles,omitempty"`
+}
+
+func ValidateRole(role string) error {
+	if len(role) == 0 || len(role) > MaxRoleLength || !roleRegexp.MatchString(role) {
+		return fmt.Errorf("invalid role %q, roles are 1 to %d letters, digits, '.', '_' or '-'", role,
+			MaxRoleLength)
+	}
+	return nil
+}
+
+func (ap *AccountPermissions) HasRole(role string) bool {
+	i := sort.SearchStrings(ap.Roles, role)
+	return i < len(ap.Roles) && ap.Roles[i] == role
+}
+
+// AddRole returns false if the account already held role
+func (ap *AccountPermissions) AddRole(role string) bool {
+	i := sort.SearchStrings(ap.Roles, role)
+	if i < len(ap.Roles) && ap.Roles[i] == role {
+		return false
+	}
+	ap.Roles = append(ap.Roles, "")
+	copy(ap.Roles[i+1:], ap.Roles[i:])
+	ap.Roles[i] = role
+	return true
+}
+
+// RemoveRole returns false if the account did not hold role
+func (ap *AccountPermissions) RemoveRole(role string) bool {
+	i := sort.SearchStrings(ap.Roles, role)
+	if i == len(ap.Roles) || ap.Roles[i] != role {
+		return false
+	}
+	ap.Roles = append(ap.Roles[:i], ap.Roles[i+1:]...)
+	if len(ap.Roles) == 0 {
+		ap.Roles = nil
+	}
+	return true
+}
+
+func (ap AccountPermissions) String() string {
+	return fmt.Sprintf("AccountPermissions{Base: %v; Roles: %v}", ap.Base, ap.Roles)
+}
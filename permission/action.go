@@ -0,0 +1,40 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package permission
+
+import (
+	"fmt"
+)
+
+// Action is a change made by a PermissionsTx
+type Action string
+
+const (
+	SetBase    Action = "set_base"
+	UnsetBase  Action = "unset_base"
+	AddRole    Action = "add_role"
+	RemoveRole Action = "remove_role"
+)
+
+func (a Action) Validate() error {
+	switch a {
+	case SetBase, UnsetBase, AddRole, RemoveRole:
+		return nil
+	}
+	return fmt.Errorf("unknown permissions action %q", string(a))
+}
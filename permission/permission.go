@@ -0,0 +1,144 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package permission
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PermFlag is a bitset of permissions
+type PermFlag uint64
+
+// Base permission flags, each guards a kind of transaction except Root which implies every other
+const (
+	Root PermFlag = 1 << iota
+	Send
+	Name
+	Proposal
+	Bond
+	// Grant and revoke permissions and roles with PermissionsTx
+	ModifyPermissions
+
+	NumPermissions uint = 6
+
+	AllPermFlags PermFlag = 1<<NumPermissions - 1
+	// Held by every account unless revoked globally or for the account
+	DefaultPermFlags PermFlag = Send | Name | Proposal | Bond
+	ZeroPermissions  PermFlag = 0
+)
+
+var nameFromFlag = map[PermFlag]string{
+	Root:              "root",
+	Send:              "send",
+	Name:              "name",
+	Proposal:          "proposal",
+	Bond:              "bond",
+	ModifyPermissions: "modifyPermissions",
+}
+
+// PermStringToFlag returns the flag for a single permission name
+func PermStringToFlag(perm string) (PermFlag, error) {
+	for flag, name := range nameFromFlag {
+		if name == perm {
+			return flag, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown permission %s", perm)
+}
+
+// PermFlagFromStringList combines the named permissions into a single bitset
+func PermFlagFromStringList(perms []string) (PermFlag, error) {
+	var flags PermFlag
+	for _, perm := range perms {
+		flag, err := PermStringToFlag(perm)
+		if err != nil {
+			return 0, err
+		}
+		flags |= flag
+	}
+	return flags, nil
+}
+
+// String lists the names of the permissions in the bitset separated by |
+func (pf PermFlag) String() string {
+	var names []string
+	for i := uint(0); i < NumPermissions; i++ {
+		flag := PermFlag(1) << i
+		if pf&flag != 0 {
+			names = append(names, nameFromFlag[flag])
+		}
+	}
+	return strings.Join(names, " | ")
+}
+
+// BasePermissions holds a value for each permission. Only permissions whose SetBit is set have a value, the others
+// fall through to the permissions they are composed with.
+type BasePermissions struct {
+	Perms  PermFlag `json:"perms"`
+	SetBit PermFlag `json:"set_bit"`
+}
+
+// DefaultBasePermissions are the global permissions of a chain whose genesis does not set them
+var DefaultBasePermissions = BasePermissions{
+	Perms:  DefaultPermFlags,
+	SetBit: AllPermFlags,
+}
+
+// Get returns whether every permission in perm is granted and whether they all have a value
+func (bp BasePermissions) Get(perm PermFlag) (value, set bool) {
+	return bp.Perms&perm == perm, bp.SetBit&perm == perm
+}
+
+// Set the value of perm
+func (bp *BasePermissions) Set(perm PermFlag, value bool) {
+	if value {
+		bp.Perms |= perm
+	} else {
+		bp.Perms &^= perm
+	}
+	bp.SetBit |= perm
+}
+
+// Unset clears the value of perm so it falls through
+func (bp *BasePermissions) Unset(perm PermFlag) {
+	bp.Perms &^= perm
+	bp.SetBit &^= perm
+}
+
+// Compose takes the permissions bp has values for and the rest from fallback
+func (bp BasePermissions) Compose(fallback BasePermissions) BasePermissions {
+	return BasePermissions{
+		Perms:  (bp.Perms & bp.SetBit) | (fallback.Perms &^ bp.SetBit),
+		SetBit: bp.SetBit | fallback.SetBit,
+	}
+}
+
+func (bp BasePermissions) String() string {
+	return fmt.Sprintf("Base{Perms: %v; SetBit: %v}", bp.Perms, bp.SetBit)
+}
+
+// HasPermission reports whether an account with base permissions has perm given the global permissions
+func HasPermission(global, base BasePermissions, perm PermFlag) bool {
+	composed := base.Compose(global)
+	if root, _ := composed.Get(Root); root {
+		return true
+	}
+	value, _ := composed.Get(perm)
+	return value
+}
@@ -0,0 +1,45 @@
+package permission
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHasPermission(t *testing.T) {
+	global := DefaultBasePermissions
+	var base BasePermissions
+	assert.True(t, HasPermission(global, base, Send))
+	assert.False(t, HasPermission(global, base, ModifyPermissions))
+
+	base.Set(Send, false)
+	assert.False(t, HasPermission(global, base, Send))
+	base.Unset(Send)
+	assert.True(t, HasPermission(global, base, Send))
+
+	base.Set(Root, true)
+	assert.True(t, HasPermission(global, base, ModifyPermissions))
+}
+
+func TestPermFlagFromStringList(t *testing.T) {
+	flags, err := PermFlagFromStringList([]string{"send", "bond"})
+	require.NoError(t, err)
+	assert.Equal(t, Send|Bond, flags)
+	assert.Equal(t, "send | bond", flags.String())
+	_, err = PermFlagFromStringList([]string{"fly"})
+	assert.Error(t, err)
+}
+
+func TestAccountPermissions_Roles(t *testing.T) {
+	var ap AccountPermissions
+	assert.True(t, ap.AddRole("b"))
+	assert.True(t, ap.AddRole("a"))
+	assert.False(t, ap.AddRole("a"))
+	assert.Equal(t, []string{"a", "b"}, ap.Roles)
+	assert.True(t, ap.HasRole("b"))
+	assert.True(t, ap.RemoveRole("b"))
+	assert.False(t, ap.RemoveRole("b"))
+	assert.False(t, ap.HasRole("b"))
+	assert.Error(t, ValidateRole("no spaces"))
+}
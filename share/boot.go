@@ -20,4 +20,10 @@ package share
 const (
 	BootConfig   = "config"
 	BootNodeInfo = "node"
+	// Connect the node to an application served separately by yaoguang abci rather than running it in-process
+	ExternalABCI = "yaoguang.external-abci"
+	// Which versions of the application state to keep, see state.NewPruningOptions
+	Pruning           = "yaoguang.pruning"
+	PruningKeepRecent = "yaoguang.pruning-keep-recent"
+	PruningKeepEvery  = "yaoguang.pruning-keep-every"
 )
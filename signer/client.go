@@ -0,0 +1,106 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package signer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sunvim/yaoguang/crypto"
+	"google.golang.org/grpc"
+)
+
+// How long a Sign call through a RemoteSigner may take
+const SignTimeout = 10 * time.Second
+
+// Client of a remote signer
+type Client struct {
+	conn   *grpc.ClientConn
+	client SignerClient
+}
+
+// Dial a remote signer at address, opts carry the credentials
+func Dial(address string, opts ...grpc.DialOption) (*Client, error) {
+	conn, err := grpc.Dial(address, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to signer at %s: %w", address, err)
+	}
+	return &Client{
+		conn:   conn,
+		client: NewSignerClient(conn),
+	}, nil
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// ListKeys returns the keys the remote signer signs with
+func (c *Client) ListKeys(ctx context.Context) (*ListKeysResponse, error) {
+	return c.client.ListKeys(ctx, &ListKeysRequest{})
+}
+
+// Signer returns a signer for address whose private key never leaves the remote signer
+func (c *Client) Signer(ctx context.Context, address crypto.Address) (*RemoteSigner, error) {
+	rsp, err := c.client.GetPublicKey(ctx, &PublicKeyRequest{Address: address})
+	if err != nil {
+		return nil, err
+	}
+	if !rsp.PublicKey.IsSet() || rsp.PublicKey.GetAddress() != address {
+		return nil, fmt.Errorf("signer returned a public key that does not match %v", address)
+	}
+	return &RemoteSigner{
+		client:    c.client,
+		publicKey: rsp.PublicKey,
+	}, nil
+}
+
+// RemoteSigner implements crypto.AddressableSigner with a key held by a remote signer
+type RemoteSigner struct {
+	client    SignerClient
+	publicKey *crypto.PublicKey
+}
+
+var _ crypto.AddressableSigner = (*RemoteSigner)(nil)
+
+func (rs *RemoteSigner) GetAddress() crypto.Address {
+	return rs.publicKey.GetAddress()
+}
+
+func (rs *RemoteSigner) GetPublicKey() *crypto.PublicKey {
+	return rs.publicKey
+}
+
+// Sign msg remotely, the signature is checked against the public key so a faulty signer is caught before a
+// transaction is broadcast
+func (rs *RemoteSigner) Sign(msg []byte) (*crypto.Signature, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), SignTimeout)
+	defer cancel()
+	rsp, err := rs.client.Sign(ctx, &SignRequest{Address: rs.GetAddress(), Message: msg})
+	if err != nil {
+		return nil, err
+	}
+	if rsp.Signature == nil {
+		return nil, fmt.Errorf("signer returned no signature")
+	}
+	if err := rs.publicKey.Verify(msg, rsp.Signature); err != nil {
+		return nil, fmt.Errorf("signer returned an invalid signature: %w", err)
+	}
+	return rsp.Signature, nil
+}
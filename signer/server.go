@@ -0,0 +1,87 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package signer
+
+import (
+	"context"
+	"sort"
+
+	"github.com/sunvim/yaoguang/crypto"
+	"github.com/sunvim/yaoguang/keys"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Server signs with a set of unlocked keys
+type Server struct {
+	keys        []*keys.Key
+	privateKeys map[crypto.Address]crypto.PrivateKey
+}
+
+var _ SignerServer = (*Server)(nil)
+
+// NewServer serves signatures by keys, privateKeys must hold the private key of each of them
+func NewServer(ks []*keys.Key, privateKeys ...crypto.PrivateKey) *Server {
+	srv := &Server{
+		privateKeys: make(map[crypto.Address]crypto.PrivateKey, len(privateKeys)),
+	}
+	for _, privateKey := range privateKeys {
+		srv.privateKeys[privateKey.GetAddress()] = privateKey
+	}
+	for _, key := range ks {
+		if _, ok := srv.privateKeys[key.Address]; ok {
+			srv.keys = append(srv.keys, key)
+		}
+	}
+	sort.Slice(srv.keys, func(i, j int) bool {
+		return srv.keys[i].Address.String() < srv.keys[j].Address.String()
+	})
+	return srv
+}
+
+func (srv *Server) ListKeys(context.Context, *ListKeysRequest) (*ListKeysResponse, error) {
+	return &ListKeysResponse{Keys: srv.keys}, nil
+}
+
+func (srv *Server) GetPublicKey(_ context.Context, req *PublicKeyRequest) (*PublicKeyResponse, error) {
+	privateKey, err := srv.privateKey(req.Address)
+	if err != nil {
+		return nil, err
+	}
+	return &PublicKeyResponse{PublicKey: privateKey.GetPublicKey()}, nil
+}
+
+func (srv *Server) Sign(_ context.Context, req *SignRequest) (*SignResponse, error) {
+	privateKey, err := srv.privateKey(req.Address)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := privateKey.Sign(req.Message)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "could not sign: %v", err)
+	}
+	return &SignResponse{Signature: sig}, nil
+}
+
+func (srv *Server) privateKey(address crypto.Address) (crypto.PrivateKey, error) {
+	privateKey, ok := srv.privateKeys[address]
+	if !ok {
+		return crypto.PrivateKey{}, status.Errorf(codes.NotFound, "no key for %v", address)
+	}
+	return privateKey, nil
+}
@@ -0,0 +1,184 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package signer
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/sunvim/yaoguang/binary"
+	"github.com/sunvim/yaoguang/crypto"
+	"github.com/sunvim/yaoguang/keys"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+)
+
+// Messages are JSON encoded so the crypto types travel in the same form as in key files and genesis
+const codecName = "json"
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (jsonCodec) Name() string {
+	return codecName
+}
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+type ListKeysRequest struct{}
+
+type ListKeysResponse struct {
+	Keys []*keys.Key `json:"keys"`
+}
+
+type PublicKeyRequest struct {
+	Address crypto.Address `json:"address"`
+}
+
+type PublicKeyResponse struct {
+	PublicKey *crypto.PublicKey `json:"public_key"`
+}
+
+type SignRequest struct {
+	Address crypto.Address  `json:"address"`
+	Message binary.HexBytes `json:"message"`
+}
+
+type SignResponse struct {
+	Signature *crypto.Signature `json:"signature"`
+}
+
+// SignerServer is the remote signer service
+type SignerServer interface {
+	ListKeys(context.Context, *ListKeysRequest) (*ListKeysResponse, error)
+	GetPublicKey(context.Context, *PublicKeyRequest) (*PublicKeyResponse, error)
+	Sign(context.Context, *SignRequest) (*SignResponse, error)
+}
+
+const serviceName = "yaoguang.signer.Signer"
+
+func RegisterSignerServer(s *grpc.Server, srv SignerServer) {
+	s.RegisterService(&signerServiceDesc, srv)
+}
+
+var signerServiceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*SignerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListKeys",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error,
+				interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				return handle(srv, ctx, dec, interceptor, "ListKeys", new(ListKeysRequest),
+					func(s SignerServer, ctx context.Context, req interface{}) (interface{}, error) {
+						return s.ListKeys(ctx, req.(*ListKeysRequest))
+					})
+			},
+		},
+		{
+			MethodName: "GetPublicKey",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error,
+				interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				return handle(srv, ctx, dec, interceptor, "GetPublicKey", new(PublicKeyRequest),
+					func(s SignerServer, ctx context.Context, req interface{}) (interface{}, error) {
+						return s.GetPublicKey(ctx, req.(*PublicKeyRequest))
+					})
+			},
+		},
+		{
+			MethodName: "Sign",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error,
+				interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				return handle(srv, ctx, dec, interceptor, "Sign", new(SignRequest),
+					func(s SignerServer, ctx context.Context, req interface{}) (interface{}, error) {
+						return s.Sign(ctx, req.(*SignRequest))
+					})
+			},
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "signer",
+}
+
+func handle(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor,
+	method string, req interface{},
+	call func(s SignerServer, ctx context.Context, req interface{}) (interface{}, error)) (interface{}, error) {
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return call(srv.(SignerServer), ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: fullMethod(method),
+	}
+	return interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return call(srv.(SignerServer), ctx, req)
+	})
+}
+
+func fullMethod(method string) string {
+	return "/" + serviceName + "/" + method
+}
+
+// SignerClient calls a remote SignerServer
+type SignerClient interface {
+	ListKeys(ctx context.Context, in *ListKeysRequest, opts ...grpc.CallOption) (*ListKeysResponse, error)
+	GetPublicKey(ctx context.Context, in *PublicKeyRequest, opts ...grpc.CallOption) (*PublicKeyResponse, error)
+	Sign(ctx context.Context, in *SignRequest, opts ...grpc.CallOption) (*SignResponse, error)
+}
+
+type signerClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSignerClient(cc grpc.ClientConnInterface) SignerClient {
+	return &signerClient{cc}
+}
+
+func (c *signerClient) ListKeys(ctx context.Context, in *ListKeysRequest,
+	opts ...grpc.CallOption) (*ListKeysResponse, error) {
+	out := new(ListKeysResponse)
+	return out, c.invoke(ctx, "ListKeys", in, out, opts)
+}
+
+func (c *signerClient) GetPublicKey(ctx context.Context, in *PublicKeyRequest,
+	opts ...grpc.CallOption) (*PublicKeyResponse, error) {
+	out := new(PublicKeyResponse)
+	return out, c.invoke(ctx, "GetPublicKey", in, out, opts)
+}
+
+func (c *signerClient) Sign(ctx context.Context, in *SignRequest, opts ...grpc.CallOption) (*SignResponse, error) {
+	out := new(SignResponse)
+	return out, c.invoke(ctx, "Sign", in, out, opts)
+}
+
+func (c *signerClient) invoke(ctx context.Context, method string, in, out interface{}, opts []grpc.CallOption) error {
+	opts = append([]grpc.CallOption{grpc.CallContentSubtype(codecName)}, opts...)
+	return c.cc.Invoke(ctx, fullMethod(method), in, out, opts...)
+}
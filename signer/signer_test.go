@@ -0,0 +1,66 @@
+package signer
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/sunvim/yaoguang/crypto"
+	"github.com/sunvim/yaoguang/keys"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+func TestRemoteSigner(t *testing.T) {
+	const secret = "shared secret"
+	privateKeys := []crypto.PrivateKey{
+		crypto.PrivateKeyFromSecret("ed", crypto.CurveTypeEd25519),
+		crypto.PrivateKeyFromSecret("secp", crypto.CurveTypeSecp256k1),
+	}
+	var ks []*keys.Key
+	for _, privateKey := range privateKeys {
+		ks = append(ks, &keys.Key{Address: privateKey.GetAddress(), PublicKey: privateKey.GetPublicKey()})
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := grpc.NewServer(grpc.UnaryInterceptor(SecretInterceptor(secret)))
+	RegisterSignerServer(server, NewServer(ks, privateKeys...))
+	go server.Serve(listener)
+	defer server.Stop()
+
+	dial := func(secret string) *Client {
+		client, err := Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithPerRPCCredentials(SecretCredentials(secret, true)))
+		require.NoError(t, err)
+		t.Cleanup(func() { client.Close() })
+		return client
+	}
+	ctx := context.Background()
+
+	client := dial(secret)
+	rsp, err := client.ListKeys(ctx)
+	require.NoError(t, err)
+	assert.Len(t, rsp.Keys, 2)
+
+	for _, privateKey := range privateKeys {
+		signer, err := client.Signer(ctx, privateKey.GetAddress())
+		require.NoError(t, err)
+		assert.Equal(t, privateKey.GetPublicKey(), signer.GetPublicKey())
+		msg := []byte("transaction")
+		sig, err := signer.Sign(msg)
+		require.NoError(t, err)
+		assert.NoError(t, privateKey.GetPublicKey().Verify(msg, sig))
+	}
+
+	other := crypto.PrivateKeyFromSecret("other", crypto.CurveTypeEd25519)
+	_, err = client.Signer(ctx, other.GetAddress())
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = dial("wrong").ListKeys(ctx)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
@@ -0,0 +1,75 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package state
+
+import (
+	"fmt"
+
+	"github.com/sunvim/yaoguang/account"
+	"github.com/sunvim/yaoguang/crypto"
+	"github.com/sunvim/yaoguang/storage"
+)
+
+var accountPrefix = storage.NewPrefix("a/")
+
+// AccountKey is the key an account is stored under, to prove it with State.Prove
+func AccountKey(address crypto.Address) []byte {
+	return accountPrefix.Key(address.Bytes())
+}
+
+// GetAccount returns nil if the account does not exist
+func (r *Reader) GetAccount(address crypto.Address) (*account.Account, error) {
+	bs, err := r.kv.Get(AccountKey(address))
+	if err != nil {
+		return nil, err
+	}
+	if bs == nil {
+		return nil, nil
+	}
+	acc, err := account.Decode(bs)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode account %v: %w", address, err)
+	}
+	return acc, nil
+}
+
+// IterateAccounts in address order
+func (r *Reader) IterateAccounts(fn func(acc *account.Account) error) error {
+	return accountPrefix.Iterate(r.kv, func(key, value []byte) error {
+		acc, err := account.Decode(value)
+		if err != nil {
+			return fmt.Errorf("could not decode account %X: %w", key, err)
+		}
+		return fn(acc)
+	})
+}
+
+func (c *Cache) UpdateAccount(acc *account.Account) error {
+	if acc == nil {
+		return fmt.Errorf("UpdateAccount passed nil account")
+	}
+	bs, err := acc.Encode()
+	if err != nil {
+		return fmt.Errorf("could not encode account %v: %w", acc.Address, err)
+	}
+	return c.Set(accountPrefix.Key(acc.Address.Bytes()), bs)
+}
+
+func (c *Cache) RemoveAccount(address crypto.Address) error {
+	return c.Delete(accountPrefix.Key(address.Bytes()))
+}
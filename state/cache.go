@@ -0,0 +1,46 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package state
+
+import (
+	"github.com/sunvim/yaoguang/storage"
+)
+
+// Reader provides typed read access to application state held in any key-value store
+type Reader struct {
+	kv storage.KVReaderIterable
+}
+
+func NewReader(kv storage.KVReaderIterable) *Reader {
+	return &Reader{kv: kv}
+}
+
+// Cache provides typed read and write access to application state. Changes are buffered until written to the
+// backend, so caches nest: a transaction cache over a block cache over the committed State.
+type Cache struct {
+	Reader
+	*storage.KVCache
+}
+
+func NewCache(backend storage.KVReaderIterable) *Cache {
+	kvc := storage.NewKVCache(backend)
+	return &Cache{
+		Reader:  Reader{kv: kvc},
+		KVCache: kvc,
+	}
+}
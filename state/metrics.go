@@ -0,0 +1,77 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package state
+
+import (
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/discard"
+	"github.com/go-kit/kit/metrics/prometheus"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+)
+
+// MetricsSubsystem prefixes the metrics of the application state, which are served alongside Tendermint's
+const MetricsSubsystem = "app_state"
+
+// Metrics of pruning the state tree
+type Metrics struct {
+	// Number of versions deleted by pruning
+	PrunedVersions metrics.Counter
+	// Number of versions on disk after the last prune
+	RetainedVersions metrics.Gauge
+	// Time taken by each prune
+	PruneTime metrics.Histogram
+}
+
+// PrometheusMetrics registers the metrics with the default prometheus registry. Labels are passed as pairs of name
+// and value.
+func PrometheusMetrics(namespace string, labelsAndValues ...string) *Metrics {
+	var labels []string
+	for i := 0; i < len(labelsAndValues); i += 2 {
+		labels = append(labels, labelsAndValues[i])
+	}
+	return &Metrics{
+		PrunedVersions: prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: MetricsSubsystem,
+			Name:      "pruned_versions",
+			Help:      "Number of versions of the state deleted by pruning.",
+		}, labels).With(labelsAndValues...),
+		RetainedVersions: prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: MetricsSubsystem,
+			Name:      "retained_versions",
+			Help:      "Number of versions of the state on disk after the last prune.",
+		}, labels).With(labelsAndValues...),
+		PruneTime: prometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: MetricsSubsystem,
+			Name:      "prune_time",
+			Help:      "Time taken to prune the state in seconds.",
+			Buckets:   stdprometheus.ExponentialBuckets(0.001, 4, 8),
+		}, labels).With(labelsAndValues...),
+	}
+}
+
+// NopMetrics discards all metrics
+func NopMetrics() *Metrics {
+	return &Metrics{
+		PrunedVersions:   discard.NewCounter(),
+		RetainedVersions: discard.NewGauge(),
+		PruneTime:        discard.NewHistogram(),
+	}
+}
@@ -0,0 +1,133 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package state
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/sunvim/yaoguang/names"
+	"github.com/sunvim/yaoguang/storage"
+)
+
+var (
+	namePrefix = storage.NewPrefix("n/")
+	// Index of names by expiry height so leases can be swept at the end of each block
+	nameExpiryPrefix = storage.NewPrefix("nx/")
+)
+
+// NameKey is the key the entry of name is stored under, to prove it with State.Prove
+func NameKey(name string) []byte {
+	return namePrefix.Key([]byte(name))
+}
+
+// GetName returns the entry for name, including an expired one that has not yet been swept, or nil if there is none
+func (r *Reader) GetName(name string) (*names.Entry, error) {
+	bs, err := r.kv.Get(NameKey(name))
+	if err != nil {
+		return nil, err
+	}
+	if bs == nil {
+		return nil, nil
+	}
+	entry, err := names.Decode(bs)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode name entry %s: %w", name, err)
+	}
+	return entry, nil
+}
+
+// IterateNames in name order
+func (r *Reader) IterateNames(fn func(entry *names.Entry) error) error {
+	return namePrefix.Iterate(r.kv, func(key, value []byte) error {
+		entry, err := names.Decode(value)
+		if err != nil {
+			return fmt.Errorf("could not decode name entry %s: %w", key, err)
+		}
+		return fn(entry)
+	})
+}
+
+// IterateExpiredNames visits the names whose leases have run out by height
+func (r *Reader) IterateExpiredNames(height uint64, fn func(entry *names.Entry) error) error {
+	var expired []string
+	err := nameExpiryPrefix.IterateRange(r.kv, nil, heightKey(height+1), func(key, value []byte) error {
+		expired = append(expired, string(key[8:]))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, name := range expired {
+		entry, err := r.GetName(name)
+		if err != nil {
+			return err
+		}
+		if entry != nil {
+			if err := fn(entry); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Cache) UpdateName(entry *names.Entry) error {
+	existing, err := c.GetName(entry.Name)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		if err := c.Delete(nameExpiryKey(existing)); err != nil {
+			return err
+		}
+	}
+	bs, err := entry.Encode()
+	if err != nil {
+		return fmt.Errorf("could not encode name entry %s: %w", entry.Name, err)
+	}
+	if err := c.Set(NameKey(entry.Name), bs); err != nil {
+		return err
+	}
+	return c.Set(nameExpiryKey(entry), []byte{})
+}
+
+func (c *Cache) RemoveName(name string) error {
+	existing, err := c.GetName(name)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return nil
+	}
+	if err := c.Delete(nameExpiryKey(existing)); err != nil {
+		return err
+	}
+	return c.Delete(NameKey(name))
+}
+
+func nameExpiryKey(entry *names.Entry) []byte {
+	return nameExpiryPrefix.Key(append(heightKey(entry.Expires), entry.Name...))
+}
+
+// heightKey encodes height so keys sort by height
+func heightKey(height uint64) []byte {
+	bs := make([]byte, 8)
+	binary.BigEndian.PutUint64(bs, height)
+	return bs
+}
@@ -0,0 +1,57 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/sunvim/yaoguang/permission"
+)
+
+var globalPermissionsKey = []byte("perm/global")
+
+// GetGlobalPermissions returns the permissions that apply to accounts without their own, the defaults if none have
+// been set
+func (r *Reader) GetGlobalPermissions() (permission.BasePermissions, error) {
+	bs, err := r.kv.Get(globalPermissionsKey)
+	if err != nil {
+		return permission.BasePermissions{}, err
+	}
+	if bs == nil {
+		return permission.DefaultBasePermissions, nil
+	}
+	var global permission.BasePermissions
+	if err := json.Unmarshal(bs, &global); err != nil {
+		return permission.BasePermissions{}, fmt.Errorf("could not decode global permissions: %w", err)
+	}
+	return global, nil
+}
+
+// HasGlobalPermissions reports whether global permissions have been set rather than left at the defaults
+func (r *Reader) HasGlobalPermissions() (bool, error) {
+	return r.kv.Has(globalPermissionsKey)
+}
+
+func (c *Cache) SetGlobalPermissions(global permission.BasePermissions) error {
+	bs, err := json.Marshal(global)
+	if err != nil {
+		return fmt.Errorf("could not encode global permissions: %w", err)
+	}
+	return c.Set(globalPermissionsKey, bs)
+}
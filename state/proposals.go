@@ -0,0 +1,110 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package state
+
+import (
+	"fmt"
+
+	"github.com/sunvim/yaoguang/binary"
+	"github.com/sunvim/yaoguang/storage"
+	"github.com/sunvim/yaoguang/txs/payload"
+)
+
+var (
+	ballotPrefix = storage.NewPrefix("g/")
+	// Index of pending ballots by expiry height so the tally at the end of each block skips finished proposals
+	pendingBallotPrefix = storage.NewPrefix("gx/")
+)
+
+// GetBallot returns nil if no proposal with proposalHash has been submitted
+func (r *Reader) GetBallot(proposalHash []byte) (*payload.Ballot, error) {
+	bs, err := r.kv.Get(ballotPrefix.Key(proposalHash))
+	if err != nil {
+		return nil, err
+	}
+	if bs == nil {
+		return nil, nil
+	}
+	ballot, err := payload.DecodeBallot(bs)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode ballot %X: %w", proposalHash, err)
+	}
+	return ballot, nil
+}
+
+// IterateBallots in proposal hash order
+func (r *Reader) IterateBallots(fn func(proposalHash binary.HexBytes, ballot *payload.Ballot) error) error {
+	return ballotPrefix.Iterate(r.kv, func(key, value []byte) error {
+		ballot, err := payload.DecodeBallot(value)
+		if err != nil {
+			return fmt.Errorf("could not decode ballot %X: %w", key, err)
+		}
+		return fn(key, ballot)
+	})
+}
+
+// IteratePendingBallots visits the ballots still open for votes in order of expiry
+func (r *Reader) IteratePendingBallots(fn func(proposalHash binary.HexBytes, ballot *payload.Ballot) error) error {
+	var pending []binary.HexBytes
+	err := pendingBallotPrefix.Iterate(r.kv, func(key, value []byte) error {
+		pending = append(pending, key[8:])
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, proposalHash := range pending {
+		ballot, err := r.GetBallot(proposalHash)
+		if err != nil {
+			return err
+		}
+		if ballot != nil {
+			if err := fn(proposalHash, ballot); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Cache) UpdateBallot(proposalHash []byte, ballot *payload.Ballot) error {
+	existing, err := c.GetBallot(proposalHash)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.State == payload.ProposalStatePending {
+		if err := c.Delete(pendingBallotKey(proposalHash, existing)); err != nil {
+			return err
+		}
+	}
+	bs, err := ballot.Encode()
+	if err != nil {
+		return fmt.Errorf("could not encode ballot %X: %w", proposalHash, err)
+	}
+	if err := c.Set(ballotPrefix.Key(proposalHash), bs); err != nil {
+		return err
+	}
+	if ballot.State == payload.ProposalStatePending {
+		return c.Set(pendingBallotKey(proposalHash, ballot), []byte{})
+	}
+	return nil
+}
+
+func pendingBallotKey(proposalHash []byte, ballot *payload.Ballot) []byte {
+	return pendingBallotPrefix.Key(append(heightKey(ballot.Expires), proposalHash...))
+}
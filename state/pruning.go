@@ -0,0 +1,151 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package state
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// Pruning strategies, which decide the versions of the state kept on disk
+const (
+	// PruneNothing keeps every version
+	PruneNothing = "nothing"
+	// PruneEverything keeps only the latest version
+	PruneEverything = "everything"
+	// PruneCustom keeps the latest version, the KeepRecent versions before it and every KeepEvery-th version
+	PruneCustom = "custom"
+)
+
+type PruningOptions struct {
+	Strategy   string
+	KeepRecent uint64
+	KeepEvery  uint64
+}
+
+// NewPruningOptions validates strategy, an empty strategy prunes nothing. keepRecent and keepEvery only apply to
+// PruneCustom.
+func NewPruningOptions(strategy string, keepRecent, keepEvery uint64) (PruningOptions, error) {
+	switch strategy {
+	case "", PruneNothing:
+		return PruningOptions{Strategy: PruneNothing}, nil
+	case PruneEverything:
+		return PruningOptions{Strategy: PruneEverything}, nil
+	case PruneCustom:
+		return PruningOptions{Strategy: PruneCustom, KeepRecent: keepRecent, KeepEvery: keepEvery}, nil
+	}
+	return PruningOptions{}, errors.Errorf("unknown pruning strategy %q, expected %s, %s or %s", strategy,
+		PruneNothing, PruneEverything, PruneCustom)
+}
+
+// Retain reports whether version is kept once latest has been committed
+func (po PruningOptions) Retain(version, latest int64) bool {
+	switch {
+	case version >= latest || po.Strategy == PruneNothing:
+		return true
+	case po.Strategy == PruneEverything:
+		return false
+	}
+	return uint64(latest-version) <= po.KeepRecent || (po.KeepEvery > 0 && uint64(version)%po.KeepEvery == 0)
+}
+
+func (po PruningOptions) String() string {
+	if po.Strategy != PruneCustom {
+		return po.Strategy
+	}
+	return fmt.Sprintf("%s(keep-recent=%d, keep-every=%d)", po.Strategy, po.KeepRecent, po.KeepEvery)
+}
+
+// Pruner deletes the versions of a state its options do not retain in the background so that Commit does not wait
+// for it
+type Pruner struct {
+	state   *State
+	options PruningOptions
+	metrics *Metrics
+	pending chan struct{}
+	started bool
+	stop    sync.Once
+	quit    chan struct{}
+	stopped chan struct{}
+}
+
+func NewPruner(st *State, options PruningOptions, metrics *Metrics) *Pruner {
+	return &Pruner{
+		state:   st,
+		options: options,
+		metrics: metrics,
+		pending: make(chan struct{}, 1),
+		quit:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+}
+
+func (p *Pruner) Start() {
+	p.started = true
+	go p.run()
+}
+
+// Prune asks for the state to be pruned after a commit and returns immediately. Requests made while a prune is in
+// progress are served by a single prune after it.
+func (p *Pruner) Prune() {
+	select {
+	case p.pending <- struct{}{}:
+	default:
+	}
+}
+
+// Stop waits for a prune in progress to finish and stops the pruner, it may be called more than once
+func (p *Pruner) Stop() {
+	p.stop.Do(func() {
+		close(p.quit)
+	})
+	if p.started {
+		<-p.stopped
+	}
+}
+
+func (p *Pruner) run() {
+	defer close(p.stopped)
+	for {
+		select {
+		case <-p.quit:
+			return
+		case <-p.pending:
+			p.prune()
+		}
+	}
+}
+
+func (p *Pruner) prune() {
+	start := time.Now()
+	pruned, retained, err := p.state.Prune(p.options.Retain)
+	if err != nil {
+		log.Error().Err(err).Str("pruning", p.options.String()).Msg("could not prune state")
+		return
+	}
+	p.metrics.PrunedVersions.Add(float64(pruned))
+	p.metrics.RetainedVersions.Set(float64(retained))
+	p.metrics.PruneTime.Observe(time.Since(start).Seconds())
+	if pruned > 0 {
+		log.Debug().Int("pruned", pruned).Int("retained", retained).Msg("prune state")
+	}
+}
@@ -0,0 +1,130 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tm-db"
+)
+
+func TestPruningOptions_Retain(t *testing.T) {
+	retained := func(options PruningOptions, latest int64) []int64 {
+		var versions []int64
+		for version := int64(1); version <= latest; version++ {
+			if options.Retain(version, latest) {
+				versions = append(versions, version)
+			}
+		}
+		return versions
+	}
+	nothing, err := NewPruningOptions("", 5, 5)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, retained(nothing, 10))
+
+	everything, err := NewPruningOptions(PruneEverything, 5, 5)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{10}, retained(everything, 10))
+
+	custom, err := NewPruningOptions(PruneCustom, 2, 4)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{4, 8, 9, 10, 11}, retained(custom, 11))
+
+	_, err = NewPruningOptions("some", 0, 0)
+	require.Error(t, err)
+}
+
+func TestState_Prune(t *testing.T) {
+	st, err := NewState(dbm.NewMemDB())
+	require.NoError(t, err)
+	var hashes [][]byte
+	for i := 0; i < 6; i++ {
+		cache := st.Cache()
+		require.NoError(t, cache.Set([]byte("a"), []byte{byte(i)}))
+		hash, _, err := st.Commit(cache)
+		require.NoError(t, err)
+		hashes = append(hashes, hash)
+	}
+	options, err := NewPruningOptions(PruneCustom, 1, 3)
+	require.NoError(t, err)
+
+	release, err := st.Hold(2)
+	require.NoError(t, err)
+	pruned, retained, err := st.Prune(options.Retain)
+	require.NoError(t, err)
+	assert.Equal(t, 2, pruned)
+	assert.Equal(t, 4, retained)
+	for version, exists := range map[int64]bool{1: false, 2: true, 3: true, 4: false, 5: true, 6: true} {
+		assert.Equal(t, exists, st.VersionExists(version), "version %d", version)
+	}
+	_, err = st.Hold(1)
+	require.Error(t, err)
+
+	// A held version is pruned once released
+	release()
+	release()
+	pruned, _, err = st.Prune(options.Retain)
+	require.NoError(t, err)
+	assert.Equal(t, 1, pruned)
+	assert.False(t, st.VersionExists(2))
+	hash, err := st.HashAt(5)
+	require.NoError(t, err)
+	assert.Equal(t, hashes[4], hash)
+	assert.Equal(t, hashes[5], st.Hash())
+}
+
+func TestState_PruneBatches(t *testing.T) {
+	st, err := NewState(dbm.NewMemDB())
+	require.NoError(t, err)
+	for i := 0; i < 3*pruneBatchSize; i++ {
+		cache := st.Cache()
+		require.NoError(t, cache.Set([]byte("a"), []byte{byte(i)}))
+		_, _, err := st.Commit(cache)
+		require.NoError(t, err)
+	}
+	options, err := NewPruningOptions(PruneEverything, 0, 0)
+	require.NoError(t, err)
+
+	// A version held after the prune has chosen it is still kept
+	var release func()
+	held := int64(2*pruneBatchSize + 1)
+	pruned, retained, err := st.Prune(func(version, latest int64) bool {
+		if release == nil {
+			release, err = st.Hold(held)
+			require.NoError(t, err)
+		}
+		return options.Retain(version, latest)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3*pruneBatchSize-2, pruned)
+	assert.Equal(t, 2, retained)
+	assert.True(t, st.VersionExists(held))
+	release()
+}
+
+func TestPruner(t *testing.T) {
+	st, err := NewState(dbm.NewMemDB())
+	require.NoError(t, err)
+	options, err := NewPruningOptions(PruneEverything, 0, 0)
+	require.NoError(t, err)
+	pruner := NewPruner(st, options, NopMetrics())
+	pruner.Start()
+	// Stopping twice, as a shutdown that is retried does, is harmless
+	defer pruner.Stop()
+	defer pruner.Stop()
+	for i := 0; i < 10; i++ {
+		cache := st.Cache()
+		require.NoError(t, cache.Set([]byte("a"), []byte{byte(i)}))
+		_, _, err := st.Commit(cache)
+		require.NoError(t, err)
+		pruner.Prune()
+	}
+	require.Eventually(t, func() bool {
+		return !st.VersionExists(9)
+	}, time.Second, time.Millisecond)
+	for version := int64(1); version < 10; version++ {
+		assert.False(t, st.VersionExists(version), "version %d", version)
+	}
+	assert.True(t, st.VersionExists(10))
+}
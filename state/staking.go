@@ -0,0 +1,126 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package state
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"github.com/sunvim/yaoguang/crypto"
+	"github.com/sunvim/yaoguang/storage"
+	"github.com/sunvim/yaoguang/validators"
+)
+
+var (
+	// Delegations keyed by validator then delegator
+	delegationPrefix = storage.NewPrefix("d/")
+	// Unbondings keyed by release height then delegator and validator
+	unbondingPrefix = storage.NewPrefix("u/")
+)
+
+// GetDelegation returns the amount delegator has bonded to validator
+func (r *Reader) GetDelegation(validator, delegator crypto.Address) (uint64, error) {
+	bs, err := r.kv.Get(delegationKey(validator, delegator))
+	if err != nil {
+		return 0, err
+	}
+	if len(bs) == 0 {
+		return 0, nil
+	}
+	return binary.BigEndian.Uint64(bs), nil
+}
+
+// IterateDelegations in validator then delegator order
+func (r *Reader) IterateDelegations(fn func(validator, delegator crypto.Address, amount uint64) error) error {
+	return delegationPrefix.Iterate(r.kv, func(key, value []byte) error {
+		if len(key) != 2*crypto.AddressLength {
+			return fmt.Errorf("malformed delegation key %X", key)
+		}
+		return fn(crypto.MustAddressFromBytes(key[:crypto.AddressLength]),
+			crypto.MustAddressFromBytes(key[crypto.AddressLength:]), binary.BigEndian.Uint64(value))
+	})
+}
+
+// SetDelegation records the amount delegator has bonded to validator, removing it at zero
+func (c *Cache) SetDelegation(validator, delegator crypto.Address, amount uint64) error {
+	key := delegationKey(validator, delegator)
+	if amount == 0 {
+		return c.Delete(key)
+	}
+	bs := make([]byte, 8)
+	binary.BigEndian.PutUint64(bs, amount)
+	return c.Set(key, bs)
+}
+
+// IterateUnbondings visits the unbondings released by height in release order
+func (r *Reader) IterateUnbondings(height uint64, fn func(unbonding *validators.Unbonding) error) error {
+	return unbondingPrefix.IterateRange(r.kv, nil, heightKey(height+1), func(key, value []byte) error {
+		unbonding := new(validators.Unbonding)
+		if err := json.Unmarshal(value, unbonding); err != nil {
+			return fmt.Errorf("could not decode unbonding %X: %w", key, err)
+		}
+		return fn(unbonding)
+	})
+}
+
+// IterateAllUnbondings visits every queued unbonding in release order
+func (r *Reader) IterateAllUnbondings(fn func(unbonding *validators.Unbonding) error) error {
+	return unbondingPrefix.Iterate(r.kv, func(key, value []byte) error {
+		unbonding := new(validators.Unbonding)
+		if err := json.Unmarshal(value, unbonding); err != nil {
+			return fmt.Errorf("could not decode unbonding %X: %w", key, err)
+		}
+		return fn(unbonding)
+	})
+}
+
+// AddUnbonding queues an unbonding, merging it with any for the same delegation released at the same height
+func (c *Cache) AddUnbonding(unbonding *validators.Unbonding) error {
+	key := unbondingKey(unbonding)
+	bs, err := c.Get(key)
+	if err != nil {
+		return err
+	}
+	queued := *unbonding
+	if bs != nil {
+		existing := new(validators.Unbonding)
+		if err := json.Unmarshal(bs, existing); err != nil {
+			return fmt.Errorf("could not decode unbonding %X: %w", key, err)
+		}
+		queued.Amount += existing.Amount
+	}
+	bs, err = json.Marshal(queued)
+	if err != nil {
+		return fmt.Errorf("could not encode unbonding: %w", err)
+	}
+	return c.Set(key, bs)
+}
+
+func (c *Cache) RemoveUnbonding(unbonding *validators.Unbonding) error {
+	return c.Delete(unbondingKey(unbonding))
+}
+
+func delegationKey(validator, delegator crypto.Address) []byte {
+	return delegationPrefix.Key(append(validator.Bytes(), delegator.Bytes()...))
+}
+
+func unbondingKey(unbonding *validators.Unbonding) []byte {
+	key := append(heightKey(unbonding.Release), unbonding.Delegator.Bytes()...)
+	return unbondingPrefix.Key(append(key, unbonding.Validator.Bytes()...))
+}
@@ -0,0 +1,285 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package state
+
+import (
+	"sync"
+
+	ics23 "github.com/confio/ics23/go"
+	"github.com/pkg/errors"
+	"github.com/sunvim/yaoguang/storage"
+	dbm "github.com/tendermint/tm-db"
+)
+
+const (
+	// number of tree nodes IAVL keeps in memory
+	treeCacheSize = 10000
+	// number of versions Prune deletes while holding the lock
+	pruneBatchSize = 10
+)
+
+var (
+	treePrefix = []byte("tree/")
+)
+
+// State is the committed application state: a versioned merkle tree whose version tracks block height
+type State struct {
+	sync.RWMutex
+	db   dbm.DB
+	tree *storage.RWTree
+	// number of readers of each historical version that pruning must keep
+	holdsLock sync.Mutex
+	holds     map[int64]int
+}
+
+// NewState opens the state tree in db at its latest version (an empty tree for a fresh db)
+func NewState(db dbm.DB) (*State, error) {
+	return LoadState(db, 0)
+}
+
+// NewInitialState opens the empty state tree in db for a chain whose first block is at initialVersion
+func NewInitialState(db dbm.DB, initialVersion uint64) (*State, error) {
+	st, err := NewState(db)
+	if err != nil {
+		return nil, err
+	}
+	if version := st.tree.Version(); version != 0 {
+		return nil, errors.Errorf("state tree already has version %d so cannot start at version %d", version,
+			initialVersion)
+	}
+	if initialVersion > 1 {
+		st.tree.SetInitialVersion(initialVersion)
+	}
+	return st, nil
+}
+
+// CopyState copies version of src into the empty db, the copy has the same hash as the original
+func CopyState(db dbm.DB, src *State, version int64) (*State, error) {
+	st, err := NewState(db)
+	if err != nil {
+		return nil, err
+	}
+	if current := st.tree.Version(); current != 0 {
+		return nil, errors.Errorf("state tree already has version %d so cannot copy version %d into it", current,
+			version)
+	}
+	src.RLock()
+	tree, err := src.tree.GetImmutable(version)
+	src.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	if err := st.tree.Restore(tree); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// LoadState opens the state tree in db at version, or the latest version if version is 0
+func LoadState(db dbm.DB, version int64) (*State, error) {
+	tree, err := storage.NewRWTree(dbm.NewPrefixDB(db, treePrefix), treeCacheSize)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create state tree")
+	}
+	if err := tree.Load(version); err != nil {
+		return nil, errors.Wrapf(err, "could not load state tree at version %d", version)
+	}
+	return &State{
+		db:    db,
+		tree:  tree,
+		holds: make(map[int64]int),
+	}, nil
+}
+
+// Rollback reverts the state to version, deleting all later versions
+func (s *State) Rollback(version int64) error {
+	s.Lock()
+	defer s.Unlock()
+	return s.tree.Rollback(version)
+}
+
+// VersionExists reports whether version is retained in the state tree
+func (s *State) VersionExists(version int64) bool {
+	s.RLock()
+	defer s.RUnlock()
+	return s.tree.VersionExists(version)
+}
+
+// Version is the last committed version of the state
+func (s *State) Version() int64 {
+	s.RLock()
+	defer s.RUnlock()
+	return s.tree.Version()
+}
+
+// Hash is the root hash of the last committed version of the state
+func (s *State) Hash() []byte {
+	s.RLock()
+	defer s.RUnlock()
+	return s.tree.Hash()
+}
+
+// HashAt is the root hash of a previously committed version
+func (s *State) HashAt(version int64) ([]byte, error) {
+	s.RLock()
+	defer s.RUnlock()
+	tree, err := s.tree.GetImmutable(version)
+	if err != nil {
+		return nil, err
+	}
+	return tree.Hash(), nil
+}
+
+// Hold keeps version from being pruned until release is called, so that a reader of a historical version such as a
+// query or a snapshot does not have it deleted from under it
+func (s *State) Hold(version int64) (release func(), err error) {
+	s.RLock()
+	defer s.RUnlock()
+	if !s.tree.VersionExists(version) {
+		return nil, errors.Errorf("state at height %d is not retained", version)
+	}
+	s.holdsLock.Lock()
+	s.holds[version]++
+	s.holdsLock.Unlock()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.holdsLock.Lock()
+			defer s.holdsLock.Unlock()
+			s.holds[version]--
+			if s.holds[version] == 0 {
+				delete(s.holds, version)
+			}
+		})
+	}, nil
+}
+
+// Prune deletes the versions before the latest that retain does not keep and that are not held, returning the
+// number of versions deleted and retained. Versions are deleted a few at a time so a Commit, which writes to the same
+// tree, only waits for the batch in progress.
+func (s *State) Prune(retain func(version, latest int64) bool) (pruned, retained int, err error) {
+	s.RLock()
+	latest := s.tree.Version()
+	available := s.tree.AvailableVersions()
+	s.RUnlock()
+	var versions []int64
+	for _, version := range available {
+		if version < latest && !retain(version, latest) {
+			versions = append(versions, version)
+		}
+	}
+	for len(versions) > 0 {
+		batch := versions
+		if len(batch) > pruneBatchSize {
+			batch = batch[:pruneBatchSize]
+		}
+		versions = versions[len(batch):]
+		deleted, err := s.deleteVersions(batch)
+		pruned += deleted
+		if err != nil {
+			return pruned, len(available) - pruned, err
+		}
+	}
+	return pruned, len(available) - pruned, nil
+}
+
+// deleteVersions deletes those of versions that are not held, a version may have been held since Prune chose it
+func (s *State) deleteVersions(versions []int64) (int, error) {
+	s.Lock()
+	defer s.Unlock()
+	s.holdsLock.Lock()
+	defer s.holdsLock.Unlock()
+	var unheld []int64
+	for _, version := range versions {
+		if s.holds[version] == 0 {
+			unheld = append(unheld, version)
+		}
+	}
+	if err := s.tree.DeleteVersions(unheld...); err != nil {
+		return 0, err
+	}
+	return len(unheld), nil
+}
+
+// Cache returns a write buffer over the latest committed state, changes are only persisted by Commit
+func (s *State) Cache() *Cache {
+	return NewCache(s)
+}
+
+// Reader returns a read-only view of a previously committed version
+func (s *State) Reader(version int64) (*Reader, error) {
+	s.RLock()
+	defer s.RUnlock()
+	tree, err := s.tree.GetImmutable(version)
+	if err != nil {
+		return nil, err
+	}
+	return NewReader(tree), nil
+}
+
+// Prove returns the value of key at a previously committed version with a proof against that version's hash
+func (s *State) Prove(version int64, key []byte) ([]byte, *ics23.CommitmentProof, error) {
+	s.RLock()
+	defer s.RUnlock()
+	tree, err := s.tree.GetImmutable(version)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tree.GetWithProof(key)
+}
+
+// Commit writes cache to the state tree and saves it as a new version
+func (s *State) Commit(cache *Cache) (hash []byte, version int64, err error) {
+	s.Lock()
+	defer s.Unlock()
+	err = cache.Write(s.tree)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "could not write state cache to tree")
+	}
+	hash, version, err = s.tree.Save()
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "could not save state tree")
+	}
+	return hash, version, nil
+}
+
+// KV access to the working tree, which is always the last committed version outside of Commit
+
+func (s *State) Get(key []byte) ([]byte, error) {
+	s.RLock()
+	defer s.RUnlock()
+	return s.tree.Get(key)
+}
+
+func (s *State) Has(key []byte) (bool, error) {
+	s.RLock()
+	defer s.RUnlock()
+	return s.tree.Has(key)
+}
+
+func (s *State) Iterator(start, end []byte) (storage.KVIterator, error) {
+	s.RLock()
+	defer s.RUnlock()
+	return s.tree.Iterator(start, end)
+}
+
+func (s *State) ReverseIterator(start, end []byte) (storage.KVIterator, error) {
+	s.RLock()
+	defer s.RUnlock()
+	return s.tree.ReverseIterator(start, end)
+}
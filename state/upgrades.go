@@ -0,0 +1,77 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package state
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"github.com/sunvim/yaoguang/txs/payload"
+)
+
+var (
+	appVersionKey  = []byte("upgrade/version")
+	upgradePlanKey = []byte("upgrade/plan")
+)
+
+// GetAppVersion returns the version of the application protocol the chain runs, 0 if it has never been set
+func (r *Reader) GetAppVersion() (uint64, error) {
+	bs, err := r.kv.Get(appVersionKey)
+	if err != nil {
+		return 0, err
+	}
+	if len(bs) == 0 {
+		return 0, nil
+	}
+	return binary.BigEndian.Uint64(bs), nil
+}
+
+func (c *Cache) SetAppVersion(appVersion uint64) error {
+	bs := make([]byte, 8)
+	binary.BigEndian.PutUint64(bs, appVersion)
+	return c.Set(appVersionKey, bs)
+}
+
+// GetUpgradePlan returns the scheduled upgrade, nil if there is none
+func (r *Reader) GetUpgradePlan() (*payload.UpgradePlan, error) {
+	bs, err := r.kv.Get(upgradePlanKey)
+	if err != nil {
+		return nil, err
+	}
+	if bs == nil {
+		return nil, nil
+	}
+	plan := new(payload.UpgradePlan)
+	if err := json.Unmarshal(bs, plan); err != nil {
+		return nil, fmt.Errorf("could not decode upgrade plan: %w", err)
+	}
+	return plan, nil
+}
+
+// SetUpgradePlan schedules plan replacing any upgrade already scheduled, a nil plan cancels it
+func (c *Cache) SetUpgradePlan(plan *payload.UpgradePlan) error {
+	if plan == nil {
+		return c.Delete(upgradePlanKey)
+	}
+	bs, err := json.Marshal(plan)
+	if err != nil {
+		return fmt.Errorf("could not encode upgrade plan: %w", err)
+	}
+	return c.Set(upgradePlanKey, bs)
+}
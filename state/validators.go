@@ -0,0 +1,116 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/sunvim/yaoguang/crypto"
+	"github.com/sunvim/yaoguang/storage"
+	"github.com/sunvim/yaoguang/validators"
+)
+
+var validatorPrefix = storage.NewPrefix("v/")
+
+type validator struct {
+	PublicKey *crypto.PublicKey `json:"public_key"`
+	Power     *big.Int          `json:"power"`
+}
+
+var _ validators.IterableReader = (*Reader)(nil)
+var _ validators.IterableReaderWriter = (*Cache)(nil)
+
+// Power returns the voting power of a validator, zero if address is not a validator
+func (r *Reader) Power(address crypto.Address) (*big.Int, error) {
+	v, err := r.getValidator(address)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return new(big.Int), nil
+	}
+	return v.Power, nil
+}
+
+// GetValidator returns the public key and power of a validator, or a nil key if address is not a validator
+func (r *Reader) GetValidator(address crypto.Address) (*crypto.PublicKey, *big.Int, error) {
+	v, err := r.getValidator(address)
+	if err != nil {
+		return nil, nil, err
+	}
+	if v == nil {
+		return nil, new(big.Int), nil
+	}
+	return v.PublicKey, v.Power, nil
+}
+
+// IterateValidators in address order
+func (r *Reader) IterateValidators(fn func(id crypto.Addressable, power *big.Int) error) error {
+	return validatorPrefix.Iterate(r.kv, func(key, value []byte) error {
+		v := new(validator)
+		if err := json.Unmarshal(value, v); err != nil {
+			return fmt.Errorf("could not decode validator %X: %w", key, err)
+		}
+		return fn(crypto.NewAddressable(v.PublicKey), v.Power)
+	})
+}
+
+func (r *Reader) getValidator(address crypto.Address) (*validator, error) {
+	bs, err := r.kv.Get(validatorPrefix.Key(address.Bytes()))
+	if err != nil {
+		return nil, err
+	}
+	if bs == nil {
+		return nil, nil
+	}
+	v := new(validator)
+	if err := json.Unmarshal(bs, v); err != nil {
+		return nil, fmt.Errorf("could not decode validator %v: %w", address, err)
+	}
+	return v, nil
+}
+
+// SetPower sets the voting power of a validator, removing it at zero power, and returns the change in power
+func (c *Cache) SetPower(id *crypto.PublicKey, power *big.Int) (*big.Int, error) {
+	if id == nil || !id.IsSet() {
+		return nil, fmt.Errorf("SetPower passed nil public key")
+	}
+	if power == nil {
+		power = new(big.Int)
+	}
+	if power.Sign() < 0 {
+		return nil, fmt.Errorf("cannot set negative power %v for validator %v", power, id.GetAddress())
+	}
+	address := id.GetAddress()
+	current, err := c.Power(address)
+	if err != nil {
+		return nil, err
+	}
+	flow := new(big.Int).Sub(power, current)
+	key := validatorPrefix.Key(address.Bytes())
+	if power.Sign() == 0 {
+		return flow, c.Delete(key)
+	}
+	bs, err := json.Marshal(validator{PublicKey: id, Power: power})
+	if err != nil {
+		return nil, fmt.Errorf("could not encode validator %v: %w", address, err)
+	}
+	return flow, c.Set(key, bs)
+}
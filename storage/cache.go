@@ -0,0 +1,254 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package storage
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+)
+
+// KVCache buffers writes in memory over a backend store. Reads fall through to the backend for keys that have not
+// been touched. Nothing reaches the backend until Write is called, so a cache can be thrown away to discard a batch
+// of changes.
+type KVCache struct {
+	sync.RWMutex
+	backend KVReaderIterable
+	cache   map[string]valueInfo
+}
+
+type valueInfo struct {
+	value   []byte
+	deleted bool
+}
+
+var _ KVStore = (*KVCache)(nil)
+
+func NewKVCache(backend KVReaderIterable) *KVCache {
+	return &KVCache{
+		backend: backend,
+		cache:   make(map[string]valueInfo),
+	}
+}
+
+func (kvc *KVCache) Get(key []byte) ([]byte, error) {
+	kvc.RLock()
+	info, ok := kvc.cache[string(key)]
+	kvc.RUnlock()
+	if ok {
+		if info.deleted {
+			return nil, nil
+		}
+		return info.value, nil
+	}
+	return kvc.backend.Get(key)
+}
+
+func (kvc *KVCache) Has(key []byte) (bool, error) {
+	value, err := kvc.Get(key)
+	if err != nil {
+		return false, err
+	}
+	return value != nil, nil
+}
+
+func (kvc *KVCache) Set(key, value []byte) error {
+	kvc.Lock()
+	defer kvc.Unlock()
+	kvc.cache[string(key)] = valueInfo{value: value}
+	return nil
+}
+
+func (kvc *KVCache) Delete(key []byte) error {
+	kvc.Lock()
+	defer kvc.Unlock()
+	kvc.cache[string(key)] = valueInfo{deleted: true}
+	return nil
+}
+
+func (kvc *KVCache) Iterator(start, end []byte) (KVIterator, error) {
+	return kvc.iterator(start, end, true)
+}
+
+func (kvc *KVCache) ReverseIterator(start, end []byte) (KVIterator, error) {
+	return kvc.iterator(start, end, false)
+}
+
+// Write flushes all buffered changes to w in key order
+func (kvc *KVCache) Write(w KVWriter) error {
+	kvc.RLock()
+	defer kvc.RUnlock()
+	for _, kv := range kvc.sorted(nil, nil, true) {
+		var err error
+		if kv.info.deleted {
+			err = w.Delete(kv.key)
+		} else {
+			err = w.Set(kv.key, kv.info.value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reset discards all buffered changes
+func (kvc *KVCache) Reset() {
+	kvc.Lock()
+	defer kvc.Unlock()
+	kvc.cache = make(map[string]valueInfo)
+}
+
+func (kvc *KVCache) iterator(start, end []byte, ascending bool) (KVIterator, error) {
+	var backend KVIterator
+	var err error
+	if ascending {
+		backend, err = kvc.backend.Iterator(start, end)
+	} else {
+		backend, err = kvc.backend.ReverseIterator(start, end)
+	}
+	if err != nil {
+		return nil, err
+	}
+	kvc.RLock()
+	defer kvc.RUnlock()
+	return newMergeIterator(start, end, kvc.sorted(start, end, ascending), backend, ascending), nil
+}
+
+// Must be called with the lock held
+func (kvc *KVCache) sorted(start, end []byte, ascending bool) []kvPair {
+	kvs := make([]kvPair, 0, len(kvc.cache))
+	for k, info := range kvc.cache {
+		key := []byte(k)
+		if start != nil && bytes.Compare(key, start) < 0 {
+			continue
+		}
+		if end != nil && bytes.Compare(key, end) >= 0 {
+			continue
+		}
+		kvs = append(kvs, kvPair{key: key, info: info})
+	}
+	sort.Slice(kvs, func(i, j int) bool {
+		if ascending {
+			return bytes.Compare(kvs[i].key, kvs[j].key) < 0
+		}
+		return bytes.Compare(kvs[i].key, kvs[j].key) > 0
+	})
+	return kvs
+}
+
+type kvPair struct {
+	key  []byte
+	info valueInfo
+}
+
+// mergeIterator overlays a sorted snapshot of cache entries on a backend iterator, preferring the cache and hiding
+// deleted keys
+type mergeIterator struct {
+	start, end []byte
+	cache      []kvPair
+	backend    KVIterator
+	ascending  bool
+	key, value []byte
+	valid      bool
+}
+
+func newMergeIterator(start, end []byte, cache []kvPair, backend KVIterator, ascending bool) *mergeIterator {
+	mi := &mergeIterator{
+		start:     start,
+		end:       end,
+		cache:     cache,
+		backend:   backend,
+		ascending: ascending,
+		valid:     true,
+	}
+	mi.Next()
+	return mi
+}
+
+func (mi *mergeIterator) Domain() (start, end []byte) {
+	return mi.start, mi.end
+}
+
+func (mi *mergeIterator) Valid() bool {
+	return mi.valid
+}
+
+func (mi *mergeIterator) Next() {
+	for {
+		cacheValid := len(mi.cache) > 0
+		backendValid := mi.backend.Valid()
+		if !cacheValid && !backendValid {
+			mi.valid = false
+			return
+		}
+		takeCache := cacheValid
+		if cacheValid && backendValid {
+			cmp := bytes.Compare(mi.cache[0].key, mi.backend.Key())
+			if !mi.ascending {
+				cmp = -cmp
+			}
+			switch {
+			case cmp == 0:
+				// cache shadows backend
+				mi.backend.Next()
+			case cmp > 0:
+				takeCache = false
+			}
+		}
+		if takeCache {
+			kv := mi.cache[0]
+			mi.cache = mi.cache[1:]
+			if kv.info.deleted {
+				continue
+			}
+			mi.key, mi.value = kv.key, kv.info.value
+			return
+		}
+		mi.key = copyBytes(mi.backend.Key())
+		mi.value = copyBytes(mi.backend.Value())
+		mi.backend.Next()
+		return
+	}
+}
+
+func (mi *mergeIterator) Key() []byte {
+	return mi.key
+}
+
+func (mi *mergeIterator) Value() []byte {
+	return mi.value
+}
+
+func (mi *mergeIterator) Error() error {
+	return mi.backend.Error()
+}
+
+func (mi *mergeIterator) Close() error {
+	mi.valid = false
+	return mi.backend.Close()
+}
+
+func copyBytes(bs []byte) []byte {
+	if bs == nil {
+		return nil
+	}
+	cp := make([]byte, len(bs))
+	copy(cp, bs)
+	return cp
+}
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tm-db"
+)
+
+func TestKVCache_Iterator(t *testing.T) {
+	tree, err := NewRWTree(dbm.NewMemDB(), 100)
+	require.NoError(t, err)
+	for _, k := range []string{"a", "c", "e", "g"} {
+		require.NoError(t, tree.Set([]byte(k), []byte("tree-"+k)))
+	}
+	_, _, err = tree.Save()
+	require.NoError(t, err)
+
+	cache := NewKVCache(tree)
+	require.NoError(t, cache.Set([]byte("b"), []byte("cache-b")))
+	require.NoError(t, cache.Set([]byte("c"), []byte("cache-c")))
+	require.NoError(t, cache.Delete([]byte("e")))
+
+	assert.Equal(t, []string{"a=tree-a", "b=cache-b", "c=cache-c", "g=tree-g"}, collect(t, cache, true))
+	assert.Equal(t, []string{"g=tree-g", "c=cache-c", "b=cache-b", "a=tree-a"}, collect(t, cache, false))
+
+	// Nothing reaches the tree until written
+	value, err := tree.Get([]byte("c"))
+	require.NoError(t, err)
+	assert.Equal(t, "tree-c", string(value))
+
+	require.NoError(t, cache.Write(tree))
+	assert.Equal(t, []string{"a=tree-a", "b=cache-b", "c=cache-c", "g=tree-g"}, collect(t, tree, true))
+}
+
+func TestKVCache_Nested(t *testing.T) {
+	tree, err := NewRWTree(dbm.NewMemDB(), 100)
+	require.NoError(t, err)
+	outer := NewKVCache(tree)
+	require.NoError(t, outer.Set([]byte("a"), []byte("1")))
+	inner := NewKVCache(outer)
+	require.NoError(t, inner.Delete([]byte("a")))
+	require.NoError(t, inner.Set([]byte("b"), []byte("2")))
+
+	has, err := outer.Has([]byte("a"))
+	require.NoError(t, err)
+	assert.True(t, has)
+
+	inner.Reset()
+	assert.Equal(t, []string{"a=1"}, collect(t, inner, true))
+}
+
+func TestPrefix_Iterate(t *testing.T) {
+	cache := NewKVCache(NewKVCache(emptyStore{}))
+	p := NewPrefix("p/")
+	require.NoError(t, cache.Set([]byte("o/1"), []byte("x")))
+	require.NoError(t, cache.Set(p.Key([]byte("1")), []byte("y")))
+	require.NoError(t, cache.Set(p.Key([]byte("2")), []byte("z")))
+	require.NoError(t, cache.Set([]byte("q/1"), []byte("x")))
+
+	var keys []string
+	require.NoError(t, p.Iterate(cache, func(key, value []byte) error {
+		keys = append(keys, string(key)+"="+string(value))
+		return nil
+	}))
+	assert.Equal(t, []string{"1=y", "2=z"}, keys)
+}
+
+func collect(t *testing.T, it KVIterable, ascending bool) []string {
+	var iter KVIterator
+	var err error
+	if ascending {
+		iter, err = it.Iterator(nil, nil)
+	} else {
+		iter, err = it.ReverseIterator(nil, nil)
+	}
+	require.NoError(t, err)
+	defer iter.Close()
+	var kvs []string
+	for ; iter.Valid(); iter.Next() {
+		kvs = append(kvs, string(iter.Key())+"="+string(iter.Value()))
+	}
+	return kvs
+}
+
+type emptyStore struct{}
+
+func (emptyStore) Get(key []byte) ([]byte, error) { return nil, nil }
+func (emptyStore) Has(key []byte) (bool, error)   { return false, nil }
+func (emptyStore) Iterator(start, end []byte) (KVIterator, error) {
+	return emptyIterator{}, nil
+}
+func (emptyStore) ReverseIterator(start, end []byte) (KVIterator, error) {
+	return emptyIterator{}, nil
+}
@@ -0,0 +1,73 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package storage
+
+import (
+	dbm "github.com/tendermint/tm-db"
+)
+
+// KVIterator is the tm-db iterator, reused so every layer of the store can hand out the same type
+type KVIterator = dbm.Iterator
+
+type KVReader interface {
+	// Get returns nil iff key doesn't exist. Panics on nil key.
+	Get(key []byte) ([]byte, error)
+	// Has checks if a key exists. Panics on nil key.
+	Has(key []byte) (bool, error)
+}
+
+type KVWriter interface {
+	// Set sets the key, replacing any existing value
+	Set(key, value []byte) error
+	// Delete deletes the key, a no-op if the key does not exist
+	Delete(key []byte) error
+}
+
+type KVIterable interface {
+	// Iterator over a domain of keys in ascending order. End is exclusive.
+	// Start must be less than end, or the Iterator is invalid.
+	// A nil start iterates from the first key, a nil end iterates to the last key (inclusive).
+	Iterator(start, end []byte) (KVIterator, error)
+	// ReverseIterator over a domain of keys in descending order. End is exclusive.
+	ReverseIterator(start, end []byte) (KVIterator, error)
+}
+
+type KVReaderIterable interface {
+	KVReader
+	KVIterable
+}
+
+type KVStore interface {
+	KVReaderIterable
+	KVWriter
+}
+
+// Iterate calls fn for each key-value pair in [start, end) until fn returns an error
+func Iterate(it KVIterable, start, end []byte, fn func(key, value []byte) error) error {
+	iter, err := it.Iterator(start, end)
+	if err != nil {
+		return err
+	}
+	defer iter.Close()
+	for ; iter.Valid(); iter.Next() {
+		if err := fn(iter.Key(), iter.Value()); err != nil {
+			return err
+		}
+	}
+	return iter.Error()
+}
@@ -0,0 +1,70 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package storage
+
+// Prefix partitions a single key space into domains
+type Prefix []byte
+
+func NewPrefix(p string) Prefix {
+	return Prefix(p)
+}
+
+// Key returns the prefixed key
+func (p Prefix) Key(key []byte) []byte {
+	prefixed := make([]byte, len(p)+len(key))
+	copy(prefixed, p)
+	copy(prefixed[len(p):], key)
+	return prefixed
+}
+
+// Suffix strips the prefix from a key
+func (p Prefix) Suffix(key []byte) []byte {
+	if len(key) < len(p) {
+		return nil
+	}
+	return key[len(p):]
+}
+
+// Iterate over the key-value pairs under this prefix in ascending order, passing keys with the prefix stripped
+func (p Prefix) Iterate(it KVIterable, fn func(key, value []byte) error) error {
+	return p.IterateRange(it, nil, nil, fn)
+}
+
+// IterateRange iterates over the unprefixed domain [start, end) under this prefix
+func (p Prefix) IterateRange(it KVIterable, start, end []byte, fn func(key, value []byte) error) error {
+	low, high := p.Key(start), p.end()
+	if end != nil {
+		high = p.Key(end)
+	}
+	return Iterate(it, low, high, func(key, value []byte) error {
+		return fn(p.Suffix(key), value)
+	})
+}
+
+// The first key greater than every key with this prefix or nil if there is none
+func (p Prefix) end() []byte {
+	end := make([]byte, len(p))
+	copy(end, p)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
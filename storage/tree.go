@@ -0,0 +1,245 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package storage
+
+import (
+	"errors"
+	"fmt"
+
+	ics23 "github.com/confio/ics23/go"
+	"github.com/cosmos/iavl"
+	dbm "github.com/tendermint/tm-db"
+)
+
+// RWTree is the versioned merkle tree (IAVL) that application state is committed to. Writes go to a working tree
+// which becomes a new immutable version on Save.
+type RWTree struct {
+	tree *iavl.MutableTree
+}
+
+var _ KVStore = (*RWTree)(nil)
+
+func NewRWTree(db dbm.DB, cacheSize int) (*RWTree, error) {
+	tree, err := iavl.NewMutableTree(db, cacheSize)
+	if err != nil {
+		return nil, err
+	}
+	return &RWTree{tree: tree}, nil
+}
+
+// Load the tree at version, or the latest version if version is 0
+func (rwt *RWTree) Load(version int64) error {
+	if version == 0 {
+		_, err := rwt.tree.Load()
+		return err
+	}
+	v, err := rwt.tree.LoadVersion(version)
+	if err != nil {
+		return err
+	}
+	if v != version {
+		return fmt.Errorf("tried to load version %d of state tree but loaded version %d", version, v)
+	}
+	return nil
+}
+
+// Rollback loads version, which must have been saved, and deletes all later versions so the next Save creates
+// version+1 again
+func (rwt *RWTree) Rollback(version int64) error {
+	if !rwt.tree.VersionExists(version) {
+		return fmt.Errorf("version %d of state tree does not exist", version)
+	}
+	if _, err := rwt.tree.LoadVersionForOverwriting(version); err != nil {
+		return fmt.Errorf("could not roll state tree back to version %d: %w", version, err)
+	}
+	return nil
+}
+
+// VersionExists reports whether version has been saved and not deleted
+func (rwt *RWTree) VersionExists(version int64) bool {
+	return rwt.tree.VersionExists(version)
+}
+
+// AvailableVersions returns the saved versions that have not been deleted in ascending order
+func (rwt *RWTree) AvailableVersions() []int64 {
+	available := rwt.tree.AvailableVersions()
+	versions := make([]int64, len(available))
+	for i, v := range available {
+		versions[i] = int64(v)
+	}
+	return versions
+}
+
+// DeleteVersions deletes saved versions other than the latest, which can no longer be loaded or read
+func (rwt *RWTree) DeleteVersions(versions ...int64) error {
+	if err := rwt.tree.DeleteVersions(versions...); err != nil {
+		return fmt.Errorf("could not delete versions of state tree: %w", err)
+	}
+	return nil
+}
+
+// SetInitialVersion makes the first Save of an empty tree create version rather than version 1
+func (rwt *RWTree) SetInitialVersion(version uint64) {
+	rwt.tree.SetInitialVersion(version)
+}
+
+// Save the working tree as a new version returning its root hash
+func (rwt *RWTree) Save() ([]byte, int64, error) {
+	return rwt.tree.SaveVersion()
+}
+
+// Hash returns the root hash of the last saved version
+func (rwt *RWTree) Hash() []byte {
+	return rwt.tree.Hash()
+}
+
+// Version returns the last saved version
+func (rwt *RWTree) Version() int64 {
+	return rwt.tree.Version()
+}
+
+func (rwt *RWTree) Get(key []byte) ([]byte, error) {
+	_, value := rwt.tree.Get(key)
+	return value, nil
+}
+
+func (rwt *RWTree) Has(key []byte) (bool, error) {
+	return rwt.tree.Has(key), nil
+}
+
+func (rwt *RWTree) Set(key, value []byte) error {
+	rwt.tree.Set(key, value)
+	return nil
+}
+
+func (rwt *RWTree) Delete(key []byte) error {
+	rwt.tree.Remove(key)
+	return nil
+}
+
+func (rwt *RWTree) Iterator(start, end []byte) (KVIterator, error) {
+	return iterator(rwt.tree.ImmutableTree, start, end, true), nil
+}
+
+func (rwt *RWTree) ReverseIterator(start, end []byte) (KVIterator, error) {
+	return iterator(rwt.tree.ImmutableTree, start, end, false), nil
+}
+
+// GetImmutable returns a read-only view of a saved version
+func (rwt *RWTree) GetImmutable(version int64) (*ImmutableTree, error) {
+	tree, err := rwt.tree.GetImmutable(version)
+	if err != nil {
+		return nil, fmt.Errorf("could not load version %d of state tree: %w", version, err)
+	}
+	return &ImmutableTree{tree: tree}, nil
+}
+
+// Restore copies src into this tree, which must be empty, as version src.Version(). Node versions are copied along
+// with the nodes so the copy has the same hash as src.
+func (rwt *RWTree) Restore(src *ImmutableTree) error {
+	importer, err := rwt.tree.Import(src.Version())
+	if err != nil {
+		return fmt.Errorf("could not restore version %d of state tree: %w", src.Version(), err)
+	}
+	defer importer.Close()
+	exporter := src.tree.Export()
+	defer exporter.Close()
+	for {
+		node, err := exporter.Next()
+		if errors.Is(err, iavl.ExportDone) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("could not export version %d of state tree: %w", src.Version(), err)
+		}
+		if err := importer.Add(node); err != nil {
+			return fmt.Errorf("could not restore version %d of state tree: %w", src.Version(), err)
+		}
+	}
+	if err := importer.Commit(); err != nil {
+		return fmt.Errorf("could not restore version %d of state tree: %w", src.Version(), err)
+	}
+	return nil
+}
+
+// ImmutableTree is a read-only saved version of an RWTree
+type ImmutableTree struct {
+	tree *iavl.ImmutableTree
+}
+
+var _ KVReaderIterable = (*ImmutableTree)(nil)
+
+func (imt *ImmutableTree) Get(key []byte) ([]byte, error) {
+	_, value := imt.tree.Get(key)
+	return value, nil
+}
+
+func (imt *ImmutableTree) Has(key []byte) (bool, error) {
+	return imt.tree.Has(key), nil
+}
+
+func (imt *ImmutableTree) Iterator(start, end []byte) (KVIterator, error) {
+	return iterator(imt.tree, start, end, true), nil
+}
+
+func (imt *ImmutableTree) ReverseIterator(start, end []byte) (KVIterator, error) {
+	return iterator(imt.tree, start, end, false), nil
+}
+
+// GetWithProof returns the value of key with a proof that the tree root commits to it, or to its absence when value
+// is nil. The proof verifies against Hash with ics23.IavlSpec.
+func (imt *ImmutableTree) GetWithProof(key []byte) ([]byte, *ics23.CommitmentProof, error) {
+	_, value := imt.tree.Get(key)
+	var proof *ics23.CommitmentProof
+	var err error
+	if value == nil {
+		proof, err = imt.tree.GetNonMembershipProof(key)
+	} else {
+		proof, err = imt.tree.GetMembershipProof(key)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not prove key %X: %w", key, err)
+	}
+	return value, proof, nil
+}
+
+func (imt *ImmutableTree) Hash() []byte {
+	return imt.tree.Hash()
+}
+
+func (imt *ImmutableTree) Version() int64 {
+	return imt.tree.Version()
+}
+
+func iterator(tree *iavl.ImmutableTree, start, end []byte, ascending bool) KVIterator {
+	if tree.Size() == 0 {
+		// IAVL cannot traverse an empty root
+		return newMergeIterator(start, end, nil, emptyIterator{}, ascending)
+	}
+	return tree.Iterator(start, end, ascending)
+}
+
+type emptyIterator struct{}
+
+func (emptyIterator) Domain() ([]byte, []byte) { return nil, nil }
+func (emptyIterator) Valid() bool              { return false }
+func (emptyIterator) Next()                    {}
+func (emptyIterator) Key() []byte              { return nil }
+func (emptyIterator) Value() []byte            { return nil }
+func (emptyIterator) Error() error             { return nil }
+func (emptyIterator) Close() error             { return nil }
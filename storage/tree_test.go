@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"testing"
+
+	ics23 "github.com/confio/ics23/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tm-db"
+)
+
+func TestImmutableTree_GetWithProof(t *testing.T) {
+	tree, err := NewRWTree(dbm.NewMemDB(), 100)
+	require.NoError(t, err)
+	for _, k := range []string{"a", "c", "e"} {
+		require.NoError(t, tree.Set([]byte(k), []byte("v1-"+k)))
+	}
+	hash1, version1, err := tree.Save()
+	require.NoError(t, err)
+	require.NoError(t, tree.Set([]byte("c"), []byte("v2-c")))
+	_, _, err = tree.Save()
+	require.NoError(t, err)
+
+	// Proofs are against the hash of the version they were read from
+	imt, err := tree.GetImmutable(version1)
+	require.NoError(t, err)
+	value, proof, err := imt.GetWithProof([]byte("c"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("v1-c"), value)
+	assert.True(t, ics23.VerifyMembership(ics23.IavlSpec, hash1, proof, []byte("c"), value))
+	assert.False(t, ics23.VerifyMembership(ics23.IavlSpec, hash1, proof, []byte("c"), []byte("v2-c")))
+	assert.False(t, ics23.VerifyMembership(ics23.IavlSpec, tree.Hash(), proof, []byte("c"), value))
+
+	value, proof, err = imt.GetWithProof([]byte("b"))
+	require.NoError(t, err)
+	assert.Nil(t, value)
+	assert.True(t, ics23.VerifyNonMembership(ics23.IavlSpec, hash1, proof, []byte("b")))
+}
+
+func TestRWTree_Restore(t *testing.T) {
+	tree, err := NewRWTree(dbm.NewMemDB(), 100)
+	require.NoError(t, err)
+	for _, k := range []string{"a", "c", "e"} {
+		require.NoError(t, tree.Set([]byte(k), []byte("v1-"+k)))
+	}
+	_, _, err = tree.Save()
+	require.NoError(t, err)
+	require.NoError(t, tree.Set([]byte("c"), []byte("v2-c")))
+	require.NoError(t, tree.Delete([]byte("e")))
+	hash2, version2, err := tree.Save()
+	require.NoError(t, err)
+
+	imt, err := tree.GetImmutable(version2)
+	require.NoError(t, err)
+	restored, err := NewRWTree(dbm.NewMemDB(), 100)
+	require.NoError(t, err)
+	require.NoError(t, restored.Restore(imt))
+	assert.Equal(t, version2, restored.Version())
+	assert.Equal(t, hash2, restored.Hash())
+	value, err := restored.Get([]byte("c"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("v2-c"), value)
+
+	// The copy carries on from the restored version
+	require.NoError(t, restored.Set([]byte("e"), []byte("v3-e")))
+	require.NoError(t, tree.Set([]byte("e"), []byte("v3-e")))
+	hash3, version3, err := restored.Save()
+	require.NoError(t, err)
+	assert.Equal(t, version2+1, version3)
+	original, _, err := tree.Save()
+	require.NoError(t, err)
+	assert.Equal(t, original, hash3)
+}
+
+func TestRWTree_Rollback(t *testing.T) {
+	db := dbm.NewMemDB()
+	tree, err := NewRWTree(db, 100)
+	require.NoError(t, err)
+	var hashes [][]byte
+	for _, v := range []string{"v1", "v2", "v3"} {
+		require.NoError(t, tree.Set([]byte("a"), []byte(v)))
+		hash, _, err := tree.Save()
+		require.NoError(t, err)
+		hashes = append(hashes, hash)
+	}
+	require.Error(t, tree.Rollback(4))
+
+	require.NoError(t, tree.Rollback(1))
+	assert.Equal(t, int64(1), tree.Version())
+	assert.Equal(t, hashes[0], tree.Hash())
+	assert.False(t, tree.VersionExists(2))
+	value, err := tree.Get([]byte("a"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("v1"), value)
+
+	// The rolled back versions are gone from the db so can be written again
+	reopened, err := NewRWTree(db, 100)
+	require.NoError(t, err)
+	require.NoError(t, reopened.Load(0))
+	assert.Equal(t, int64(1), reopened.Version())
+	require.NoError(t, reopened.Set([]byte("a"), []byte("v2")))
+	hash, version, err := reopened.Save()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), version)
+	assert.Equal(t, hashes[1], hash)
+}
@@ -23,9 +23,9 @@ type Codec interface {
 }
 
 type Encoder interface {
-	EncodeTx(envelope Envelope) ([]byte, error)
+	EncodeTx(envelope *Envelope) ([]byte, error)
 }
 
 type Decoder interface {
-	DecodeTx(txBytes []byte) (Envelope, error)
+	DecodeTx(txBytes []byte) (*Envelope, error)
 }
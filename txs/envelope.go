@@ -17,5 +17,106 @@
 
 package txs
 
-type Envelope interface {
+import (
+	"fmt"
+
+	"github.com/sunvim/yaoguang/codes"
+	"github.com/sunvim/yaoguang/crypto"
+	"github.com/sunvim/yaoguang/txs/payload"
+)
+
+// Envelope carries a Tx and the signatures of the accounts that authorised it
+type Envelope struct {
+	Signatories []Signatory `json:"signatories"`
+	Tx          *Tx         `json:"tx"`
+}
+
+type Signatory struct {
+	Address   *crypto.Address   `json:"address,omitempty"`
+	PublicKey *crypto.PublicKey `json:"public_key"`
+	Signature *crypto.Signature `json:"signature"`
+}
+
+// Enclose a payload for chainID in an unsigned Envelope
+func Enclose(chainID string, p payload.Payload) *Envelope {
+	tx := NewTx(p)
+	tx.ChainID = chainID
+	return &Envelope{Tx: tx}
+}
+
+// Sign the Tx with each of signers, replacing any existing signatures
+func (txEnv *Envelope) Sign(signers ...crypto.AddressableSigner) error {
+	signBytes, err := txEnv.Tx.SignBytes()
+	if err != nil {
+		return err
+	}
+	txEnv.Signatories = txEnv.Signatories[:0]
+	for _, s := range signers {
+		sig, err := s.Sign(signBytes)
+		if err != nil {
+			return fmt.Errorf("could not sign %v: %w", txEnv.Tx, err)
+		}
+		address := s.GetAddress()
+		txEnv.Signatories = append(txEnv.Signatories, Signatory{
+			Address:   &address,
+			PublicKey: s.GetPublicKey(),
+			Signature: sig,
+		})
+	}
+	return nil
+}
+
+// Verify that the Tx belongs to chainID, that every signature is valid, and that every input is signed for
+func (txEnv *Envelope) Verify(chainID string) error {
+	if txEnv.Tx == nil || txEnv.Tx.Payload == nil {
+		return codes.InvalidTx.Errorf("envelope contains no transaction")
+	}
+	if txEnv.Tx.ChainID != chainID {
+		return codes.WrongChainID.Errorf("transaction is for chain %q but this is chain %q", txEnv.Tx.ChainID, chainID)
+	}
+	if err := CheckInputs(txEnv.Tx.Payload); err != nil {
+		return err
+	}
+	signBytes, err := txEnv.Tx.SignBytes()
+	if err != nil {
+		return err
+	}
+	signed := make(map[crypto.Address]bool, len(txEnv.Signatories))
+	for i, s := range txEnv.Signatories {
+		if !s.PublicKey.IsSet() {
+			return codes.InvalidSignature.Errorf("signatory %d has no public key", i)
+		}
+		address := s.PublicKey.GetAddress()
+		if s.Address != nil && *s.Address != address {
+			return codes.InvalidSignature.Errorf("signatory %d claims address %v but its public key has address %v", i,
+				*s.Address, address)
+		}
+		if s.Signature == nil {
+			return codes.InvalidSignature.Errorf("signatory %v has no signature", address)
+		}
+		if err := s.PublicKey.Verify(signBytes, s.Signature); err != nil {
+			return codes.InvalidSignature.Wrap(err, fmt.Sprintf("invalid signature from %v", address))
+		}
+		signed[address] = true
+	}
+	for _, input := range txEnv.Tx.GetInputs() {
+		if !signed[input.Address] {
+			return codes.InvalidSignature.Errorf("input %v is not signed for", input.Address)
+		}
+	}
+	return nil
+}
+
+// PublicKeyOf returns the public key of the signatory for address or nil if it has not signed
+func (txEnv *Envelope) PublicKeyOf(address crypto.Address) *crypto.PublicKey {
+	for _, s := range txEnv.Signatories {
+		if s.PublicKey.IsSet() && s.PublicKey.GetAddress() == address {
+			return s.PublicKey
+		}
+	}
+	return nil
+}
+
+func (txEnv *Envelope) String() string {
+	return fmt.Sprintf("TxEnvelope{Signatures: %d, Tx: %s}", len(txEnv.Signatories), txEnv.Tx)
 }
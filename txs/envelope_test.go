@@ -0,0 +1,90 @@
+package txs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/sunvim/yaoguang/codes"
+	"github.com/sunvim/yaoguang/crypto"
+	"github.com/sunvim/yaoguang/txs/payload"
+)
+
+func TestEnvelope_SignVerify(t *testing.T) {
+	key := crypto.PrivateKeyFromSecret("signer", crypto.CurveTypeSecp256k1)
+	other := crypto.PrivateKeyFromSecret("other", crypto.CurveTypeEd25519)
+	tx := payload.NewSendTx()
+	tx.AddInput(key.GetAddress(), 10, 1)
+	tx.AddOutput(other.GetAddress(), 10)
+
+	txEnv := Enclose("chain", tx)
+	require.NoError(t, txEnv.Sign(&key))
+	require.NoError(t, txEnv.Verify("chain"))
+	assert.Error(t, txEnv.Verify("another-chain"))
+
+	// Signed by someone other than the input
+	require.NoError(t, txEnv.Sign(&other))
+	assert.Error(t, txEnv.Verify("chain"))
+
+	// Tampered after signing
+	require.NoError(t, txEnv.Sign(&key))
+	tx.Outputs[0].Amount = 20
+	assert.Error(t, txEnv.Verify("chain"))
+}
+
+func TestJSONCodec(t *testing.T) {
+	key := crypto.PrivateKeyFromSecret("signer", crypto.CurveTypeEd25519)
+	tx := payload.NewSendTx()
+	tx.AddInput(key.GetAddress(), 10, 3)
+	tx.AddOutput(crypto.Address{1, 2, 3}, 10)
+	txEnv := Enclose("chain", tx)
+	require.NoError(t, txEnv.Sign(&key))
+
+	codec := NewJSONCodec()
+	bs, err := codec.EncodeTx(txEnv)
+	require.NoError(t, err)
+	txEnvOut, err := codec.DecodeTx(bs)
+	require.NoError(t, err)
+	assert.Equal(t, txEnv.Tx.Hash(), txEnvOut.Tx.Hash())
+	assert.Equal(t, tx, txEnvOut.Tx.Payload)
+	require.NoError(t, txEnvOut.Verify("chain"))
+}
+
+func TestJSONCodec_NestedPayloads(t *testing.T) {
+	key := crypto.PrivateKeyFromSecret("signer", crypto.CurveTypeEd25519)
+	send := payload.NewSendTx()
+	send.AddInput(crypto.Address{1}, 10, 0)
+	send.AddOutput(crypto.Address{2}, 10)
+	proposal := payload.NewProposal("transfer", "move some funds", send)
+	txEnv := Enclose("chain", payload.NewProposalTx(key.GetAddress(), 1, proposal))
+	require.NoError(t, txEnv.Sign(&key))
+
+	codec := NewJSONCodec()
+	bs, err := codec.EncodeTx(txEnv)
+	require.NoError(t, err)
+	txEnvOut, err := codec.DecodeTx(bs)
+	require.NoError(t, err)
+	proposalOut := txEnvOut.Tx.Payload.(*payload.ProposalTx).Proposal
+	assert.Equal(t, send, proposalOut.Payloads[0].Payload)
+	hash, err := proposal.Hash()
+	require.NoError(t, err)
+	hashOut, err := proposalOut.Hash()
+	require.NoError(t, err)
+	assert.Equal(t, hash, hashOut)
+}
+
+func TestEnvelope_VerifyMissingInputs(t *testing.T) {
+	key := crypto.PrivateKeyFromSecret("signer", crypto.CurveTypeEd25519)
+	codec := NewJSONCodec()
+	for _, typ := range []string{"NameTx", "BondTx", "UnbondTx", "PermissionsTx", "ProposalTx", "UpgradeTx"} {
+		txEnv, err := codec.DecodeTx([]byte(`{"tx":{"chain_id":"chain","type":"` + typ + `","payload":{}}}`))
+		require.NoError(t, err, typ)
+		assert.Equal(t, codes.InvalidTx, codes.FromError(txEnv.Verify("chain")), typ)
+		// signatures do not make up for the missing input
+		require.NoError(t, txEnv.Sign(&key))
+		assert.Equal(t, codes.InvalidTx, codes.FromError(txEnv.Verify("chain")), typ)
+	}
+	txEnv, err := codec.DecodeTx([]byte(`{"tx":{"chain_id":"chain","type":"SendTx","payload":{"inputs":[null]}}}`))
+	require.NoError(t, err)
+	assert.Equal(t, codes.InvalidTx, codes.FromError(txEnv.Verify("chain")))
+}
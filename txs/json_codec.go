@@ -0,0 +1,41 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package txs
+
+import (
+	"encoding/json"
+)
+
+type jsonCodec struct{}
+
+func NewJSONCodec() Codec {
+	return &jsonCodec{}
+}
+
+func (*jsonCodec) EncodeTx(env *Envelope) ([]byte, error) {
+	return json.Marshal(env)
+}
+
+func (*jsonCodec) DecodeTx(txBytes []byte) (*Envelope, error) {
+	env := new(Envelope)
+	err := json.Unmarshal(txBytes, env)
+	if err != nil {
+		return nil, err
+	}
+	return env, nil
+}
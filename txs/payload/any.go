@@ -0,0 +1,60 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package payload
+
+import (
+	"encoding/json"
+)
+
+// Any carries a payload along with its type so payloads can be nested inside other payloads
+type Any struct {
+	Payload
+}
+
+type anyWrapper struct {
+	Type    Type            `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+func NewAny(p Payload) *Any {
+	return &Any{Payload: p}
+}
+
+func (a *Any) MarshalJSON() ([]byte, error) {
+	bs, err := json.Marshal(a.Payload)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(anyWrapper{
+		Type:    a.Type(),
+		Payload: bs,
+	})
+}
+
+func (a *Any) UnmarshalJSON(data []byte) error {
+	w := new(anyWrapper)
+	err := json.Unmarshal(data, w)
+	if err != nil {
+		return err
+	}
+	a.Payload, err = New(w.Type)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(w.Payload, a.Payload)
+}
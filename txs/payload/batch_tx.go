@@ -0,0 +1,60 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package payload
+
+import (
+	"fmt"
+
+	"github.com/sunvim/yaoguang/crypto"
+)
+
+// BatchTx executes its payloads in order as a single unit, if any fails none take effect. Inputs carries one entry
+// per signing account with its sequence, every account that appears in the inputs of a step must be among them.
+// The steps' own inputs carry amounts only, their sequences must be zero.
+type BatchTx struct {
+	Inputs   []*TxInput `json:"inputs"`
+	Payloads []*Any     `json:"payloads"`
+}
+
+func NewBatchTx(payloads ...Payload) *BatchTx {
+	tx := &BatchTx{}
+	for _, p := range payloads {
+		tx.Payloads = append(tx.Payloads, NewAny(p))
+	}
+	return tx
+}
+
+// AddInput adds a signing account
+func (tx *BatchTx) AddInput(address crypto.Address, sequence uint64) {
+	tx.Inputs = append(tx.Inputs, &TxInput{
+		Address:  address,
+		Sequence: sequence,
+	})
+}
+
+func (tx *BatchTx) GetInputs() []*TxInput {
+	return tx.Inputs
+}
+
+func (tx *BatchTx) Type() Type {
+	return TypeBatch
+}
+
+func (tx *BatchTx) String() string {
+	return fmt.Sprintf("BatchTx{%v; %d payloads}", tx.Inputs, len(tx.Payloads))
+}
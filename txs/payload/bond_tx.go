@@ -0,0 +1,86 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package payload
+
+import (
+	"fmt"
+
+	"github.com/sunvim/yaoguang/crypto"
+)
+
+// BondTx locks Input.Amount from the input account and adds it to the voting power of Validator. Anyone may
+// delegate to an existing validator but only the holder of a key may make it a validator, so a BondTx for a key
+// that is not yet a validator must come from the account of that key.
+type BondTx struct {
+	Input *TxInput `json:"input"`
+	// Bond to this validator, or to the input account's own key if nil
+	Validator *crypto.PublicKey `json:"validator,omitempty"`
+}
+
+func NewBondTx(address crypto.Address, amount, sequence uint64, validator *crypto.PublicKey) *BondTx {
+	return &BondTx{
+		Input: &TxInput{
+			Address:  address,
+			Amount:   amount,
+			Sequence: sequence,
+		},
+		Validator: validator,
+	}
+}
+
+func (tx *BondTx) GetInputs() []*TxInput {
+	return []*TxInput{tx.Input}
+}
+
+func (tx *BondTx) Type() Type {
+	return TypeBond
+}
+
+func (tx *BondTx) String() string {
+	return fmt.Sprintf("BondTx{%v -> %v}", tx.Input, tx.Validator)
+}
+
+// UnbondTx removes Input.Amount of the input account's delegation from Validator. The amount is returned to the
+// account once the unbonding period has passed.
+type UnbondTx struct {
+	Input     *TxInput       `json:"input"`
+	Validator crypto.Address `json:"validator"`
+}
+
+func NewUnbondTx(address crypto.Address, amount, sequence uint64, validator crypto.Address) *UnbondTx {
+	return &UnbondTx{
+		Input: &TxInput{
+			Address:  address,
+			Amount:   amount,
+			Sequence: sequence,
+		},
+		Validator: validator,
+	}
+}
+
+func (tx *UnbondTx) GetInputs() []*TxInput {
+	return []*TxInput{tx.Input}
+}
+
+func (tx *UnbondTx) Type() Type {
+	return TypeUnbond
+}
+
+func (tx *UnbondTx) String() string {
+	return fmt.Sprintf("UnbondTx{%v <- %v}", tx.Input, tx.Validator)
+}
@@ -0,0 +1,60 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package payload
+
+import (
+	"fmt"
+
+	"github.com/sunvim/yaoguang/crypto"
+)
+
+// NameTx registers, updates, transfers or releases a name. Input.Amount is the lease fee, which buys
+// Amount / names.CostPerBlock(Name, Data) blocks.
+type NameTx struct {
+	Input *TxInput `json:"input"`
+	Name  string   `json:"name"`
+	Data  string   `json:"data,omitempty"`
+	// Transfer the name to this address, only the current owner may
+	NewOwner *crypto.Address `json:"new_owner,omitempty"`
+	// Give up the name before its lease runs out, only the current owner may
+	Release bool `json:"release,omitempty"`
+}
+
+func NewNameTx(address crypto.Address, amount, sequence uint64, name, data string) *NameTx {
+	return &NameTx{
+		Input: &TxInput{
+			Address:  address,
+			Amount:   amount,
+			Sequence: sequence,
+		},
+		Name: name,
+		Data: data,
+	}
+}
+
+func (tx *NameTx) GetInputs() []*TxInput {
+	return []*TxInput{tx.Input}
+}
+
+func (tx *NameTx) Type() Type {
+	return TypeName
+}
+
+func (tx *NameTx) String() string {
+	return fmt.Sprintf("NameTx{%v -> %s: %s}", tx.Input, tx.Name, tx.Data)
+}
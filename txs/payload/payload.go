@@ -0,0 +1,115 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package payload
+
+import (
+	"fmt"
+)
+
+type Type uint32
+
+// Types of Payload implementations
+const (
+	TypeUnknown Type = iota
+	// Account transactions
+	TypeSend
+	// Name registry
+	TypeName
+	// Governance
+	TypeProposal
+	// Staking
+	TypeBond
+	TypeUnbond
+	// Access control
+	TypePermissions
+	// Several payloads executed atomically
+	TypeBatch
+	// Coordinated upgrades of the application protocol
+	TypeUpgrade
+)
+
+var nameFromType = map[Type]string{
+	TypeUnknown:     "UnknownTx",
+	TypeSend:        "SendTx",
+	TypeName:        "NameTx",
+	TypeProposal:    "ProposalTx",
+	TypeBond:        "BondTx",
+	TypeUnbond:      "UnbondTx",
+	TypePermissions: "PermissionsTx",
+	TypeBatch:       "BatchTx",
+	TypeUpgrade:     "UpgradeTx",
+}
+
+var typeFromName = make(map[string]Type)
+
+func init() {
+	for t, n := range nameFromType {
+		typeFromName[n] = t
+	}
+}
+
+func TypeFromString(name string) Type {
+	return typeFromName[name]
+}
+
+func (typ Type) String() string {
+	name, ok := nameFromType[typ]
+	if ok {
+		return name
+	}
+	return "UnknownTx"
+}
+
+func (typ Type) MarshalText() ([]byte, error) {
+	return []byte(typ.String()), nil
+}
+
+func (typ *Type) UnmarshalText(data []byte) error {
+	*typ = TypeFromString(string(data))
+	return nil
+}
+
+type Payload interface {
+	String() string
+	// GetInputs returns the accounts that must sign for this payload, their sequences are checked and bumped
+	GetInputs() []*TxInput
+	Type() Type
+}
+
+// New returns an empty payload of txType
+func New(txType Type) (Payload, error) {
+	switch txType {
+	case TypeSend:
+		return &SendTx{}, nil
+	case TypeName:
+		return &NameTx{}, nil
+	case TypeProposal:
+		return &ProposalTx{}, nil
+	case TypeBond:
+		return &BondTx{}, nil
+	case TypeUnbond:
+		return &UnbondTx{}, nil
+	case TypePermissions:
+		return &PermissionsTx{}, nil
+	case TypeBatch:
+		return &BatchTx{}, nil
+	case TypeUpgrade:
+		return &UpgradeTx{}, nil
+	}
+	return nil, fmt.Errorf("unknown payload type: %d", txType)
+}
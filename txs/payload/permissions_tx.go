@@ -0,0 +1,63 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package payload
+
+import (
+	"fmt"
+
+	"github.com/sunvim/yaoguang/crypto"
+	"github.com/sunvim/yaoguang/permission"
+)
+
+// PermissionsTx grants or revokes a permission or role, the input account must hold ModifyPermissions
+type PermissionsTx struct {
+	Input *TxInput `json:"input"`
+	// Account to change, nil changes the global permissions
+	Target *crypto.Address   `json:"target,omitempty"`
+	Action permission.Action `json:"action"`
+	// Permissions to set or unset for SetBase and UnsetBase
+	Permission permission.PermFlag `json:"permission,omitempty"`
+	// Value to set for SetBase
+	Value bool `json:"value,omitempty"`
+	// Role for AddRole and RemoveRole
+	Role string `json:"role,omitempty"`
+}
+
+func NewPermissionsTx(address crypto.Address, sequence uint64, target *crypto.Address,
+	action permission.Action) *PermissionsTx {
+	return &PermissionsTx{
+		Input: &TxInput{
+			Address:  address,
+			Sequence: sequence,
+		},
+		Target: target,
+		Action: action,
+	}
+}
+
+func (tx *PermissionsTx) GetInputs() []*TxInput {
+	return []*TxInput{tx.Input}
+}
+
+func (tx *PermissionsTx) Type() Type {
+	return TypePermissions
+}
+
+func (tx *PermissionsTx) String() string {
+	return fmt.Sprintf("PermissionsTx{%v -> %v: %s}", tx.Input, tx.Target, tx.Action)
+}
@@ -0,0 +1,178 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package payload
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/sunvim/yaoguang/binary"
+	"github.com/sunvim/yaoguang/crypto"
+)
+
+// ProposalTx either submits a Proposal or votes for one already submitted by its hash. Only validators may vote and
+// the weight of a vote is the voter's validator power when the proposal is tallied.
+type ProposalTx struct {
+	Input *TxInput `json:"input"`
+	// Vote for the proposal with this hash
+	ProposalHash binary.HexBytes `json:"proposal_hash,omitempty"`
+	// Submit this proposal, a proposer who is a validator also votes for it
+	Proposal *Proposal `json:"proposal,omitempty"`
+}
+
+func NewProposalTx(address crypto.Address, sequence uint64, proposal *Proposal) *ProposalTx {
+	return &ProposalTx{
+		Input: &TxInput{
+			Address:  address,
+			Sequence: sequence,
+		},
+		Proposal: proposal,
+	}
+}
+
+func NewVoteTx(address crypto.Address, sequence uint64, proposalHash binary.HexBytes) *ProposalTx {
+	return &ProposalTx{
+		Input: &TxInput{
+			Address:  address,
+			Sequence: sequence,
+		},
+		ProposalHash: proposalHash,
+	}
+}
+
+func (tx *ProposalTx) GetInputs() []*TxInput {
+	return []*TxInput{tx.Input}
+}
+
+func (tx *ProposalTx) Type() Type {
+	return TypeProposal
+}
+
+func (tx *ProposalTx) String() string {
+	if tx.Proposal != nil {
+		return fmt.Sprintf("ProposalTx{%v -> %v}", tx.Input, tx.Proposal)
+	}
+	return fmt.Sprintf("ProposalTx{%v votes for %v}", tx.Input, tx.ProposalHash)
+}
+
+// Proposal is a batch of payloads that are executed together, without signatures, once validators holding enough of
+// the total power have voted for it. Either every payload takes effect or none does. Every input of a payload must
+// be the proposer or a voter and hold the permission the payload needs, except that the vote alone schedules an
+// upgrade.
+type Proposal struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Payloads    []*Any `json:"payloads"`
+}
+
+func NewProposal(name, description string, payloads ...Payload) *Proposal {
+	proposal := &Proposal{
+		Name:        name,
+		Description: description,
+	}
+	for _, p := range payloads {
+		proposal.Payloads = append(proposal.Payloads, NewAny(p))
+	}
+	return proposal
+}
+
+// Hash identifies the proposal for voting
+func (p *Proposal) Hash() (binary.HexBytes, error) {
+	bs, err := json.Marshal(p)
+	if err != nil {
+		return nil, fmt.Errorf("could not hash proposal %s: %w", p.Name, err)
+	}
+	return crypto.SHA256(bs), nil
+}
+
+func (p *Proposal) String() string {
+	return fmt.Sprintf("Proposal{%s; %d payloads}", p.Name, len(p.Payloads))
+}
+
+type ProposalState uint32
+
+const (
+	ProposalStatePending ProposalState = iota
+	ProposalStateExecuted
+	ProposalStateFailed
+	ProposalStateExpired
+)
+
+var nameFromProposalState = map[ProposalState]string{
+	ProposalStatePending:  "pending",
+	ProposalStateExecuted: "executed",
+	ProposalStateFailed:   "failed",
+	ProposalStateExpired:  "expired",
+}
+
+func (s ProposalState) String() string {
+	name, ok := nameFromProposalState[s]
+	if ok {
+		return name
+	}
+	return "unknown"
+}
+
+func (s ProposalState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *ProposalState) UnmarshalText(data []byte) error {
+	for state, name := range nameFromProposalState {
+		if name == string(data) {
+			*s = state
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown proposal state: %s", data)
+}
+
+// Ballot records a proposal and the votes cast for it
+type Ballot struct {
+	Proposal *Proposal        `json:"proposal"`
+	Proposer crypto.Address   `json:"proposer"`
+	Votes    []crypto.Address `json:"votes,omitempty"`
+	// Height the proposal was submitted at
+	Height uint64 `json:"height"`
+	// Voting closes at this height
+	Expires uint64        `json:"expires"`
+	State   ProposalState `json:"state"`
+	// Why execution failed
+	Error string `json:"error,omitempty"`
+}
+
+func (b *Ballot) HasVoted(address crypto.Address) bool {
+	for _, voter := range b.Votes {
+		if voter == address {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *Ballot) Encode() ([]byte, error) {
+	return json.Marshal(b)
+}
+
+func DecodeBallot(bs []byte) (*Ballot, error) {
+	ballot := new(Ballot)
+	if err := json.Unmarshal(bs, ballot); err != nil {
+		return nil, err
+	}
+	return ballot, nil
+}
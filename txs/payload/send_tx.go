@@ -0,0 +1,54 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package payload
+
+import (
+	"fmt"
+
+	"github.com/sunvim/yaoguang/crypto"
+)
+
+// SendTx moves tokens from its inputs to its outputs, the input and output totals must match
+type SendTx struct {
+	Inputs  []*TxInput  `json:"inputs"`
+	Outputs []*TxOutput `json:"outputs"`
+}
+
+func NewSendTx() *SendTx {
+	return &SendTx{}
+}
+
+func (tx *SendTx) GetInputs() []*TxInput {
+	return tx.Inputs
+}
+
+func (tx *SendTx) Type() Type {
+	return TypeSend
+}
+
+func (tx *SendTx) AddInput(address crypto.Address, amount, sequence uint64) {
+	tx.Inputs = append(tx.Inputs, &TxInput{Address: address, Amount: amount, Sequence: sequence})
+}
+
+func (tx *SendTx) AddOutput(address crypto.Address, amount uint64) {
+	tx.Outputs = append(tx.Outputs, &TxOutput{Address: address, Amount: amount})
+}
+
+func (tx *SendTx) String() string {
+	return fmt.Sprintf("SendTx{%v -> %v}", tx.Inputs, tx.Outputs)
+}
@@ -0,0 +1,44 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package payload
+
+import (
+	"fmt"
+
+	"github.com/sunvim/yaoguang/crypto"
+)
+
+type TxInput struct {
+	Address crypto.Address `json:"address"`
+	Amount  uint64         `json:"amount"`
+	// Must be exactly one more than the sequence of the account's last transaction
+	Sequence uint64 `json:"sequence"`
+}
+
+func (input *TxInput) String() string {
+	return fmt.Sprintf("TxInput{%v, Amount: %d, Sequence: %d}", input.Address, input.Amount, input.Sequence)
+}
+
+type TxOutput struct {
+	Address crypto.Address `json:"address"`
+	Amount  uint64         `json:"amount"`
+}
+
+func (output *TxOutput) String() string {
+	return fmt.Sprintf("TxOutput{%v, Amount: %d}", output.Address, output.Amount)
+}
@@ -0,0 +1,84 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package payload
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sunvim/yaoguang/crypto"
+)
+
+// UpgradeTx schedules a coordinated upgrade of the application protocol, replacing any upgrade already scheduled, or
+// cancels the scheduled upgrade if Plan is nil. The input must hold Root unless it is passed by a proposal.
+type UpgradeTx struct {
+	Input *TxInput     `json:"input"`
+	Plan  *UpgradePlan `json:"plan,omitempty"`
+}
+
+// UpgradePlan names the upgrade to run at the start of the block at Height. A node whose binary has no handler for
+// the upgrade halts there until it is replaced by one that has.
+type UpgradePlan struct {
+	Name   string `json:"name"`
+	Height uint64 `json:"height"`
+	// Where to find the binary that runs the upgrade, for operators
+	Info string `json:"info,omitempty"`
+}
+
+func NewUpgradeTx(address crypto.Address, sequence uint64, plan *UpgradePlan) *UpgradeTx {
+	return &UpgradeTx{
+		Input: &TxInput{
+			Address:  address,
+			Sequence: sequence,
+		},
+		Plan: plan,
+	}
+}
+
+func (tx *UpgradeTx) GetInputs() []*TxInput {
+	return []*TxInput{tx.Input}
+}
+
+func (tx *UpgradeTx) Type() Type {
+	return TypeUpgrade
+}
+
+func (tx *UpgradeTx) String() string {
+	if tx.Plan == nil {
+		return fmt.Sprintf("UpgradeTx{%v cancels}", tx.Input)
+	}
+	return fmt.Sprintf("UpgradeTx{%v -> %v}", tx.Input, tx.Plan)
+}
+
+// Validate checks the plan is well formed, whether its height is still to come depends on the chain
+func (plan *UpgradePlan) Validate() error {
+	if plan.Name == "" {
+		return fmt.Errorf("upgrade plan has no name")
+	}
+	if strings.TrimSpace(plan.Name) != plan.Name || strings.ContainsAny(plan.Name, " \t\r\n") {
+		return fmt.Errorf("upgrade name %q must not contain whitespace", plan.Name)
+	}
+	if plan.Height == 0 {
+		return fmt.Errorf("upgrade plan %s has no height", plan.Name)
+	}
+	return nil
+}
+
+func (plan *UpgradePlan) String() string {
+	return fmt.Sprintf("UpgradePlan{%s at %d}", plan.Name, plan.Height)
+}
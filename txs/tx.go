@@ -0,0 +1,120 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package txs
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/sunvim/yaoguang/binary"
+	"github.com/sunvim/yaoguang/codes"
+	"github.com/sunvim/yaoguang/crypto"
+	"github.com/sunvim/yaoguang/txs/payload"
+)
+
+// Tx binds a payload to a chain, it is the part of an Envelope covered by signatures
+type Tx struct {
+	ChainID string
+	// Fee paid by the first input whether or not the payload succeeds, the fee per unit of gas ranks the
+	// transaction in the mempool
+	Fee uint64
+	payload.Payload
+}
+
+// wrapper carries the payload type so Tx can be decoded into the right payload
+type wrapper struct {
+	ChainID string          `json:"chain_id"`
+	Fee     uint64          `json:"fee,omitempty"`
+	Type    payload.Type    `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+func NewTx(p payload.Payload) *Tx {
+	return &Tx{Payload: p}
+}
+
+func (tx *Tx) MarshalJSON() ([]byte, error) {
+	bs, err := json.Marshal(tx.Payload)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(wrapper{
+		ChainID: tx.ChainID,
+		Fee:     tx.Fee,
+		Type:    tx.Type(),
+		Payload: bs,
+	})
+}
+
+func (tx *Tx) UnmarshalJSON(data []byte) error {
+	w := new(wrapper)
+	err := json.Unmarshal(data, w)
+	if err != nil {
+		return err
+	}
+	tx.ChainID = w.ChainID
+	tx.Fee = w.Fee
+	tx.Payload, err = payload.New(w.Type)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(w.Payload, tx.Payload)
+}
+
+// SignBytes is the canonical serialisation of the Tx that signatories sign
+func (tx *Tx) SignBytes() ([]byte, error) {
+	bs, err := json.Marshal(tx)
+	if err != nil {
+		return nil, fmt.Errorf("could not generate canonical SignBytes for Payload %v: %w", tx.Payload, err)
+	}
+	return bs, nil
+}
+
+// Hash identifies the Tx independently of its signatures
+func (tx *Tx) Hash() binary.HexBytes {
+	bs, err := tx.SignBytes()
+	if err != nil {
+		return nil
+	}
+	return crypto.SHA256(bs)
+}
+
+func (tx *Tx) String() string {
+	if tx == nil {
+		return "Tx{nil}"
+	}
+	return fmt.Sprintf("Tx{ChainID: %s; TxHash: %s; Payload: %s}", tx.ChainID, tx.Hash(), tx.Payload)
+}
+
+// CheckInputs rejects a payload with a missing input or output, such as the single Input of most payloads left out of
+// the JSON or a null among the outputs of a SendTx, before any of them is dereferenced
+func CheckInputs(p payload.Payload) error {
+	for i, input := range p.GetInputs() {
+		if input == nil {
+			return codes.InvalidTx.Errorf("input %d of %v is missing", i, p.Type())
+		}
+	}
+	if tx, ok := p.(*payload.SendTx); ok {
+		for i, output := range tx.Outputs {
+			if output == nil {
+				return codes.InvalidTx.Errorf("output %d of %v is missing", i, p.Type())
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+// Package upgrade holds the migrations this binary can run when the chain reaches a scheduled upgrade
+package upgrade
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/sunvim/yaoguang/state"
+)
+
+// ErrUnknownUpgrade is returned when the chain reaches an upgrade this binary has no handler for, the node must halt
+// and be restarted with a binary that has
+var ErrUnknownUpgrade = errors.New("upgrade needed")
+
+// Handler migrates state to a new version of the application protocol
+type Handler struct {
+	// Name of the upgrade plan that triggers this handler
+	Name string
+	// Application protocol version the chain runs from the block of the upgrade
+	AppVersion uint64
+	// Migrate transforms state at the start of the block of the upgrade, before any of its transactions
+	Migrate func(st *state.Cache) error
+}
+
+// Registry holds upgrade handlers by name
+type Registry struct {
+	sync.RWMutex
+	handlers map[string]*Handler
+}
+
+// Handlers are the upgrades this binary knows, migrations register themselves in an init function
+var Handlers = NewRegistry()
+
+func NewRegistry(handlers ...*Handler) *Registry {
+	r := &Registry{handlers: make(map[string]*Handler)}
+	for _, h := range handlers {
+		r.MustRegister(h)
+	}
+	return r
+}
+
+func (r *Registry) Register(h *Handler) error {
+	if h.Name == "" {
+		return fmt.Errorf("upgrade handler has no name")
+	}
+	if h.AppVersion == 0 {
+		return fmt.Errorf("upgrade handler %s has no app version", h.Name)
+	}
+	r.Lock()
+	defer r.Unlock()
+	if _, ok := r.handlers[h.Name]; ok {
+		return fmt.Errorf("upgrade handler %s is already registered", h.Name)
+	}
+	r.handlers[h.Name] = h
+	return nil
+}
+
+func (r *Registry) MustRegister(h *Handler) {
+	if err := r.Register(h); err != nil {
+		panic(err)
+	}
+}
+
+// Get returns the handler for the named upgrade, nil if there is none
+func (r *Registry) Get(name string) *Handler {
+	r.RLock()
+	defer r.RUnlock()
+	return r.handlers[name]
+}
+
+// Names of the registered upgrades in order
+func (r *Registry) Names() []string {
+	r.RLock()
+	defer r.RUnlock()
+	names := make([]string, 0, len(r.handlers))
+	for name := range r.handlers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -37,3 +37,25 @@ type History interface {
 	ValidatorChanges(blocksAgo int) IterableReader
 	Validators(blocksAgo int) IterableReader
 }
+
+// TotalPower sums the power of every validator
+func TotalPower(it Iterable) (*big.Int, error) {
+	total := new(big.Int)
+	err := it.IterateValidators(func(id crypto.Addressable, power *big.Int) error {
+		total.Add(total, power)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return total, nil
+}
+
+// Unbonding is stake on its way back to a delegator's balance
+type Unbonding struct {
+	Validator crypto.Address `json:"validator"`
+	Delegator crypto.Address `json:"delegator"`
+	Amount    uint64         `json:"amount"`
+	// Height at the end of which the amount is credited to the delegator
+	Release uint64 `json:"release"`
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright (C) 2022  mobus <sunsc0220@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+// Package version describes the build of the running binary and the application protocol it implements
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// AppVersion is the version of the application protocol new chains start at. A running chain moves to a later one
+// with the upgrade handler that introduces it.
+const AppVersion uint64 = 1
+
+// Build metadata set at link time, for example
+//
+//	go build -ldflags "-X github.com/sunvim/yaoguang/version.Commit=$(git rev-parse HEAD)"
+//
+// Any that are set override what the Go toolchain recorded in the binary.
+var (
+	Version string
+	Branch  string
+	Commit  string
+	Date    string
+	// "true" if the commit was built with uncommitted changes
+	Modified string
+)
+
+// Info is the build of the running binary
+type Info struct {
+	Version    string `json:"version"`
+	Branch     string `json:"branch,omitempty"`
+	Commit     string `json:"commit,omitempty"`
+	Modified   bool   `json:"modified"`
+	Date       string `json:"date,omitempty"`
+	GoVersion  string `json:"go_version"`
+	Platform   string `json:"platform"`
+	AppVersion uint64 `json:"app_version"`
+}
+
+// Get reads the build info the Go toolchain embedded in the binary and applies the link time overrides
+func Get() Info {
+	info := Info{
+		Version:    "dev",
+		GoVersion:  runtime.Version(),
+		Platform:   runtime.GOOS + "/" + runtime.GOARCH,
+		AppVersion: AppVersion,
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range bi.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				info.Commit = setting.Value
+			case "vcs.time":
+				info.Date = setting.Value
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+		// a pseudo-version only repeats the commit and its time
+		if v := bi.Main.Version; v != "" && v != "(devel)" && !isPseudoVersion(v, info.Commit) {
+			info.Version = v
+		}
+	}
+	if Version != "" {
+		info.Version = Version
+	}
+	if Branch != "" {
+		info.Branch = Branch
+	}
+	if Commit != "" {
+		info.Commit = Commit
+		info.Modified = Modified == "true"
+	}
+	if Date != "" {
+		info.Date = Date
+	}
+	return info
+}
+
+// String is the version with the commit it was built from, such as v0.2.0-821288f-dirty
+func (info Info) String() string {
+	s := info.Version
+	if info.Commit != "" {
+		commit := info.Commit
+		if len(commit) > 7 {
+			commit = commit[:7]
+		}
+		s += "-" + commit
+	}
+	if info.Modified {
+		s += "-dirty"
+	}
+	return s
+}
+
+func isPseudoVersion(version, commit string) bool {
+	const shortCommit = 12
+	return len(commit) >= shortCommit && strings.Contains(version, commit[:shortCommit])
+}
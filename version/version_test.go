@@ -0,0 +1,28 @@
+package version
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGet_Override(t *testing.T) {
+	defer func(version, commit, modified string) {
+		Version, Commit, Modified = version, commit, modified
+	}(Version, Commit, Modified)
+	Version, Commit, Modified = "v0.3.0", "821288f0a9c4d1e2", "true"
+
+	info := Get()
+	assert.Equal(t, "v0.3.0", info.Version)
+	assert.Equal(t, "821288f0a9c4d1e2", info.Commit)
+	assert.True(t, info.Modified)
+	assert.Equal(t, AppVersion, info.AppVersion)
+	assert.Equal(t, "v0.3.0-821288f-dirty", info.String())
+}
+
+func TestIsPseudoVersion(t *testing.T) {
+	commit := "5840e0b3ff8e4e0aff2fb2a07496515287963103"
+	assert.True(t, isPseudoVersion("v0.0.0-20261019045816-5840e0b3ff8e+dirty", commit))
+	assert.False(t, isPseudoVersion("v0.3.0", commit))
+	assert.False(t, isPseudoVersion("v0.3.0", ""))
+}